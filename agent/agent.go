@@ -9,18 +9,26 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/doublemo/balala/agent/service"
 	"github.com/doublemo/balala/agent/session"
 	"github.com/doublemo/balala/cores/process"
+	"github.com/doublemo/balala/cores/proto"
 	"github.com/doublemo/balala/cores/services"
+	"github.com/doublemo/balala/cores/tracing"
 	"github.com/doublemo/balala/cores/utils"
 	"github.com/doublemo/balala/internal/serviceid"
 	"github.com/gin-gonic/gin"
 	"github.com/go-kit/kit/log"
 	kitlog "github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/kit/sd/etcdv3"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
 )
 
 // Agent 代理服务器
@@ -44,16 +52,47 @@ type Agent struct {
 	// sessionStore session存储
 	sessionStore *session.Store
 
+	// router 客户端请求路由
+	router *router
+
+	// sessionState 会话状态服务连接
+	sessionState *sessionState
+
+	// tracer 请求追踪, grpc服务重启时保持不变
+	tracer *tracing.Zipkin
+
+	// registerChan 通知服务注册以当前参数覆盖注册信息
+	registerChan chan struct{}
+
 	// ServiceOpts 系统服务参数
 	serviceOpts *services.Options
 
 	// servicesCaches 集群服务信息缓存
 	servicesCaches map[int32]string
 
+	// actors 服务名称对应的协程ID, 用于重新加载配置时重启服务
+	actors map[string]int32
+
+	// readyedOnce 服务注册可能因为重新加载配置而重启
+	readyedOnce sync.Once
+
+	// reloadMutex 重新加载配置
+	reloadMutex sync.Mutex
+
+	// logLevel 日志级别过滤, 重新加载配置时切换
+	logLevel *log.SwapLogger
+
+	// rawLogger 未过滤级别的日志
+	rawLogger log.Logger
+
 	// logger
 	logger log.Logger
 }
 
+// runtimeActors 服务注册顺序就是服务的启动顺序
+// 关闭服务时会反顺关闭
+var runtimeActors = []string{"grpc", "socket", "kcp", "http", "websocket", "sss", "services"}
+
 // Start 启动服务
 func (s *Agent) Start() {
 	defer func() {
@@ -62,8 +101,12 @@ func (s *Agent) Start() {
 
 	rand.Seed(time.Now().UnixNano())
 
+	s.reloadMutex.Lock()
+
 	// 读取一个配置文件副本
+	// 保存副本后再读取配置不需要重新查询本机IP
 	opts := s.configureOptions.Read()
+	s.configureOptions.Reset(opts)
 
 	// gin web framework
 	gin.SetMode(gin.ReleaseMode)
@@ -77,24 +120,31 @@ func (s *Agent) Start() {
 	// init etcd
 	utils.Assert(s.makeEtcdv3Client())
 
-	// 开始注册服务
-	// 注意服务注册顺序就是服务的启动顺序
-	// 关闭服务时会反顺关闭
-	// internal grpc
-	s.process.Add(s.mustRuntimeActor(makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger)), true)
+	// init tracer
+	utils.Assert(s.makeTracer())
+
+	// init routes
+	s.router = newRouter([]byte(opts.ServiceSecurityKey), s.logger)
+	s.sessionState = newSessionState(uuid.NewV4().String(), s.serviceOpts.MachineID, s.sessionStore, []byte(opts.ServiceSecurityKey), s.logger)
+	makeRoutes(s.router, s.sessionStore, s.sessionState, opts)
+	s.sessionStore.OnRemove(s.sessionState.Unregister)
+	s.sessionStore.SetCompressThreshold(opts.CompressThreshold)
+	s.sessionStore.SetRequestLimits(time.Duration(opts.RequestTimeout)*time.Second, opts.MaxInflight)
+	s.sessionStore.SetDisconnectCounter(prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: opts.ID,
+		Subsystem: "agent",
+		Name:      "disconnect_total",
+		Help:      "Total count of client disconnections by reason.",
+	}, []string{"reason"}))
 
-	// socket
-	s.process.Add(makeSocketRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
-
-	// http
-	s.process.Add(makeHTTPRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
-
-	// websocket
-	s.process.Add(makeWebsocketRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
+	// 开始注册服务
+	for _, name := range runtimeActors {
+		s.actors[name] = s.mustRuntimeActor(name)
+	}
 
-	// 创建服务
-	s.process.Add(s.mustRuntimeActor(s.makeServices()), true)
+	s.reloadMutex.Unlock()
 	s.process.Run()
+	s.tracer.Close()
 }
 
 // Readyed 返回服务准备就绪信号
@@ -107,10 +157,146 @@ func (s *Agent) Readyed() bool {
 func (s *Agent) Shutdown() {
 	kitlog.Debug(s.logger).Log("Agent", "shutdown")
 	s.process.Stop()
+	if s.router != nil {
+		s.router.Close()
+	}
 }
 
-// Reload 重新加载服务
-func (s *Agent) Reload() {}
+// Reload 重新加载配置文件
+// 可以在线修改的参数立即生效, 监听参数改变时只重启对应的服务.
+// 配置文件错误时保留原来的配置
+func (s *Agent) Reload() {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	// 服务还没有启动
+	if s.router == nil {
+		return
+	}
+
+	old := s.configureOptions.Read()
+	if err := s.configureOptions.Load(); err != nil {
+		kitlog.Error(s.logger).Log("reload", "failed", "error", err)
+		return
+	}
+
+	opts := s.configureOptions.Read()
+	if opts.KCP != nil {
+		if err := opts.KCP.Config().Validate(); err != nil {
+			s.configureOptions.Reset(old)
+			kitlog.Error(s.logger).Log("reload", "failed", "error", err)
+			return
+		}
+	}
+
+	// 以下参数需要重启进程才能生效, 保持原来的值
+	var ignored []string
+	if opts.ID != old.ID || opts.LocalIP != old.LocalIP || opts.Domain != old.Domain {
+		opts.ID, opts.LocalIP, opts.Domain = old.ID, old.LocalIP, old.Domain
+		ignored = append(ignored, "id/localip/domain")
+	}
+
+	if !reflect.DeepEqual(opts.ETCD, old.ETCD) {
+		opts.ETCD = old.ETCD
+		ignored = append(ignored, "etcd")
+	}
+
+	if opts.ServiceSecurityKey != old.ServiceSecurityKey {
+		opts.ServiceSecurityKey = old.ServiceSecurityKey
+		ignored = append(ignored, "servicesecuritykey")
+	}
+
+	s.configureOptions.Reset(opts)
+
+	var (
+		changed  []string
+		restarts []string
+	)
+
+	if opts.Runmode != old.Runmode {
+		s.logLevel.Swap(utils.LevelLogger(s.rawLogger, opts.Runmode))
+		changed = append(changed, "runmode")
+	}
+
+	if opts.TokenKey != old.TokenKey || !reflect.DeepEqual(opts.Login, old.Login) {
+		s.router.HandleFunc(proto.InternalLogin, makeLoginHandler(s.sessionStore, opts, s.sessionState))
+		changed = append(changed, "login")
+	}
+
+	if opts.CompressThreshold != old.CompressThreshold {
+		s.sessionStore.SetCompressThreshold(opts.CompressThreshold)
+		changed = append(changed, "compressthreshold")
+	}
+
+	if opts.RequestTimeout != old.RequestTimeout || opts.MaxInflight != old.MaxInflight {
+		s.sessionStore.SetRequestLimits(time.Duration(opts.RequestTimeout)*time.Second, opts.MaxInflight)
+		changed = append(changed, "requestlimits")
+	}
+
+	sections := []struct {
+		name     string
+		old, new interface{}
+		live     []string
+	}{
+		{"grpc", old.GRPC, opts.GRPC, nil},
+		{"socket", old.Socket, opts.Socket, []string{"MaxMessageSize", "ReadDeadline", "WriteDeadline", "RPMLimit"}},
+		{"kcp", old.KCP, opts.KCP, []string{"NoComp", "MaxMessageSize", "ReadDeadline", "WriteDeadline", "RPMLimit"}},
+		{"http", old.HTTP, opts.HTTP, nil},
+		{"websocket", old.WebSocket, opts.WebSocket, []string{"MaxMessageSize", "ReadDeadline", "WriteDeadline", "RPMLimit"}},
+	}
+
+	if !reflect.DeepEqual(opts.Tracer, old.Tracer) {
+		s.tracer.SetReporterURL(opts.Tracer.GetReporterURL())
+		changed = append(changed, "tracer")
+	}
+
+	restart := make(map[string]bool)
+	for _, section := range sections {
+		fields, fieldsRestart := utils.DiffFields(section.old, section.new, section.live...)
+		for _, field := range fields {
+			changed = append(changed, section.name+"."+field)
+		}
+
+		if len(fieldsRestart) > 0 {
+			restart[section.name] = true
+		}
+	}
+
+	// 优先级只需要更新注册信息
+	var register bool
+	if opts.Priority != old.Priority {
+		s.serviceOpts.Priority = opts.Priority
+		changed = append(changed, "priority")
+		register = true
+	}
+
+	// 监听地址改变时需要重新注册服务
+	if len(restart) > 0 {
+		restart["services"] = true
+	}
+
+	for _, name := range runtimeActors {
+		if !restart[name] {
+			continue
+		}
+
+		if err := s.restartRuntimeActor(name); err != nil {
+			kitlog.Error(s.logger).Log("reload", "restart", "actor", name, "error", err)
+			continue
+		}
+
+		restarts = append(restarts, name)
+	}
+
+	if register && !restart["services"] {
+		select {
+		case s.registerChan <- struct{}{}:
+		default:
+		}
+	}
+
+	s.logger.Log("reload", "ok", "changed", strings.Join(changed, ","), "restarted", strings.Join(restarts, ","), "ignored", strings.Join(ignored, ","))
+}
 
 // ServiceName 返回唯一服务名称
 func (s *Agent) ServiceName() string {
@@ -185,21 +371,36 @@ func (s *Agent) makeEtcdv3Client() error {
 	return nil
 }
 
+func (s *Agent) makeTracer() error {
+	opts := s.configureOptions.Read()
+	tracer, err := tracing.NewZipkin("agent", opts.Tracer.GetReporterURL())
+	if err != nil {
+		return err
+	}
+
+	s.tracer = tracer
+	return nil
+}
+
 func (s *Agent) makeServices() (*process.RuntimeActor, error) {
 	opts := s.configureOptions.Read()
 	if opts.ETCD == nil {
 		return nil, errors.New("ETCD options is nil")
 	}
 
-	registrar := etcdv3.NewRegistrar(s.etcdV3Client, etcdv3.Service{
+	regService := etcdv3.Service{
 		Key:   services.RegKey(opts.ETCD.Frefix, s.serviceOpts),
 		Value: services.RegValue(s.serviceOpts),
-	}, s.logger)
+	}
+
+	registrar := etcdv3.NewRegistrar(s.etcdV3Client, regService, s.logger)
 	serviceChan := make(chan struct{})
 	return &process.RuntimeActor{
 		Exec: func() error {
 			registrar.Register()
-			close(s.readyedChan)
+			s.readyedOnce.Do(func() {
+				close(s.readyedChan)
+			})
 			ch := make(chan struct{})
 			go s.etcdV3Client.WatchPrefix(opts.ETCD.Frefix, ch)
 			for {
@@ -215,6 +416,15 @@ func (s *Agent) makeServices() (*process.RuntimeActor, error) {
 						service.Caches.StoreFromString(s)
 					}
 
+					if s.router != nil {
+						s.router.Prune()
+					}
+
+				case <-s.registerChan:
+					// 相同的key以新的值重新注册, 关闭时原来的registrar仍然可以注销
+					regService.Value = services.RegValue(s.serviceOpts)
+					etcdv3.NewRegistrar(s.etcdV3Client, regService, s.logger).Register()
+
 				case <-s.exitChan:
 					return nil
 
@@ -233,26 +443,72 @@ func (s *Agent) makeServices() (*process.RuntimeActor, error) {
 	}, nil
 }
 
-func (s *Agent) mustRuntimeActor(actor *process.RuntimeActor, err error) *process.RuntimeActor {
+// makeRuntimeActor 根据当前配置创建服务
+func (s *Agent) makeRuntimeActor(name string) (*process.RuntimeActor, error) {
+	switch name {
+	case "grpc":
+		return makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.tracer, s.logger)
+
+	case "socket":
+		return makeSocketRuntimeActor(s.serviceOpts, s.configureOptions, s.sessionStore, s.router, s.logger), nil
+
+	case "kcp":
+		return makeKCPRuntimeActor(s.serviceOpts, s.configureOptions, s.sessionStore, s.router, s.logger), nil
+
+	case "http":
+		return makeHTTPRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), nil
+
+	case "websocket":
+		return makeWebsocketRuntimeActor(s.serviceOpts, s.configureOptions, s.sessionStore, s.router, s.logger), nil
+
+	case "sss":
+		return makeSessionStateRuntimeActor(s.sessionState, s.logger), nil
+
+	case "services":
+		return s.makeServices()
+	}
+
+	return nil, nil
+}
+
+// restartRuntimeActor 关闭服务并按当前配置重新创建
+func (s *Agent) restartRuntimeActor(name string) error {
+	id, err := s.process.Replace(s.actors[name], s.runtimeActorFunc(name))
+
+	s.actors[name] = id
+	return err
+}
+
+// runtimeActorFunc 服务的创建函数, 服务重启时按当前配置重新创建
+// 同时删除注册信息中原来的监听地址
+func (s *Agent) runtimeActorFunc(name string) func() (*process.RuntimeActor, error) {
+	return func() (*process.RuntimeActor, error) {
+		delete(s.serviceOpts.Params, name)
+		return s.makeRuntimeActor(name)
+	}
+}
+
+// mustRuntimeActor 注册服务, 创建失败时退出
+func (s *Agent) mustRuntimeActor(name string) int32 {
+	id, err := s.process.AddFunc(s.runtimeActorFunc(name))
 	if err != nil {
 		kitlog.Error(s.logger).Log("error", err)
 		panic(err)
 	}
 
-	return actor
+	return id
 }
 
 // New 创建网关服务
 func New(serviceOpts *services.Options, opts *ConfigureOptions) *Agent {
-	logger := log.NewLogfmtLogger(os.Stderr)
-	logger = log.WithPrefix(logger, "o", "Agent server")
-	if opts.Read().Runmode == "dev" {
-		logger = kitlog.NewFilter(logger, kitlog.AllowAll())
-	} else {
-		logger = kitlog.NewFilter(logger, kitlog.AllowError(), kitlog.AllowWarn())
-	}
+	rawLogger := log.NewLogfmtLogger(os.Stderr)
+	rawLogger = log.WithPrefix(rawLogger, "o", "Agent server")
+
+	var logLevel log.SwapLogger
+	logLevel.Swap(utils.LevelLogger(rawLogger, opts.Read().Runmode))
 
-	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	var logger log.Logger
+	logger = log.With(&logLevel, "ts", log.DefaultTimestampUTC)
 	logger = log.With(logger, "caller", log.DefaultCaller)
 
 	return &Agent{
@@ -261,6 +517,10 @@ func New(serviceOpts *services.Options, opts *ConfigureOptions) *Agent {
 		configureOptions: opts,
 		process:          process.NewRuntimeContainer(),
 		sessionStore:     session.NewStore(logger),
+		registerChan:     make(chan struct{}, 1),
+		actors:           make(map[string]int32),
+		logLevel:         &logLevel,
+		rawLogger:        rawLogger,
 		logger:           logger,
 		serviceOpts:      serviceOpts,
 	}
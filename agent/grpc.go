@@ -17,17 +17,12 @@ import (
 	"github.com/doublemo/balala/cores/process"
 	"github.com/doublemo/balala/cores/proto/pb"
 	"github.com/doublemo/balala/cores/services"
+	"github.com/doublemo/balala/cores/tracing"
 	"github.com/doublemo/balala/cores/utils"
 	"github.com/go-kit/kit/log"
 	kitlog "github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics"
-	"github.com/go-kit/kit/metrics/prometheus"
 	kitgrpc "github.com/go-kit/kit/transport/grpc"
-	stdopentracing "github.com/opentracing/opentracing-go"
-	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
-	stdzipkin "github.com/openzipkin/zipkin-go"
-	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
-	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -35,6 +30,8 @@ import (
 
 // baseGRPCServer 服务于内部通信的grpc
 type baseGRPCServer struct {
+	// sessionStore session存储
+	sessionStore *session.Store
 
 	// logger 日志
 	logger log.Logger
@@ -46,6 +43,8 @@ func (s *baseGRPCServer) Call(ctx context.Context, in *pb.Request) (*pb.Response
 	return &pb.Response{Command: 1}, nil
 }
 
+// Stream 后端服务推送信息到客户端
+// 每条信息都按接收顺序回复一个回执, 失败时回执的Command为InternalBad, Body为pb.Bad
 func (s *baseGRPCServer) Stream(_ context.Context, stream pb.Internal_StreamServer) error {
 	defer utils.RecoverStackPanic(s.logger)
 	_, ok := metadata.FromIncomingContext(stream.Context())
@@ -54,9 +53,15 @@ func (s *baseGRPCServer) Stream(_ context.Context, stream pb.Internal_StreamServ
 	}
 
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// 对方关闭发送时recvChan被关闭, 已经收到的请求处理完成后再返回
+	done := make(chan struct{})
+	defer close(done)
+
 	recvChan := make(chan *pb.Request, 4096)
-	recvErr := make(chan error)
-	go s.recv(stream, recvChan, recvErr)
+	recvErr := make(chan error, 1)
+	go s.recv(stream, recvChan, recvErr, done)
 	for {
 		select {
 		case frame, ok := <-recvChan:
@@ -64,13 +69,12 @@ func (s *baseGRPCServer) Stream(_ context.Context, stream pb.Internal_StreamServ
 				return nil
 			}
 
-			s.logger.Log("frame", frame)
-
-		case err, ok := <-recvErr:
-			if !ok {
+			if err := stream.Send(push(s.sessionStore, frame)); err != nil {
+				kitlog.Error(s.logger).Log("error", err)
 				return nil
 			}
 
+		case err := <-recvErr:
 			s.logger.Log("error", err)
 			return nil
 
@@ -79,16 +83,13 @@ func (s *baseGRPCServer) Stream(_ context.Context, stream pb.Internal_StreamServ
 	}
 }
 
-func (s *baseGRPCServer) recv(stream pb.Internal_StreamServer, recvChan chan *pb.Request, recvErr chan error) {
-	defer func() {
-		close(recvChan)
-		close(recvErr)
-	}()
-
+// recv 接收请求, 对方关闭发送时关闭recvChan, 其它错误发送到recvErr
+func (s *baseGRPCServer) recv(stream pb.Internal_StreamServer, recvChan chan *pb.Request, recvErr chan error, done chan struct{}) {
 	for {
 		frame, err := stream.Recv()
 		if err == io.EOF {
-			continue
+			close(recvChan)
+			return
 		}
 
 		if err != nil {
@@ -96,17 +97,22 @@ func (s *baseGRPCServer) recv(stream pb.Internal_StreamServer, recvChan chan *pb
 			return
 		}
 
-		recvChan <- frame
+		select {
+		case recvChan <- frame:
+		case <-done:
+			return
+		}
 	}
 }
 
-func newBaseGRPCServer(logger log.Logger) *baseGRPCServer {
+func newBaseGRPCServer(store *session.Store, logger log.Logger) *baseGRPCServer {
 	return &baseGRPCServer{
-		logger: logger,
+		sessionStore: store,
+		logger:       logger,
 	}
 }
 
-func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, logger log.Logger) (*process.RuntimeActor, error) {
+func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, tracer *tracing.Zipkin, logger log.Logger) (*process.RuntimeActor, error) {
 	grpcOpts := opts.GRPC
 	if grpcOpts == nil {
 		return nil, nil
@@ -120,7 +126,7 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 	serviceOpts.Port = port
 	var duration metrics.Histogram
 	{
-		duration = prometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+		duration = utils.SummaryFrom(stdprometheus.SummaryOpts{
 			Namespace: opts.ID,
 			Subsystem: "agent",
 			Name:      "request_duration_seconds",
@@ -130,7 +136,7 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 
 	var counter metrics.Gauge
 	{
-		counter = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		counter = utils.GaugeFrom(stdprometheus.GaugeOpts{
 			Namespace: opts.ID,
 			Subsystem: "agent",
 			Name:      "connect_to_counter",
@@ -138,27 +144,10 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 		}, []string{"method"})
 	}
 
-	tracer := stdopentracing.GlobalTracer() // no-op
-
-	var reporter zipkinreporter.Reporter
-	zipkinTracer, err := stdzipkin.NewTracer(nil, stdzipkin.WithNoopTracer(true))
-	if opts.Tracer != nil && opts.Tracer.ReporterURL != "" {
-
-		// some http://192.168.31.20:9411/api/v2/spans
-		reporter = zipkinhttp.NewReporter(opts.Tracer.ReporterURL)
-		zEP, _ := stdzipkin.NewEndpoint("agent", port)
-		zipkinTracer, err = stdzipkin.NewTracer(reporter, stdzipkin.WithLocalEndpoint(zEP))
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	tracer = zipkinot.Wrap(zipkinTracer)
 	var (
-		s          = newBaseGRPCServer(logger)
-		endpoints  = endpoint.NewSet(s, logger, duration, counter, tracer, zipkinTracer, makeKeyFuncByJWT(opts.ServiceSecurityKey))
-		grpcServer = transport.NewGRPCServer(endpoints, tracer, zipkinTracer, logger)
+		s          = newBaseGRPCServer(store, logger)
+		endpoints  = endpoint.NewSet(s, logger, duration, counter, tracer.OpenTracer(), tracer.Tracer(), makeKeyFuncByJWT(opts.ServiceSecurityKey))
+		grpcServer = transport.NewGRPCServer(endpoints, tracer.OpenTracer(), tracer.Tracer(), logger)
 	)
 
 	lis, err := net.Listen("tcp", grpcOpts.Addr)
@@ -181,12 +170,10 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 
 		Close: func() {
 			logger.Log("transport", "grpc", "on", "shutdown")
-			if reporter != nil {
-				reporter.Close()
-			}
-
 			lis.Close()
 		},
+
+		Restart: process.RestartOnFailure,
 	}, nil
 }
 
@@ -0,0 +1,237 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/crypto/dh"
+	"github.com/doublemo/balala/cores/proto"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// handshakeSalt rc4密钥盐
+	handshakeSalt = "balala"
+
+	// handshakeInfoC2S 客户端到服务器方向的密钥派生信息
+	handshakeInfoC2S = "balala c2s"
+
+	// handshakeInfoS2C 服务器到客户端方向的密钥派生信息
+	handshakeInfoS2C = "balala s2c"
+
+	// handshakeMACSalt rc4帧认证密钥盐
+	handshakeMACSalt = "balala mac"
+)
+
+var (
+	// ErrHandshakeRepeated 重复的密钥交换
+	ErrHandshakeRepeated = errors.New("ErrHandshakeRepeated")
+
+	// ErrHandshakeRequired 未完成密钥交换
+	ErrHandshakeRequired = errors.New("ErrHandshakeRequired")
+)
+
+//go:generate go run github.com/doublemo/balala/cmd/packgen -type=handshakeRequest,handshakeResponse
+
+// handshakeRequest rc4密钥交换请求
+type handshakeRequest struct {
+	// SendSeed 客户端发送方向的公开值
+	SendSeed uint32
+
+	// ReceiveSeed 客户端接收方向的公开值
+	ReceiveSeed uint32
+}
+
+// handshakeResponse 密钥交换响应
+type handshakeResponse struct {
+	// SendSeed 服务端发送方向的公开值
+	SendSeed uint32
+
+	// ReceiveSeed 服务端接收方向的公开值
+	ReceiveSeed uint32
+}
+
+// handleHandshake 处理客户端密钥交换
+// 加密方式由请求的版本号决定, 不支持的版本号返回ErrInvalidCipher.
+// 密钥安装到session后设置FlagKeyexcg,
+// 待响应发出后session切换为FlagEncrypt.
+// 加密参数之后可以追加协商参数, 见handshakeNegotiate
+func handleHandshake(sess *session.Client, req *proto.RequestBytes) (*proto.ResponseBytes, error) {
+	if sess.Flag()&(session.FlagKeyexcg|session.FlagEncrypt) != 0 {
+		return nil, ErrHandshakeRepeated
+	}
+
+	var (
+		cipher session.Cipher
+		body   []byte
+		err    error
+	)
+
+	r := proto.NewBytesBuffer(req.Body())
+	switch v := req.V(); {
+	case v == session.CipherRC4:
+		cipher, body, err = handshakeRC4(r)
+
+	case v == session.CipherChaCha20Poly1305, v == session.CipherAESGCM:
+		cipher, body, err = handshakeX25519(v, r)
+
+	default:
+		err = session.ErrInvalidCipher
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sess.SetCipher(cipher)
+	body = append(body, handshakeNegotiate(sess, r.Bytes())...)
+	sess.Flag(sess.Flag() | session.FlagKeyexcg)
+	return &proto.ResponseBytes{
+		Ver:     req.V(),
+		Cmd:     req.Command(),
+		SubCmd:  req.SubCommand(),
+		SeqID:   req.SID(),
+		Content: body,
+	}, nil
+}
+
+// handshakeRC4 DH交换生成rc4密钥
+// 客户端依次发送自己发送方向与接收方向的公开值(uint32),
+// 服务器分别生成两组密钥, 帧认证密钥为SHA256("balala mac" + 对应方向的DH密钥)
+func handshakeRC4(r *proto.BytesBuffer) (session.Cipher, []byte, error) {
+	var req handshakeRequest
+	if err := proto.Unpack(r, &req); err != nil {
+		return nil, nil, err
+	}
+
+	x1, e1 := dh.DHExchange()
+	x2, e2 := dh.DHExchange()
+	key1 := dh.DHKey(x1, big.NewInt(int64(req.SendSeed)))
+	key2 := dh.DHKey(x2, big.NewInt(int64(req.ReceiveSeed)))
+
+	encoder, err := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, key2)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder, err := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, key1)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var w proto.BytesBuffer
+	body, err := proto.Pack(&w, &handshakeResponse{SendSeed: uint32(e1.Uint64()), ReceiveSeed: uint32(e2.Uint64())})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 帧认证密钥只在协商了帧序号时使用
+	sendKey := sha256.Sum256([]byte(fmt.Sprintf("%v%v", handshakeMACSalt, key2)))
+	recvKey := sha256.Sum256([]byte(fmt.Sprintf("%v%v", handshakeMACSalt, key1)))
+	cipher := session.NewRC4Cipher(encoder, decoder)
+	cipher.SetMACKeys(sendKey[:], recvKey[:])
+	return cipher, body, nil
+}
+
+// handshakeX25519 X25519交换生成AEAD密钥
+// 客户端发送32字节公钥, 服务器返回自己的32字节公钥
+// 双方以共享密钥通过HKDF-SHA256派生两个方向的密钥, 盐为客户端公钥+服务器公钥
+func handshakeX25519(version int8, r *proto.BytesBuffer) (session.Cipher, []byte, error) {
+	frame, err := r.ReadBytes(curve25519.ScalarSize)
+	if err != nil {
+		return nil, nil, session.ErrInvalidCipher
+	}
+
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, priv); err != nil {
+		return nil, nil, err
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret, err := curve25519.X25519(priv, frame)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	salt := make([]byte, 0, len(frame)+len(pub))
+	salt = append(salt, frame...)
+	salt = append(salt, pub...)
+
+	decryptKey, err := deriveKey(secret, salt, handshakeInfoC2S)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encryptKey, err := deriveKey(secret, salt, handshakeInfoS2C)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipher, err := session.NewAEADCipher(version, encryptKey, decryptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cipher, pub, nil
+}
+
+// handshakeNegotiate 协商会话参数
+// 客户端在加密参数之后依次追加期望的参数, 每个参数一个字节, 没有发送的参数使用默认值:
+// 第1个字节为长度头格式(proto.Framing), 不支持的格式使用FramingUint16;
+// 第2个字节为压缩算法(proto.Compression), 服务器没有注册的算法使用CompressionNone;
+// 第3个字节为1时启用帧序号防止重放, 见session.SequencedCipher.
+// 客户端发送了协商参数时, 响应在加密参数之后按同样的顺序返回服务器的选择
+func handshakeNegotiate(sess *session.Client, options []byte) []byte {
+	if len(options) < 1 {
+		return nil
+	}
+
+	framing := proto.Framing(options[0])
+	if !framing.IsValid() {
+		framing = proto.FramingUint16
+	}
+
+	sess.SetFraming(framing)
+	if len(options) < 2 {
+		return []byte{byte(framing)}
+	}
+
+	compression := proto.Compression(options[1])
+	if _, ok := proto.GetCompressor(compression); !ok {
+		compression = proto.CompressionNone
+	}
+
+	sess.SetCompression(compression)
+	if len(options) < 3 {
+		return []byte{byte(framing), byte(compression)}
+	}
+
+	var sequenced byte
+	if options[2] == 1 && sess.EnableSequence() {
+		sequenced = 1
+	}
+
+	return []byte{byte(framing), byte(compression), sequenced}
+}
+
+func deriveKey(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,77 @@
+// Code generated by packgen. DO NOT EDIT.
+
+package agent
+
+import "github.com/doublemo/balala/cores/proto"
+
+// Pack 封包, 实现proto.FastPack
+func (v *handshakeRequest) Pack(w *proto.BytesBuffer) error {
+	if err := w.WriteUint32(uint32(v.SendSeed)); err != nil {
+		return err
+	}
+
+	if err := w.WriteUint32(uint32(v.ReceiveSeed)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unpack 解包, 实现proto.FastUnpack
+func (v *handshakeRequest) Unpack(r *proto.BytesBuffer) error {
+	{
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.SendSeed = uint32(m)
+	}
+
+	{
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.ReceiveSeed = uint32(m)
+	}
+
+	return nil
+}
+
+// Pack 封包, 实现proto.FastPack
+func (v *handshakeResponse) Pack(w *proto.BytesBuffer) error {
+	if err := w.WriteUint32(uint32(v.SendSeed)); err != nil {
+		return err
+	}
+
+	if err := w.WriteUint32(uint32(v.ReceiveSeed)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unpack 解包, 实现proto.FastUnpack
+func (v *handshakeResponse) Unpack(r *proto.BytesBuffer) error {
+	{
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.SendSeed = uint32(m)
+	}
+
+	{
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.ReceiveSeed = uint32(m)
+	}
+
+	return nil
+}
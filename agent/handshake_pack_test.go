@@ -0,0 +1,207 @@
+// Code generated by packgen. DO NOT EDIT.
+
+package agent
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/doublemo/balala/cores/proto"
+)
+
+type packgenReflectHandshakeRequest handshakeRequest
+
+func packgenSampleHandshakeRequest() *handshakeRequest {
+	v := &handshakeRequest{}
+	v.SendSeed = 2
+	v.ReceiveSeed = 3
+	return v
+}
+
+func TestPackgenHandshakeRequest(t *testing.T) {
+	v := packgenSampleHandshakeRequest()
+	var w1, w2 proto.BytesBuffer
+	data, err := proto.Pack(&w1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proto.Pack(&w2, (*packgenReflectHandshakeRequest)(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out, out2 := &handshakeRequest{}, &packgenReflectHandshakeRequest{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, (*handshakeRequest)(out2)) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, out2)
+	}
+}
+
+func BenchmarkPackgenHandshakeRequestPack(b *testing.B) {
+	v := packgenSampleHandshakeRequest()
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Pack(proto.NewBytesBuffer(buf[:0])); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeRequestPackReflect(b *testing.B) {
+	v := (*packgenReflectHandshakeRequest)(packgenSampleHandshakeRequest())
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Pack(proto.NewBytesBuffer(buf[:0]), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeRequestUnpack(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleHandshakeRequest())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &handshakeRequest{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Unpack(proto.NewBytesBuffer(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeRequestUnpackReflect(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleHandshakeRequest())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &packgenReflectHandshakeRequest{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := proto.Unpack(proto.NewBytesBuffer(data), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type packgenReflectHandshakeResponse handshakeResponse
+
+func packgenSampleHandshakeResponse() *handshakeResponse {
+	v := &handshakeResponse{}
+	v.SendSeed = 4
+	v.ReceiveSeed = 5
+	return v
+}
+
+func TestPackgenHandshakeResponse(t *testing.T) {
+	v := packgenSampleHandshakeResponse()
+	var w1, w2 proto.BytesBuffer
+	data, err := proto.Pack(&w1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proto.Pack(&w2, (*packgenReflectHandshakeResponse)(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out, out2 := &handshakeResponse{}, &packgenReflectHandshakeResponse{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, (*handshakeResponse)(out2)) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, out2)
+	}
+}
+
+func BenchmarkPackgenHandshakeResponsePack(b *testing.B) {
+	v := packgenSampleHandshakeResponse()
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Pack(proto.NewBytesBuffer(buf[:0])); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeResponsePackReflect(b *testing.B) {
+	v := (*packgenReflectHandshakeResponse)(packgenSampleHandshakeResponse())
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Pack(proto.NewBytesBuffer(buf[:0]), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeResponseUnpack(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleHandshakeResponse())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &handshakeResponse{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Unpack(proto.NewBytesBuffer(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenHandshakeResponseUnpackReflect(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleHandshakeResponse())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &packgenReflectHandshakeResponse{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := proto.Unpack(proto.NewBytesBuffer(data), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/crypto/dh"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/go-kit/kit/log"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestHandshakeRC4(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+	defer store.RemoveAndExit(sess.ID())
+
+	x1, e1 := dh.DHExchange()
+	x2, e2 := dh.DHExchange()
+
+	var w proto.BytesBuffer
+	w.WriteUint32(uint32(e1.Uint64()))
+	w.WriteUint32(uint32(e2.Uint64()))
+
+	req := &proto.RequestBytes{Ver: session.CipherRC4, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: w.Data()}
+	resp, err := handleHandshake(sess, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sess.Flag()&session.FlagKeyexcg == 0 {
+		t.Fatal("FlagKeyexcg is not set")
+	}
+
+	r := proto.NewBytesBuffer(resp.Body())
+	sendSeed, _ := r.ReadUint32()
+	receiveSeed, _ := r.ReadUint32()
+
+	// 客户端的发送方向对应服务器的接收方向
+	encoder, _ := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, dh.DHKey(x1, big.NewInt(int64(sendSeed))))))
+	decoder, _ := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, dh.DHKey(x2, big.NewInt(int64(receiveSeed))))))
+	testHandshakeCipher(t, sess, session.NewRC4Cipher(encoder, decoder))
+
+	if _, err := handleHandshake(sess, req); err != ErrHandshakeRepeated {
+		t.Fatalf("expected ErrHandshakeRepeated, got %v", err)
+	}
+}
+
+func TestHandshakeInvalidCipher(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+	defer store.RemoveAndExit(sess.ID())
+
+	var w proto.BytesBuffer
+	w.WriteUint32(1)
+	w.WriteUint32(1)
+	for _, version := range []int8{0, -1, proto.VerCompressed | session.CipherRC4, session.CipherAESGCM + 1} {
+		req := &proto.RequestBytes{Ver: version, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: w.Data()}
+		if _, err := handleHandshake(sess, req); err != session.ErrInvalidCipher {
+			t.Fatalf("version %d: expected ErrInvalidCipher, got %v", version, err)
+		}
+	}
+
+	if sess.Flag()&session.FlagKeyexcg != 0 {
+		t.Fatal("FlagKeyexcg is set")
+	}
+}
+
+func TestHandshakeX25519(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	for _, version := range []int8{session.CipherChaCha20Poly1305, session.CipherAESGCM} {
+		sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+		priv := make([]byte, curve25519.ScalarSize)
+		rand.Read(priv)
+		pub, _ := curve25519.X25519(priv, curve25519.Basepoint)
+
+		req := &proto.RequestBytes{Ver: version, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: pub}
+		resp, err := handleHandshake(sess, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secret, err := curve25519.X25519(priv, resp.Body())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		salt := append(append([]byte{}, pub...), resp.Body()...)
+		encryptKey, _ := deriveKey(secret, salt, handshakeInfoC2S)
+		decryptKey, _ := deriveKey(secret, salt, handshakeInfoS2C)
+		cipher, err := session.NewAEADCipher(version, encryptKey, decryptKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testHandshakeCipher(t, sess, cipher)
+
+		// 篡改的数据必须被拒绝
+		frame, _ := cipher.Encrypt([]byte("balala"))
+		frame[0] ^= 0xff
+		if _, err := sess.DecodeFrame(frame); err != session.ErrDecryptFailed {
+			t.Fatalf("expected ErrDecryptFailed, got %v", err)
+		}
+
+		store.RemoveAndExit(sess.ID())
+	}
+}
+
+func TestHandshakeNegotiate(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	cases := []struct {
+		options     []byte
+		framing     proto.Framing
+		compression proto.Compression
+		body        int
+	}{
+		{nil, proto.FramingUint16, proto.CompressionNone, curve25519.ScalarSize},
+		{[]byte{byte(proto.FramingVarint)}, proto.FramingVarint, proto.CompressionNone, curve25519.ScalarSize + 1},
+		{[]byte{byte(proto.FramingUint32), 0}, proto.FramingUint32, proto.CompressionNone, curve25519.ScalarSize + 2},
+		{[]byte{0x7f}, proto.FramingUint16, proto.CompressionNone, curve25519.ScalarSize + 1},
+		{[]byte{0, byte(proto.CompressionSnappy)}, proto.FramingUint16, proto.CompressionSnappy, curve25519.ScalarSize + 2},
+		{[]byte{0, byte(proto.CompressionSnappy) + 1}, proto.FramingUint16, proto.CompressionNone, curve25519.ScalarSize + 2},
+		{[]byte{0, 0x7f, 0}, proto.FramingUint16, proto.CompressionNone, curve25519.ScalarSize + 3},
+	}
+
+	for _, c := range cases {
+		sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+		pub := make([]byte, curve25519.ScalarSize)
+		rand.Read(pub)
+
+		req := &proto.RequestBytes{Ver: session.CipherChaCha20Poly1305, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: append(pub, c.options...)}
+		resp, err := handleHandshake(sess, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.Body()) != c.body {
+			t.Fatalf("options %v: expected body size %d, got %d", c.options, c.body, len(resp.Body()))
+		}
+
+		if len(c.options) > 0 && proto.Framing(resp.Body()[curve25519.ScalarSize]) != c.framing {
+			t.Fatalf("options %v: expected framing %v in response, got %v", c.options, c.framing, resp.Body()[curve25519.ScalarSize])
+		}
+
+		if sess.Framing() != c.framing {
+			t.Fatalf("options %v: expected framing %v, got %v", c.options, c.framing, sess.Framing())
+		}
+
+		if len(c.options) > 1 && proto.Compression(resp.Body()[curve25519.ScalarSize+1]) != c.compression {
+			t.Fatalf("options %v: expected compression %v in response, got %v", c.options, c.compression, resp.Body()[curve25519.ScalarSize+1])
+		}
+
+		if sess.Compression() != c.compression {
+			t.Fatalf("options %v: expected compression %v, got %v", c.options, c.compression, sess.Compression())
+		}
+
+		store.RemoveAndExit(sess.ID())
+	}
+}
+
+func TestHandshakeSequence(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+
+	// rc4只接受下一个序号的帧
+	sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+	defer store.RemoveAndExit(sess.ID())
+
+	x1, e1 := dh.DHExchange()
+	x2, e2 := dh.DHExchange()
+
+	var w proto.BytesBuffer
+	w.WriteUint32(uint32(e1.Uint64()))
+	w.WriteUint32(uint32(e2.Uint64()))
+	w.WriteBytes(0, 0, 1)
+
+	resp, err := handleHandshake(sess, &proto.RequestBytes{Ver: session.CipherRC4, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: w.Data()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := proto.NewBytesBuffer(resp.Body())
+	sendSeed, _ := r.ReadUint32()
+	receiveSeed, _ := r.ReadUint32()
+	if !reflect.DeepEqual(r.Bytes(), []byte{0, 0, 1}) {
+		t.Fatalf("expected sequence enabled, got %v", r.Bytes())
+	}
+
+	key1 := dh.DHKey(x1, big.NewInt(int64(sendSeed)))
+	key2 := dh.DHKey(x2, big.NewInt(int64(receiveSeed)))
+	encoder, _ := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, key1)))
+	decoder, _ := rc4.NewCipher([]byte(fmt.Sprintf("%v%v", handshakeSalt, key2)))
+	sendKey := sha256.Sum256([]byte(fmt.Sprintf("%v%v", handshakeMACSalt, key1)))
+	recvKey := sha256.Sum256([]byte(fmt.Sprintf("%v%v", handshakeMACSalt, key2)))
+	rc4Cipher := session.NewRC4Cipher(encoder, decoder)
+	rc4Cipher.SetMACKeys(sendKey[:], recvKey[:])
+	rc4Cipher.EnableSequence()
+	testHandshakeCipher(t, sess, rc4Cipher)
+
+	f1, _ := rc4Cipher.Encrypt([]byte("f1"))
+	f1 = append([]byte{}, f1...)
+	f2, _ := rc4Cipher.Encrypt([]byte("f2"))
+	f2 = append([]byte{}, f2...)
+	f3, _ := rc4Cipher.Encrypt([]byte("f3"))
+	f3[len(f3)-1] ^= 0xff
+
+	testSequence(t, sess, [][]byte{f2, f1, f1, f2, f3}, []string{"", "f1", "", "f2", ""}, []error{session.ErrFrameOutOfOrder, nil, session.ErrReplayedFrame, nil, session.ErrDecryptFailed})
+	if sess.Replayed() != 1 {
+		t.Fatalf("expected 1 replayed frame, got %d", sess.Replayed())
+	}
+
+	// AEAD同样只接受下一个序号的帧, 跳过序号的帧需要先通过认证
+	sess = store.NewClient(nil, "", time.Second, time.Second, 0)
+	defer store.RemoveAndExit(sess.ID())
+
+	priv := make([]byte, curve25519.ScalarSize)
+	rand.Read(priv)
+	pub, _ := curve25519.X25519(priv, curve25519.Basepoint)
+	resp, err = handleHandshake(sess, &proto.RequestBytes{Ver: session.CipherAESGCM, Cmd: proto.InternalHandshake, SubCmd: 1, SeqID: 1, Content: append(pub, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, _ := curve25519.X25519(priv, resp.Body()[:curve25519.ScalarSize])
+	salt := append(append([]byte{}, pub...), resp.Body()[:curve25519.ScalarSize]...)
+	encryptKey, _ := deriveKey(secret, salt, handshakeInfoC2S)
+	decryptKey, _ := deriveKey(secret, salt, handshakeInfoS2C)
+	aead, _ := session.NewAEADCipher(session.CipherAESGCM, encryptKey, decryptKey)
+	aead.EnableSequence()
+	testHandshakeCipher(t, sess, aead)
+
+	frames := make([][]byte, 4)
+	for i := range frames {
+		frames[i], _ = aead.Encrypt([]byte(fmt.Sprint(i)))
+	}
+	frames[3][len(frames[3])-1] ^= 0xff
+
+	testSequence(t, sess, [][]byte{frames[1], frames[3], frames[0], frames[0], frames[1], frames[2]}, []string{"", "", "0", "", "1", "2"}, []error{session.ErrFrameOutOfOrder, session.ErrDecryptFailed, nil, session.ErrReplayedFrame, nil, nil})
+}
+
+// testSequence 按顺序解密frames, 确认结果
+func testSequence(t *testing.T, sess *session.Client, frames [][]byte, plains []string, errs []error) {
+	for i, frame := range frames {
+		m, err := sess.DecodeFrame(append([]byte{}, frame...))
+		if err != errs[i] || (err == nil && string(m) != plains[i]) {
+			t.Fatalf("frame %d: expected %q, %v, got %q, %v", i, plains[i], errs[i], m, err)
+		}
+	}
+}
+
+// testHandshakeCipher 确认客户端与服务器两个方向的密钥一致
+func testHandshakeCipher(t *testing.T, sess *session.Client, client session.Cipher) {
+	plain := []byte("balala")
+	frame, _ := client.Encrypt([]byte("balala"))
+	frame, err := sess.DecodeFrame(frame)
+	if err != nil || !reflect.DeepEqual(frame, plain) {
+		t.Fatalf("client to server key mismatch: %v", err)
+	}
+
+	frame, _ = sess.EncodeFrame([]byte("balala"))
+	frame, err = client.Decrypt(frame)
+	if err != nil || !reflect.DeepEqual(frame, plain) {
+		t.Fatalf("server to client key mismatch: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"net"
+	"time"
+
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/networks"
+	"github.com/doublemo/balala/cores/process"
+	"github.com/doublemo/balala/cores/services"
+	"github.com/go-kit/kit/log"
+	kitlog "github.com/go-kit/kit/log/level"
+)
+
+// makeKCPRuntimeActor kcp(udp)服务, 与socket使用相同的帧协议
+// 未关闭压缩时连接使用snappy流压缩
+func makeKCPRuntimeActor(serviceOpts *services.Options, conf *ConfigureOptions, store *session.Store, rt *router, logger log.Logger) *process.RuntimeActor {
+	kcpOpts := conf.Read().KCP
+	if kcpOpts == nil {
+		return nil
+	}
+
+	serviceOpts.Params["kcp"] = kcpOpts.Addr
+	config := kcpOpts.Config()
+	kcp := networks.NewKCP()
+	{
+		kcp.CallBack(func(conn net.Conn, exit chan struct{}) {
+			// 压缩, 超时, 信息大小与rpm限制使用最新的配置
+			o := kcpOpts
+			if m := conf.Read().KCP; m != nil {
+				o = m
+			}
+
+			if !o.NoComp {
+				conn = networks.NewKCPStream(conn)
+			}
+
+			sess := store.NewClient(conn, "", time.Duration(o.ReadDeadline)*time.Second, time.Duration(o.WriteDeadline)*time.Second, o.MaxMessageSize)
+			defer func() {
+				store.RemoveAndExit(sess.ID())
+			}()
+
+			socketLoop(sess, exit, o.RPMLimit, rt, logger)
+		})
+	}
+
+	return &process.RuntimeActor{
+		Exec: func() error {
+			logger.Log("transport", "kcp", "on", kcpOpts.Addr)
+			return kcp.Serve(config)
+		},
+		Interrupt: func(err error) {
+			if err != nil {
+				kitlog.Error(logger).Log("transport", "kcp", "error", err)
+			}
+		},
+
+		Close: func() {
+			logger.Log("transport", "kcp", "on", "shutdown")
+			kcp.Shutdown()
+		},
+
+		Restart: process.RestartOnFailure,
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/crypto/token"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/types"
+	kitlog "github.com/go-kit/kit/log/level"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrAlreadyAuthorized session已经认证
+	ErrAlreadyAuthorized = proto.NewError(proto.CodeInvalidRequest, "ErrAlreadyAuthorized")
+
+	// ErrInvalidToken 无效的token
+	ErrInvalidToken = proto.NewError(proto.CodeUnauthorized, "ErrInvalidToken")
+
+	// ErrTokenExpired token已过期
+	ErrTokenExpired = proto.NewError(proto.CodeUnauthorized, "ErrTokenExpired")
+
+	// ErrLoginElsewhere 用户在其它地方登录
+	ErrLoginElsewhere = errors.New("ErrLoginElsewhere")
+)
+
+// loginToken token.TK 加密的登录信息
+type loginToken struct {
+	// UserID 用户ID
+	UserID uint64
+
+	// ExpiresAt 过期时间 unix时间戳
+	ExpiresAt int64
+}
+
+// makeLoginHandler 客户端认证
+// 请求内容为token字符串, 支持token.TK加密的loginToken或者以ServiceSecurityKey签名的JWT,
+// JWT的Subject为用户ID.
+// 认证成功后session更换为新的id并设置为已认证, 响应内容为新的id.
+// 同一用户重复登录按opts.Login处理, 连接数按用户在集群中的所有连接计算:
+// 认证通过的连接登记到sss, reject策略下查询sss中的连接数,
+// kick与allow策略下通过sss通知所有代理服务器踢掉超出上限的最早的连接.
+// sss不可用时只按本机的连接处理
+func makeLoginHandler(store *session.Store, opts *Options, ss *sessionState) routeHandler {
+	var tk *token.TK
+	if opts.TokenKey != "" {
+		tk = token.NewTK([]byte(opts.TokenKey))
+	}
+
+	policy, max, reject := LoginPolicyKick, 1, false
+	if opts.Login != nil && opts.Login.Policy != "" {
+		policy = opts.Login.Policy
+	}
+
+	switch policy {
+	case LoginPolicyReject:
+		reject = true
+
+	case LoginPolicyAllow:
+		max = opts.Login.MaxDevices
+	}
+
+	jwtKey := []byte(opts.ServiceSecurityKey)
+	return func(ctx context.Context, sess *session.Client, req *proto.RequestBytes) (*proto.ResponseBytes, error) {
+		if sess.Flag()&session.FlagAuthorized != 0 {
+			return nil, ErrAlreadyAuthorized
+		}
+
+		var (
+			uid types.UID
+			err error
+		)
+
+		s := string(req.Body())
+		if strings.Count(s, ".") == 2 {
+			uid, err = verifyJWT(s, jwtKey)
+		} else {
+			uid, err = verifyTK(s, tk)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ss != nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, sessionStateTimeout)
+			defer cancel()
+		}
+
+		// 其它代理服务器上的连接计入上限
+		localMax := max
+		if reject && max > 0 && ss != nil {
+			sessions, err := ss.Sessions(ctx, uid)
+			if err != nil && err != ErrServiceUnavailable {
+				kitlog.Warn(ss.logger).Log("error", err, "login", uid.Uint64())
+			}
+
+			for _, m := range sessions {
+				if m.GetRemoteServID() != ss.machineID {
+					localMax--
+				}
+			}
+
+			if localMax < 1 {
+				return nil, session.ErrTooManySessions
+			}
+		}
+
+		evicted, err := store.Authorize(sess, uuid.NewV4().String(), uid, localMax, reject)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range evicted {
+			go c.Disconnect(session.DisconnectLoginElsewhere, ErrLoginElsewhere.Error())
+		}
+
+		if ss != nil {
+			sessions, err := ss.Register(ctx, uid, sess)
+			if err != nil && err != ErrServiceUnavailable {
+				kitlog.Warn(ss.logger).Log("error", err, "login", uid.Uint64())
+			}
+
+			if !reject && max > 0 && len(sessions) > max {
+				ss.Kick(uid, sess.ID(), sessions[len(sessions)-max].GetVersion())
+			}
+		}
+
+		return &proto.ResponseBytes{
+			Ver:     req.V(),
+			Cmd:     req.Command(),
+			SubCmd:  req.SubCommand(),
+			SeqID:   req.SID(),
+			Content: []byte(sess.ID()),
+		}, nil
+	}
+}
+
+// verifyTK 验证token.TK加密的token
+func verifyTK(s string, tk *token.TK) (types.UID, error) {
+	if tk == nil {
+		return 0, ErrInvalidToken
+	}
+
+	var o loginToken
+	if err := tk.Decrypt(s, &o); err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	if o.UserID < 1 {
+		return 0, ErrInvalidToken
+	}
+
+	if o.ExpiresAt > 0 && o.ExpiresAt < time.Now().Unix() {
+		return 0, ErrTokenExpired
+	}
+
+	return types.UID(o.UserID), nil
+}
+
+// verifyJWT 验证JWT
+func verifyJWT(s string, key []byte) (types.UID, error) {
+	if len(key) < 1 {
+		return 0, ErrInvalidToken
+	}
+
+	claims := jwtgo.StandardClaims{}
+	_, err := jwtgo.ParseWithClaims(s, &claims, func(token *jwtgo.Token) (interface{}, error) {
+		if token.Method != jwtgo.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+
+		return key, nil
+	})
+
+	if err != nil {
+		if e, ok := err.(*jwtgo.ValidationError); ok && e.Errors&jwtgo.ValidationErrorExpired != 0 {
+			return 0, ErrTokenExpired
+		}
+
+		return 0, ErrInvalidToken
+	}
+
+	uid, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || uid < 1 {
+		return 0, ErrInvalidToken
+	}
+
+	return types.UID(uid), nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/crypto/token"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/types"
+	"github.com/doublemo/balala/sss/proto/pb"
+	sssservice "github.com/doublemo/balala/sss/service"
+	"github.com/go-kit/kit/log"
+)
+
+func TestLogin(t *testing.T) {
+	opts := &Options{ServiceSecurityKey: "balala", TokenKey: "0123456789abcdef"}
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	login := makeLoginHandler(store, opts, nil)
+
+	jwtToken, _ := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{
+		Subject:   "10001",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(opts.ServiceSecurityKey))
+
+	expiredToken, _ := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{
+		Subject:   "10001",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(opts.ServiceSecurityKey))
+
+	tkToken, _ := token.NewTK([]byte(opts.TokenKey)).Encrypt(&loginToken{UserID: 10002, ExpiresAt: time.Now().Add(time.Minute).Unix()})
+
+	cases := []struct {
+		token string
+		uid   types.UID
+		err   error
+	}{
+		{jwtToken, 10001, nil},
+		{tkToken, 10002, nil},
+		{expiredToken, 0, ErrTokenExpired},
+		{"a.b.c", 0, ErrInvalidToken},
+		{"invalid", 0, ErrInvalidToken},
+	}
+
+	for _, c := range cases {
+		sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+		oldID := sess.ID()
+		req := &proto.RequestBytes{Ver: 1, Cmd: proto.InternalLogin, SubCmd: 1, SeqID: 1, Content: []byte(c.token)}
+		resp, err := login(context.Background(), sess, req)
+		if err != c.err {
+			t.Fatalf("token %q: expected %v, got %v", c.token, c.err, err)
+		}
+
+		if err != nil {
+			store.RemoveAndExit(sess.ID())
+			continue
+		}
+
+		if sess.Flag()&session.FlagAuthorized == 0 {
+			t.Fatal("FlagAuthorized is not set")
+		}
+
+		if m, _ := sess.Param("UserID"); m != c.uid {
+			t.Fatalf("expected UserID %v, got %v", c.uid, m)
+		}
+
+		if string(resp.Body()) != sess.ID() || store.Get(oldID) != nil || store.Get(sess.ID()) != sess {
+			t.Fatal("session is not re-keyed")
+		}
+
+		if _, err := login(context.Background(), sess, req); err != ErrAlreadyAuthorized {
+			t.Fatalf("expected ErrAlreadyAuthorized, got %v", err)
+		}
+
+		store.RemoveAndExit(sess.ID())
+	}
+}
+
+func TestLoginPolicy(t *testing.T) {
+	cases := []struct {
+		login  *LoginOptions
+		online int
+		err    error
+	}{
+		{nil, 1, nil},
+		{&LoginOptions{Policy: LoginPolicyReject}, 1, session.ErrTooManySessions},
+		{&LoginOptions{Policy: LoginPolicyAllow, MaxDevices: 2}, 2, nil},
+	}
+
+	for _, c := range cases {
+		opts := &Options{ServiceSecurityKey: "balala", Login: c.login}
+		store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+		login := makeLoginHandler(store, opts, nil)
+		jwtToken, _ := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{Subject: "10001"}).SignedString([]byte(opts.ServiceSecurityKey))
+
+		var err error
+		for i := 0; i < 3; i++ {
+			sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+			req := &proto.RequestBytes{Ver: 1, Cmd: proto.InternalLogin, SubCmd: 1, SeqID: 1, Content: []byte(jwtToken)}
+			if _, err = login(context.Background(), sess, req); err != nil {
+				store.RemoveAndExit(sess.ID())
+			}
+		}
+
+		if err != c.err {
+			t.Fatalf("policy %v: expected %v, got %v", c.login, c.err, err)
+		}
+
+		if n := len(store.GetByUserID(10001)); n != c.online {
+			t.Fatalf("policy %v: expected %d sessions, got %d", c.login, c.online, n)
+		}
+	}
+}
+
+// clusterState 模拟sss, 保存登记的连接, 广播的事件发送到events
+type clusterState struct {
+	sssservice.GRPC
+	sessions []*pb.SessionStateServerAPI_NewRequest
+	version  int64
+	events   chan *pb.SessionStateServerAPI_Event
+	mutex    sync.Mutex
+}
+
+func (c *clusterState) New(_ context.Context, in *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.version++
+	in.Version = c.version
+	c.sessions = append(c.sessions, in)
+	return &pb.SessionStateServerAPI_Nil{}, nil
+}
+
+func (c *clusterState) Remove(_ context.Context, in *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, m := range c.sessions {
+		if m.GetClientID() == in.GetClientID() {
+			c.sessions = append(c.sessions[:i], c.sessions[i+1:]...)
+			break
+		}
+	}
+
+	c.events <- &pb.SessionStateServerAPI_Event{Action: pb.EventSessionRemove, ClientID: in.GetClientID()}
+	return &pb.SessionStateServerAPI_Nil{}, nil
+}
+
+func (c *clusterState) FindByParam(_ context.Context, in *pb.SessionStateServerAPI_FindByParamRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// 倒序返回, 由调用者按创建时间戳排序
+	resp := &pb.SessionStateServerAPI_ListResponse{}
+	for i := len(c.sessions) - 1; i >= 0; i-- {
+		resp.Sessions = append(resp.Sessions, c.sessions[i])
+	}
+	return resp, nil
+}
+
+func (c *clusterState) Broadcast(_ context.Context, in *pb.SessionStateServerAPI_BroadcastRequest) (*pb.SessionStateServerAPI_BroadcastResponse, error) {
+	for _, action := range in.GetActions() {
+		c.events <- action
+	}
+	return &pb.SessionStateServerAPI_BroadcastResponse{}, nil
+}
+
+func TestLoginCluster(t *testing.T) {
+	cases := []struct {
+		login  *LoginOptions
+		remote int
+		err    error
+		before int64
+	}{
+		{nil, 2, nil, 3},
+		{&LoginOptions{Policy: LoginPolicyReject}, 1, session.ErrTooManySessions, 0},
+		{&LoginOptions{Policy: LoginPolicyAllow, MaxDevices: 2}, 2, nil, 2},
+		{&LoginOptions{Policy: LoginPolicyAllow, MaxDevices: 3}, 2, nil, 0},
+	}
+
+	for _, c := range cases {
+		opts := &Options{ServiceSecurityKey: "balala", Login: c.login}
+		store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+		cluster := &clusterState{events: make(chan *pb.SessionStateServerAPI_Event, 4)}
+		for i := 0; i < c.remote; i++ {
+			cluster.New(context.Background(), &pb.SessionStateServerAPI_NewRequest{
+				ClientID:     fmt.Sprint("remote", i),
+				RemoteServID: "other",
+				Params:       []*pb.SessionStateServerAPI_Param{{Key: "UserID", Value: "10001"}},
+			})
+		}
+
+		ss := newSessionState("agent", "local", store, nil, log.NewNopLogger())
+		ss.cli = cluster
+		store.OnRemove(ss.Unregister)
+
+		login := makeLoginHandler(store, opts, ss)
+		jwtToken, _ := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{Subject: "10001"}).SignedString([]byte(opts.ServiceSecurityKey))
+		sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+		req := &proto.RequestBytes{Ver: 1, Cmd: proto.InternalLogin, SubCmd: 1, SeqID: 1, Content: []byte(jwtToken)}
+		if _, err := login(context.Background(), sess, req); err != c.err {
+			t.Fatalf("policy %v: expected %v, got %v", c.login, c.err, err)
+		}
+
+		if c.err != nil {
+			if len(store.GetByUserID(10001)) != 0 || len(cluster.sessions) != c.remote {
+				t.Fatalf("policy %v: rejected session registered", c.login)
+			}
+
+			store.RemoveAndExit(sess.ID())
+			continue
+		}
+
+		if m, _ := sess.Param(sessionVersionParam); m != int64(c.remote+1) {
+			t.Fatalf("policy %v: expected version %d, got %v", c.login, c.remote+1, m)
+		}
+
+		if c.before > 0 {
+			var event kickEvent
+			action := <-cluster.events
+			if err := json.Unmarshal(action.GetBody(), &event); err != nil || action.GetClientID() != sess.ID() || event.Before != c.before {
+				t.Fatalf("policy %v: unexpected kick %v, %v", c.login, action, err)
+			}
+		}
+
+		// 断开后从sss中删除
+		sid := sess.ID()
+		store.RemoveAndExit(sid)
+		if action := <-cluster.events; action.GetAction() != pb.EventSessionRemove || action.GetClientID() != sid {
+			t.Fatalf("policy %v: unexpected event %v", c.login, action)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"os"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/doublemo/balala/cores/alias"
@@ -61,6 +62,10 @@ type SocketOptions struct {
 	// WriteBufferSize 写入缓存大小 32767
 	WriteBufferSize int `alias:"writebuffersize" default:"32767"`
 
+	// MaxMessageSize 每条信息最大数据大小, 包括分片重组后的大小
+	// 超过65535需要客户端协商4字节或varint长度头
+	MaxMessageSize int64 `alias:"maxmessagesize" default:"1048576"`
+
 	// ReadDeadline 读取超时
 	ReadDeadline int `alias:"readdeadline" default:"310"`
 
@@ -77,6 +82,7 @@ func (o *SocketOptions) Clone() *SocketOptions {
 		Addr:            o.Addr,
 		ReadBufferSize:  o.ReadBufferSize,
 		WriteBufferSize: o.WriteBufferSize,
+		MaxMessageSize:  o.MaxMessageSize,
 		ReadDeadline:    o.ReadDeadline,
 		WriteDeadline:   o.WriteDeadline,
 		RPMLimit:        o.RPMLimit,
@@ -157,6 +163,124 @@ func (o *WebSocketOptions) Clone() *WebSocketOptions {
 	}
 }
 
+// KCPOptions kcp参数
+type KCPOptions struct {
+	// Addr 监听地址
+	Addr string `alias:"addr" default:":9094"`
+
+	// Key 通信加密密钥
+	Key string `alias:"key"`
+
+	// Crypt aes, aes-128, aes-192, salsa20, blowfish, twofish, cast5, 3des, tea, xtea, xor, sm4, none
+	Crypt string `alias:"crypt" default:"aes"`
+
+	// Mode fast3, fast2, fast, normal, manual
+	Mode string `alias:"mode" default:"fast"`
+
+	// NoDelay manual模式 是否启用nodelay 0:关闭 1:启用
+	NoDelay int `alias:"nodelay"`
+
+	// Interval manual模式 内部刷新间隔(毫秒) 10-5000
+	Interval int `alias:"interval" default:"40"`
+
+	// Resend manual模式 快速重传的跨越次数, 0关闭快速重传
+	Resend int `alias:"resend" default:"2"`
+
+	// NoCongestion manual模式 是否关闭拥塞控制 0:启用 1:关闭
+	NoCongestion int `alias:"nc" default:"1"`
+
+	// MTU UDP包最大传输单元
+	MTU int `alias:"mtu" default:"1350"`
+
+	// SndWnd 发送窗口大小(包数量)
+	SndWnd int `alias:"sndwnd" default:"1024"`
+
+	// RcvWnd 接收窗口大小(包数量)
+	RcvWnd int `alias:"rcvwnd" default:"1024"`
+
+	// DataShard reed-solomon 纠错数据分片
+	DataShard int `alias:"datashard" default:"10"`
+
+	// ParityShard reed-solomon 纠错校验分片
+	ParityShard int `alias:"parityshard" default:"3"`
+
+	// DSCP (6bit)
+	DSCP int `alias:"dscp"`
+
+	// NoComp 关闭snappy压缩
+	NoComp bool `alias:"nocomp" default:"false"`
+
+	// AckNodelay 收到包后立即发送ack
+	AckNodelay bool `alias:"acknodelay" default:"false"`
+
+	// SockBuf socket缓存大小
+	SockBuf int `alias:"sockbuf" default:"4194304"`
+
+	// MaxMessageSize 每条信息最大数据大小, 包括分片重组后的大小
+	// 超过65535需要客户端协商4字节或varint长度头
+	MaxMessageSize int64 `alias:"maxmessagesize" default:"1048576"`
+
+	// ReadDeadline 读取超时
+	ReadDeadline int `alias:"readdeadline" default:"310"`
+
+	// WriteDeadline 写入超时
+	WriteDeadline int `alias:"writedeadline"`
+
+	// RPMLimit per connection rpm limit
+	RPMLimit int `alias:"rpm" default:"200"`
+}
+
+// Clone KCPOptions
+func (o *KCPOptions) Clone() *KCPOptions {
+	return &KCPOptions{
+		Addr:           o.Addr,
+		Key:            o.Key,
+		Crypt:          o.Crypt,
+		Mode:           o.Mode,
+		NoDelay:        o.NoDelay,
+		Interval:       o.Interval,
+		Resend:         o.Resend,
+		NoCongestion:   o.NoCongestion,
+		MTU:            o.MTU,
+		SndWnd:         o.SndWnd,
+		RcvWnd:         o.RcvWnd,
+		DataShard:      o.DataShard,
+		ParityShard:    o.ParityShard,
+		DSCP:           o.DSCP,
+		NoComp:         o.NoComp,
+		AckNodelay:     o.AckNodelay,
+		SockBuf:        o.SockBuf,
+		MaxMessageSize: o.MaxMessageSize,
+		ReadDeadline:   o.ReadDeadline,
+		WriteDeadline:  o.WriteDeadline,
+		RPMLimit:       o.RPMLimit,
+	}
+}
+
+// Config 转换为networks.KCPConfig
+func (o *KCPOptions) Config() *networks.KCPConfig {
+	return &networks.KCPConfig{
+		Addr:         o.Addr,
+		Key:          o.Key,
+		Crypt:        o.Crypt,
+		Mode:         o.Mode,
+		NoDelay:      o.NoDelay,
+		Interval:     o.Interval,
+		Resend:       o.Resend,
+		NoCongestion: o.NoCongestion,
+		MTU:          o.MTU,
+		SndWnd:       o.SndWnd,
+		RcvWnd:       o.RcvWnd,
+		DataShard:    o.DataShard,
+		ParityShard:  o.ParityShard,
+		DSCP:         o.DSCP,
+		NoComp:       o.NoComp,
+		AckNodelay:   o.AckNodelay,
+		SockBuf:      o.SockBuf,
+		ReadDeadline: time.Duration(o.ReadDeadline) * time.Second,
+	}
+}
+
 // ETCDOptions etcd参数
 type ETCDOptions struct {
 	// Address etcd 服务器地址
@@ -202,12 +326,50 @@ func (o *ETCDOptions) Clone() *ETCDOptions {
 	}
 }
 
+// 同一用户重复登录策略
+const (
+	// LoginPolicyKick 踢掉之前的连接, 包括其它代理服务器上的连接
+	LoginPolicyKick = "kick"
+
+	// LoginPolicyReject 拒绝新的登录
+	LoginPolicyReject = "reject"
+
+	// LoginPolicyAllow 允许MaxDevices个连接同时在线, 超出时踢掉集群中最早的连接
+	LoginPolicyAllow = "allow"
+)
+
+// LoginOptions 客户端登录参数
+type LoginOptions struct {
+	// Policy 同一用户重复登录策略 kick, reject, allow
+	Policy string `alias:"policy" default:"kick"`
+
+	// MaxDevices allow策略下同一用户最多同时在线的连接数
+	MaxDevices int `alias:"maxdevices" default:"1"`
+}
+
+// Clone LoginOptions
+func (o *LoginOptions) Clone() *LoginOptions {
+	return &LoginOptions{
+		Policy:     o.Policy,
+		MaxDevices: o.MaxDevices,
+	}
+}
+
 // TracerOptions 请求运行追踪
 type TracerOptions struct {
 	// ReporterURL 追踪服务地址 eg:http://192.168.31.20:9411/api/v2/spans
 	ReporterURL string `alias:"reporterurl"`
 }
 
+// GetReporterURL 返回追踪服务地址, 没有配置追踪时返回空
+func (o *TracerOptions) GetReporterURL() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.ReporterURL
+}
+
 // Clone ETCDOptions
 func (o *TracerOptions) Clone() *TracerOptions {
 	return &TracerOptions{
@@ -245,12 +407,31 @@ type Options struct {
 	// WebSocket 将支持WebSocket服务
 	WebSocket *WebSocketOptions `alias:"websocket"`
 
+	// KCP 将支持kcp(udp)服务
+	KCP *KCPOptions `alias:"kcp"`
+
 	// ETCD etcd
 	ETCD *ETCDOptions `alias:"etcd"`
 
 	// ServiceSecurityKey JWT 服务之通信认证
 	ServiceSecurityKey string `alias:"servicesecuritykey"`
 
+	// TokenKey 客户端登录token的AES密钥,长度为16、24或32字节
+	// 为空时只接受JWT登录
+	TokenKey string `alias:"tokenkey"`
+
+	// Login 同一用户重复登录策略, 为空时使用kick
+	Login *LoginOptions `alias:"login"`
+
+	// CompressThreshold 协商了压缩的客户端, 数据帧达到该长度才压缩
+	CompressThreshold int `alias:"compressthreshold" default:"256"`
+
+	// RequestTimeout 转发到后端服务的请求超时时间(秒), 0为不限制
+	RequestTimeout int `alias:"requesttimeout" default:"10"`
+
+	// MaxInflight 每个连接最多同时等待响应的请求数量, 0为不限制
+	MaxInflight int `alias:"maxinflight" default:"32"`
+
 	// Tracer 请求运行追踪
 	Tracer *TracerOptions `alias:"tracer"`
 }
@@ -291,6 +472,10 @@ func (o *Options) Clone() *Options {
 		copy.WebSocket = o.WebSocket.Clone()
 	}
 
+	if o.KCP != nil {
+		copy.KCP = o.KCP.Clone()
+	}
+
 	if o.ETCD != nil {
 		copy.ETCD = o.ETCD.Clone()
 	}
@@ -299,7 +484,15 @@ func (o *Options) Clone() *Options {
 		copy.Tracer = o.Tracer.Clone()
 	}
 
+	if o.Login != nil {
+		copy.Login = o.Login.Clone()
+	}
+
 	copy.ServiceSecurityKey = o.ServiceSecurityKey
+	copy.TokenKey = o.TokenKey
+	copy.CompressThreshold = o.CompressThreshold
+	copy.RequestTimeout = o.RequestTimeout
+	copy.MaxInflight = o.MaxInflight
 	return &copy
 }
 
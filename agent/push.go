@@ -0,0 +1,79 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/proto/pb"
+	"github.com/doublemo/balala/cores/types"
+	grpcproto "github.com/golang/protobuf/proto"
+)
+
+var (
+	// ErrSessionNotFound 推送目标session不存在
+	ErrSessionNotFound = proto.NewError(proto.CodeInvalidRequest, "ErrSessionNotFound")
+)
+
+// push 将后端服务推送的信息发送到客户端
+// 目标由Header.SID指定, Header.SID为空时发送到Header.UserID的所有session.
+// 客户端命令为Command, 子命令为Header.Method, 版本号为Header.V.
+// 没有完成密钥交换的session不接收推送, 按UserID推送时还需要已经授权
+func push(store *session.Store, frame *pb.Request) *pb.Response {
+	header := frame.GetHeader()
+	if header == nil {
+		return newPushBad(frame, ErrSessionNotFound)
+	}
+
+	var clients []*session.Client
+	if sid := header.GetSID(); sid != "" {
+		if sess := store.Get(sid); sess != nil && sess.Flag()&session.FlagEncrypt != 0 {
+			clients = append(clients, sess)
+		}
+	} else if uid := header.GetUserID(); uid > 0 {
+		for _, sess := range store.GetByUserID(types.UID(uid)) {
+			if sess.Flag()&(session.FlagEncrypt|session.FlagAuthorized) == session.FlagEncrypt|session.FlagAuthorized {
+				clients = append(clients, sess)
+			}
+		}
+	}
+
+	if len(clients) < 1 {
+		return newPushBad(frame, ErrSessionNotFound)
+	}
+
+	w := &proto.ResponseBytes{
+		Ver:     int8(header.GetV()),
+		Cmd:     proto.Command(frame.GetCommand()),
+		SubCmd:  proto.Command(header.GetMethod()),
+		SeqID:   proto.PushSeqID,
+		Content: frame.GetBody(),
+	}
+
+	b, err := w.Marshal()
+	if err != nil {
+		return newPushBad(frame, err)
+	}
+
+	delivered := 0
+	for _, sess := range clients {
+		// Send将数据复制到各自的发送缓冲区, 加密不会影响b
+		if err = sess.Send(b); err == nil {
+			delivered++
+		}
+	}
+
+	if delivered < 1 {
+		return newPushBad(frame, err)
+	}
+
+	return &pb.Response{Command: frame.GetCommand()}
+}
+
+// newPushBad 推送失败的回执
+func newPushBad(frame *pb.Request, err error) *pb.Response {
+	bad := proto.FromError(err).Bad(proto.Command(frame.GetCommand()), proto.Command(frame.GetHeader().GetMethod()))
+
+	body, _ := grpcproto.Marshal(bad)
+	return &pb.Response{Command: int32(proto.InternalBad), Body: body}
+}
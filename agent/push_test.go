@@ -0,0 +1,119 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/proto/pb"
+	"github.com/doublemo/balala/cores/types"
+	"github.com/go-kit/kit/log"
+	grpcproto "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPush(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	sess := store.NewClient(nil, "", time.Second, time.Second, 0)
+	store.Authorize(sess, "balala", types.UID(10001), 0, false)
+	defer store.RemoveAndExit(sess.ID())
+
+	// 完成密钥交换之前不推送
+	pending := store.NewClient(nil, "", time.Second, time.Second, 0)
+	store.Authorize(pending, "pending", types.UID(10003), 0, false)
+	defer store.RemoveAndExit(pending.ID())
+
+	for _, c := range []*pb.Header{{SID: sess.ID()}, {UserID: 10001}, {SID: pending.ID()}, {UserID: 10003}} {
+		resp := push(store, &pb.Request{Header: c, Command: 30000})
+		if proto.Command(resp.GetCommand()) != proto.InternalBad {
+			t.Fatalf("push %v: expected InternalBad before key exchange, got %v", c, resp)
+		}
+	}
+
+	sess.Flag(sess.Flag() | session.FlagEncrypt)
+	pending.Flag(pending.Flag() | session.FlagKeyexcg)
+
+	cases := []struct {
+		header *pb.Header
+		ok     bool
+	}{
+		{&pb.Header{SID: sess.ID(), V: 1, Method: 1}, true},
+		{&pb.Header{UserID: 10001, V: 1, Method: 1}, true},
+		{&pb.Header{SID: "none", V: 1, Method: 1}, false},
+		{&pb.Header{UserID: 10002, V: 1, Method: 1}, false},
+		{&pb.Header{SID: pending.ID(), V: 1, Method: 1}, false},
+		{&pb.Header{UserID: 10003, V: 1, Method: 1}, false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		resp := push(store, &pb.Request{Header: c.header, Command: 30000, Body: []byte("balala")})
+		if c.ok {
+			if resp.GetCommand() != 30000 {
+				t.Fatalf("push %v: unexpected response %v", c.header, resp)
+			}
+
+			continue
+		}
+
+		if proto.Command(resp.GetCommand()) != proto.InternalBad {
+			t.Fatalf("push %v: expected InternalBad, got %v", c.header, resp)
+		}
+
+		var bad pb.Bad
+		if err := grpcproto.Unmarshal(resp.GetBody(), &bad); err != nil || bad.Message != ErrSessionNotFound.Error() {
+			t.Fatalf("push %v: unexpected bad %v, %v", c.header, bad, err)
+		}
+	}
+}
+
+// pushStream 按顺序返回请求, 之后返回io.EOF
+type pushStream struct {
+	grpc.ServerStream
+	frames []*pb.Request
+	acks   []*pb.Response
+}
+
+func (stream *pushStream) Context() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "test"))
+}
+
+func (stream *pushStream) Recv() (*pb.Request, error) {
+	if len(stream.frames) < 1 {
+		return nil, io.EOF
+	}
+
+	frame := stream.frames[0]
+	stream.frames = stream.frames[1:]
+	return frame, nil
+}
+
+func (stream *pushStream) Send(resp *pb.Response) error {
+	stream.acks = append(stream.acks, resp)
+	return nil
+}
+
+func TestStreamDrainOnEOF(t *testing.T) {
+	store := session.NewStore(log.NewLogfmtLogger(os.Stderr))
+	s := newBaseGRPCServer(store, log.NewNopLogger())
+	stream := &pushStream{}
+	for i := 0; i < 100; i++ {
+		stream.frames = append(stream.frames, &pb.Request{Header: &pb.Header{SID: "none"}, Command: 30000})
+	}
+
+	// 对方关闭发送前的请求都要回复回执
+	if err := s.Stream(context.Background(), stream); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stream.acks) != 100 {
+		t.Fatalf("expected 100 acks, got %d", len(stream.acks))
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/doublemo/balala/agent/service"
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/agent/transport"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/proto/pb"
+	"github.com/doublemo/balala/cores/types"
+	"github.com/doublemo/balala/internal/serviceid"
+	"github.com/go-kit/kit/log"
+	grpcproto "github.com/golang/protobuf/proto"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+var (
+	// ErrServiceUnavailable 没有可用的服务节点
+	ErrServiceUnavailable = proto.NewError(proto.CodeServiceUnavailable, "ErrServiceUnavailable").WithRetryable()
+)
+
+// route 命令路由
+// 命令号在[min, max]区间内的请求将转发到serviceID对应的服务
+type route struct {
+	min       proto.Command
+	max       proto.Command
+	serviceID int32
+}
+
+// routeHandler 由代理服务器本地处理的命令
+type routeHandler func(ctx context.Context, sess *session.Client, req *proto.RequestBytes) (*proto.ResponseBytes, error)
+
+// router 客户端请求路由
+type router struct {
+	// routes 路由表,按min升序排列
+	routes []route
+
+	// handlers 本地处理的命令, 优先于路由表
+	handlers map[proto.Command]routeHandler
+
+	// clients 内部服务连接 key为服务地址
+	clients map[string]*routerClient
+
+	// jwtToken 内部服务通信认证
+	jwtToken []byte
+
+	// logger 日志
+	logger log.Logger
+
+	// mutex lock
+	mutex sync.RWMutex
+}
+
+// routerClient 内部服务连接
+type routerClient struct {
+	conn *grpc.ClientConn
+	cli  service.GRPC
+}
+
+// Handle 注册命令路由
+func (r *router) Handle(min, max proto.Command, serviceID int32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.routes = append(r.routes, route{min: min, max: max, serviceID: serviceID})
+	sort.Slice(r.routes, func(i, j int) bool {
+		return r.routes[i].min < r.routes[j].min
+	})
+}
+
+// HandleFunc 注册由代理服务器本地处理的命令
+func (r *router) HandleFunc(cmd proto.Command, fn routeHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers[cmd] = fn
+}
+
+// Local 是否为本地处理的命令
+func (r *router) Local(cmd proto.Command) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	_, ok := r.handlers[cmd]
+	return ok
+}
+
+// Lookup 根据命令号查找服务ID
+func (r *router) Lookup(cmd proto.Command) (int32, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	idx := sort.Search(len(r.routes), func(i int) bool {
+		return r.routes[i].max >= cmd
+	})
+
+	if idx < len(r.routes) && r.routes[idx].min <= cmd {
+		return r.routes[idx].serviceID, true
+	}
+
+	return 0, false
+}
+
+// Call 处理客户端请求
+// 本地命令直接处理, 其它命令按路由表转发到后端服务
+func (r *router) Call(ctx context.Context, sess *session.Client, req *proto.RequestBytes) (*proto.ResponseBytes, error) {
+	r.mutex.RLock()
+	fn, ok := r.handlers[req.Command()]
+	r.mutex.RUnlock()
+	if ok {
+		return fn(ctx, sess, req)
+	}
+
+	serviceID, ok := r.Lookup(req.Command())
+	if !ok {
+		return nil, proto.ErrInvalidCommand
+	}
+
+	instance, ok := service.Caches.RndOnce(serviceID)
+	if !ok {
+		return nil, ErrServiceUnavailable
+	}
+
+	cli, err := r.client(instance.IP + ":" + instance.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &pb.Header{
+		SID:    sess.ID(),
+		V:      int32(req.V()),
+		From:   serviceid.AgentID,
+		Method: int32(req.SubCommand()),
+	}
+
+	if m, ok := sess.Param("UserID"); ok {
+		if uid, ok := m.(types.UID); ok {
+			header.UserID = uid.Uint64()
+		}
+	}
+
+	if m, ok := sess.Param("RemoteAddr"); ok {
+		header.FromAddress, _ = m.(string)
+	}
+
+	resp, err := cli.Call(ctx, &pb.Request{
+		Header:  header,
+		Command: int32(req.Command()),
+		Body:    req.Body(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponseBytes(req, resp), nil
+}
+
+// client 获取服务连接, 同一地址的连接将被复用
+func (r *router) client(addr string) (service.GRPC, error) {
+	r.mutex.RLock()
+	c, ok := r.clients[addr]
+	r.mutex.RUnlock()
+	if ok {
+		return c.cli, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if c, ok := r.clients[addr]; ok {
+		return c.cli, nil
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	c = &routerClient{
+		conn: conn,
+		cli:  transport.NewGRPCClient(conn, stdopentracing.GlobalTracer(), nil, r.jwtToken, r.logger),
+	}
+
+	r.clients[addr] = c
+	return c.cli, nil
+}
+
+// Prune 关闭已经不在服务信息缓存中的内部服务连接
+// 服务信息发生变化时调用, 已经下线的服务不再保持连接
+func (r *router) Prune() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	alive := make(map[string]bool)
+	for _, rt := range r.routes {
+		for _, o := range service.Caches.Get(rt.serviceID) {
+			alive[o.IP+":"+o.Port] = true
+		}
+	}
+
+	for addr, c := range r.clients {
+		if alive[addr] {
+			continue
+		}
+
+		c.conn.Close()
+		delete(r.clients, addr)
+	}
+}
+
+// Close 关闭所有内部服务连接
+func (r *router) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for addr, c := range r.clients {
+		c.conn.Close()
+		delete(r.clients, addr)
+	}
+}
+
+// newResponseBytes 将后端服务的响应转换为客户端响应
+func newResponseBytes(req *proto.RequestBytes, resp *pb.Response) *proto.ResponseBytes {
+	w := &proto.ResponseBytes{
+		Ver:     req.V(),
+		Cmd:     req.Command(),
+		SubCmd:  req.SubCommand(),
+		SeqID:   req.SID(),
+		Content: resp.GetBody(),
+	}
+
+	// 后端服务返回的错误保留原始子命令号, 由MarshalTo重新生成pb.Bad
+	// 这样错误码、国际化key与重试标记都能完整的传递给客户端
+	if proto.Command(resp.GetCommand()) == proto.InternalBad {
+		bad := &pb.Bad{}
+		if err := grpcproto.Unmarshal(resp.GetBody(), bad); err != nil {
+			w.Err = proto.NewError(proto.CodeUnknown, "")
+		} else {
+			w.Err = proto.NewErrorFromBad(bad)
+		}
+
+		w.Content = nil
+		return w
+	}
+
+	if resp.GetCommand() > 0 {
+		w.SubCmd = proto.Command(resp.GetCommand())
+	}
+
+	return w
+}
+
+// newRouter 创建路由
+func newRouter(jwtToken []byte, logger log.Logger) *router {
+	return &router{
+		routes:   make([]route, 0),
+		handlers: make(map[proto.Command]routeHandler),
+		clients:  make(map[string]*routerClient),
+		jwtToken: jwtToken,
+		logger:   logger,
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"os"
+	"testing"
+
+	"github.com/doublemo/balala/agent/service"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/proto/pb"
+	"github.com/doublemo/balala/cores/services"
+	"github.com/go-kit/kit/log"
+	grpcproto "github.com/golang/protobuf/proto"
+)
+
+func TestRouterLookup(t *testing.T) {
+	r := newRouter([]byte("balala"), log.NewLogfmtLogger(os.Stderr))
+	r.Handle(30000, 30999, 3)
+	r.Handle(100, 199, 2)
+	r.Handle(200, 200, 4)
+
+	cases := []struct {
+		cmd       proto.Command
+		serviceID int32
+		ok        bool
+	}{
+		{99, 0, false},
+		{100, 2, true},
+		{150, 2, true},
+		{199, 2, true},
+		{200, 4, true},
+		{201, 0, false},
+		{30000, 3, true},
+		{30999, 3, true},
+		{31000, 0, false},
+	}
+
+	for _, c := range cases {
+		serviceID, ok := r.Lookup(c.cmd)
+		if ok != c.ok || serviceID != c.serviceID {
+			t.Fatalf("Lookup(%d) = %d, %v; expected %d, %v", c.cmd, serviceID, ok, c.serviceID, c.ok)
+		}
+	}
+}
+
+func TestNewResponseBytesBad(t *testing.T) {
+	req := &proto.RequestBytes{Ver: 1, Cmd: 30000, SubCmd: 2, SeqID: 10}
+	body, _ := grpcproto.Marshal(proto.NewError(proto.CodeCustom, "ErrBusy").WithKey("error.busy").WithRetryable().Bad(30000, 1))
+
+	b, err := newResponseBytes(req, &pb.Response{Command: int32(proto.InternalBad), Body: body}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &proto.ResponseBytes{}
+	if err := resp.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+
+	var bad pb.Bad
+	if err := grpcproto.Unmarshal(resp.Content, &bad); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.SubCmd != proto.InternalBad || bad.SubCommand != 2 || bad.Code != proto.CodeCustom || bad.Key != "error.busy" || !bad.Retryable {
+		t.Fatalf("unexpected response %+v, %+v", resp, bad)
+	}
+}
+
+func TestRouterPrune(t *testing.T) {
+	defer service.Caches.Reset()
+
+	r := newRouter([]byte("balala"), log.NewLogfmtLogger(os.Stderr))
+	defer r.Close()
+
+	r.Handle(100, 199, 2)
+	service.Caches.Reset()
+	service.Caches.Store(&services.Options{ID: 2, IP: "127.0.0.1", Port: "9001", Priority: 1})
+	for _, addr := range []string{"127.0.0.1:9001", "127.0.0.1:9002"} {
+		if _, err := r.client(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 已经下线的服务连接被关闭
+	r.Prune()
+	if len(r.clients) != 1 || r.clients["127.0.0.1:9001"] == nil {
+		t.Fatalf("unexpected clients %v", r.clients)
+	}
+}
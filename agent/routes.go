@@ -0,0 +1,19 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/internal/serviceid"
+)
+
+// makeRoutes 定义客户端命令路由
+// 命令号区间不能重叠
+func makeRoutes(r *router, store *session.Store, ss *sessionState, opts *Options) {
+	// 客户端认证
+	r.HandleFunc(proto.InternalLogin, makeLoginHandler(store, opts, ss))
+
+	// 机器人服务 30000 - 30999
+	r.Handle(30000, 30999, serviceid.RobotID)
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rc4"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// 会话加密版本
+// 由密钥交换请求中的RequestBytes.Ver协商
+const (
+	// CipherRC4 DH + RC4, 兼容旧客户端
+	CipherRC4 int8 = 1
+
+	// CipherChaCha20Poly1305 X25519 + ChaCha20-Poly1305
+	CipherChaCha20Poly1305 int8 = 2
+
+	// CipherAESGCM X25519 + AES-256-GCM
+	CipherAESGCM int8 = 3
+)
+
+var (
+	// ErrInvalidCipher 不支持的加密版本
+	ErrInvalidCipher = errors.New("ErrInvalidCipher")
+
+	// ErrDecryptFailed 解密失败,数据被篡改或者顺序错误
+	ErrDecryptFailed = errors.New("ErrDecryptFailed")
+
+	// ErrReplayedFrame 已经接收过的数据帧, 丢弃即可
+	ErrReplayedFrame = errors.New("ErrReplayedFrame")
+
+	// ErrFrameOutOfOrder 帧序号跳过了未接收的帧, 传输层保证顺序, 出现时视为违反协议
+	ErrFrameOutOfOrder = errors.New("ErrFrameOutOfOrder")
+)
+
+const (
+	// seqSize 帧序号的长度
+	seqSize = 8
+
+	// macSize rc4帧认证码的长度, 截取HMAC-SHA256的前16字节
+	macSize = 16
+)
+
+// Cipher 会话加密
+type Cipher interface {
+	// Encrypt 加密发往客户端的数据
+	Encrypt(frame []byte) ([]byte, error)
+
+	// Decrypt 解密来自客户端的数据
+	Decrypt(frame []byte) ([]byte, error)
+
+	// Overhead 加密后比原数据增加的长度
+	// 发送时预留足够的空间, 使加密可以在缓冲区内原地完成
+	Overhead() int
+}
+
+// SequencedCipher 支持帧序号的加密
+// 启用后每帧之前加入8字节的序号(大端, 从1开始), 序号与数据一起认证, 只接受下一个序号的帧.
+// 已经接收过的序号解密时返回ErrReplayedFrame, 跳过序号的帧认证通过后返回ErrFrameOutOfOrder
+type SequencedCipher interface {
+	Cipher
+
+	// EnableSequence 启用帧序号, 需要在开始加密之前调用
+	// 无法启用时返回false
+	EnableSequence() bool
+}
+
+// RC4Cipher rc4流加密
+// 启用帧序号后格式为 序号 + 密文 + HMAC-SHA256(序号 + 密文)的前16字节,
+type RC4Cipher struct {
+	encoder *rc4.Cipher
+	decoder *rc4.Cipher
+
+	// sendMAC, recvMAC 两个方向的帧认证, 设置了认证密钥才能启用帧序号
+	sendMAC hash.Hash
+	recvMAC hash.Hash
+
+	// sequenced 是否启用了帧序号
+	sequenced bool
+
+	// sendSeq 最后发送的帧序号
+	sendSeq uint64
+
+	// recvSeq 最后接收的帧序号
+	recvSeq uint64
+
+	// sendSum, recvSum 认证码缓存, 收发各在自己的协程内使用
+	sendSum []byte
+	recvSum []byte
+}
+
+// Encrypt 加密
+func (c *RC4Cipher) Encrypt(frame []byte) ([]byte, error) {
+	if !c.sequenced {
+		c.encoder.XORKeyStream(frame, frame)
+		return frame, nil
+	}
+
+	n := len(frame)
+	frame = growFrame(frame, c.Overhead())
+	copy(frame[seqSize:], frame[:n])
+
+	c.sendSeq++
+	binary.BigEndian.PutUint64(frame, c.sendSeq)
+	c.encoder.XORKeyStream(frame[seqSize:seqSize+n], frame[seqSize:seqSize+n])
+
+	c.sendMAC.Reset()
+	c.sendMAC.Write(frame[:seqSize+n])
+	c.sendSum = c.sendMAC.Sum(c.sendSum[:0])
+	copy(frame[seqSize+n:], c.sendSum[:macSize])
+	return frame, nil
+}
+
+// Decrypt 解密
+func (c *RC4Cipher) Decrypt(frame []byte) ([]byte, error) {
+	if !c.sequenced {
+		c.decoder.XORKeyStream(frame, frame)
+		return frame, nil
+	}
+
+	if len(frame) < seqSize+macSize {
+		return nil, ErrDecryptFailed
+	}
+
+	n := len(frame) - macSize
+	seq := binary.BigEndian.Uint64(frame)
+	if seq <= c.recvSeq {
+		return nil, ErrReplayedFrame
+	}
+
+	c.recvMAC.Reset()
+	c.recvMAC.Write(frame[:n])
+	c.recvSum = c.recvMAC.Sum(c.recvSum[:0])
+	if !hmac.Equal(c.recvSum[:macSize], frame[n:]) {
+		return nil, ErrDecryptFailed
+	}
+
+	if seq != c.recvSeq+1 {
+		return nil, ErrFrameOutOfOrder
+	}
+
+	c.recvSeq++
+	c.decoder.XORKeyStream(frame[seqSize:n], frame[seqSize:n])
+	return frame[seqSize:n], nil
+}
+
+// Overhead 流加密不增加长度, 启用帧序号后增加序号与认证码
+func (c *RC4Cipher) Overhead() int {
+	if c.sequenced {
+		return seqSize + macSize
+	}
+
+	return 0
+}
+
+// SetMACKeys 设置两个方向的帧认证密钥
+func (c *RC4Cipher) SetMACKeys(sendKey, recvKey []byte) {
+	c.sendMAC = hmac.New(sha256.New, sendKey)
+	c.recvMAC = hmac.New(sha256.New, recvKey)
+}
+
+// EnableSequence 启用帧序号, 没有设置认证密钥时无法启用
+func (c *RC4Cipher) EnableSequence() bool {
+	c.sequenced = c.sendMAC != nil && c.recvMAC != nil
+	return c.sequenced
+}
+
+// NewRC4Cipher 创建rc4加密
+func NewRC4Cipher(encoder, decoder *rc4.Cipher) *RC4Cipher {
+	return &RC4Cipher{encoder: encoder, decoder: decoder}
+}
+
+// AEADCipher 带认证的加密
+// 收发两个方向使用不同的密钥, nonce为各自方向的帧计数.
+// 启用帧序号后nonce为帧前的序号, 序号同时作为附加数据认证, 接收方只接受下一个序号的帧
+type AEADCipher struct {
+	encoder cipher.AEAD
+	decoder cipher.AEAD
+
+	// sequenced 是否启用了帧序号
+	sequenced bool
+
+	// recvSeq 最后接收的帧序号
+	recvSeq uint64
+
+	// sendNonce 发送帧计数
+	sendNonce uint64
+
+	// recvNonce 接收帧计数
+	recvNonce uint64
+
+	// sendNonceBytes, recvNonceBytes nonce缓存, 收发各在自己的协程内使用
+	sendNonceBytes []byte
+	recvNonceBytes []byte
+}
+
+// Encrypt 加密
+func (c *AEADCipher) Encrypt(frame []byte) ([]byte, error) {
+	nonce := c.sendNonceBytes
+	if c.sequenced {
+		n := len(frame)
+		frame = growFrame(frame, c.Overhead())
+		copy(frame[seqSize:], frame[:n])
+
+		c.sendNonce++
+		binary.BigEndian.PutUint64(frame, c.sendNonce)
+		binary.BigEndian.PutUint64(nonce[len(nonce)-8:], c.sendNonce)
+		c.encoder.Seal(frame[seqSize:seqSize], nonce, frame[seqSize:seqSize+n], frame[:seqSize])
+		return frame, nil
+	}
+
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], c.sendNonce)
+	c.sendNonce++
+	return c.encoder.Seal(frame[:0], nonce, frame, nil), nil
+}
+
+// Decrypt 解密
+func (c *AEADCipher) Decrypt(frame []byte) ([]byte, error) {
+	nonce := c.recvNonceBytes
+	if c.sequenced {
+		if len(frame) < seqSize+c.decoder.Overhead() {
+			return nil, ErrDecryptFailed
+		}
+
+		seq := binary.BigEndian.Uint64(frame)
+		if seq <= c.recvSeq {
+			return nil, ErrReplayedFrame
+		}
+
+		binary.BigEndian.PutUint64(nonce[len(nonce)-8:], seq)
+		plain, err := c.decoder.Open(frame[seqSize:seqSize], nonce, frame[seqSize:], frame[:seqSize])
+		if err != nil {
+			return nil, ErrDecryptFailed
+		}
+
+		if seq != c.recvSeq+1 {
+			return nil, ErrFrameOutOfOrder
+		}
+
+		c.recvSeq = seq
+		return plain, nil
+	}
+
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], c.recvNonce)
+	plain, err := c.decoder.Open(frame[:0], nonce, frame, nil)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	c.recvNonce++
+	return plain, nil
+}
+
+// NewAEADCipher 根据加密版本创建AEAD加密
+// encryptKey, decryptKey 长度必须为32字节
+func NewAEADCipher(version int8, encryptKey, decryptKey []byte) (*AEADCipher, error) {
+	encoder, err := newAEAD(version, encryptKey)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := newAEAD(version, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AEADCipher{
+		encoder:        encoder,
+		decoder:        decoder,
+		sendNonceBytes: make([]byte, encoder.NonceSize()),
+		recvNonceBytes: make([]byte, decoder.NonceSize()),
+	}, nil
+}
+
+// Overhead 认证标签的长度, 启用帧序号后包括序号
+func (c *AEADCipher) Overhead() int {
+	if c.sequenced {
+		return seqSize + c.encoder.Overhead()
+	}
+
+	return c.encoder.Overhead()
+}
+
+// EnableSequence 启用帧序号
+func (c *AEADCipher) EnableSequence() bool {
+	c.sequenced = true
+	return true
+}
+
+// growFrame 将frame的长度增加n, 容量足够时在原来的内存中完成
+func growFrame(frame []byte, n int) []byte {
+	size := len(frame) + n
+	if cap(frame) >= size {
+		return frame[:size]
+	}
+
+	m := make([]byte, size)
+	copy(m, frame)
+	return m
+}
+
+func newAEAD(version int8, key []byte) (cipher.AEAD, error) {
+	switch version {
+	case CipherChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+
+	case CipherAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(block)
+	}
+
+	return nil, ErrInvalidCipher
+}
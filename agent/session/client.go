@@ -3,8 +3,6 @@
 package session
 
 import (
-	"crypto/rc4"
-	"encoding/binary"
 	"errors"
 	"io"
 	"net"
@@ -33,13 +31,22 @@ const (
 	FlagAuthorized = 0x8
 )
 
+// frameHeaderSize 发送缓冲区为长度头预留的空间
+// 长度头按协商的格式写在数据之前, 预留空间按最长的格式计算
+const frameHeaderSize = proto.MaxFrameHeaderSize
+
+var (
+	// ErrInvalidMessageType websocket只接受二进制信息
+	ErrInvalidMessageType = errors.New("ErrInvalidMessageType")
+)
+
 // Client 连接信息
 type Client struct {
 	// id 唯一
 	id string
 
 	// protoTypes 客户端使用的通信协议
-	// 目前支持 SOCKET, WEBSOCKET, GRPC 默认情况下SOCKET
+	// 目前支持 SOCKET, WEBSOCKET, KCP, GRPC 默认情况下SOCKET
 	protoTypes proto.Types
 
 	// websocketConn 客户端websocket通信支持
@@ -52,20 +59,56 @@ type Client struct {
 	// flag 会话标记
 	flag int32
 
-	// seqID 信息ID
+	// seqID 信息ID, 每收到一个有效的帧加1
 	seqID uint32
 
-	// encoder  数据加密
-	encoder *rc4.Cipher
+	// replayed 丢弃的重复帧数量
+	replayed uint64
+
+	// cipher 数据加密与解密
+	cipher Cipher
+
+	// reader 数据帧读取
+	reader *proto.FrameReader
+
+	// framing 协商的长度头格式
+	// 接收方向立即生效, 发送方向在密钥交换的响应发出后生效
+	framing int32
+
+	// compression 协商的压缩算法, 生效时机与framing相同
+	compression int32
+
+	// compressThreshold 数据帧超过该长度才压缩
+	compressThreshold int
+
+	// maxMessageSize 信息最大长度, 同时限制解压后的长度
+	maxMessageSize int
 
-	// decoder 数据解密
-	decoder *rc4.Cipher
+	// requests 等待后端服务响应的请求 key为SeqID
+	requests map[uint32]*Request
+
+	// requestsMutex requests lock
+	requestsMutex sync.Mutex
+
+	// inflight 正在处理的请求数量, 包括已经超时但是还没有结束的请求
+	inflight int32
+
+	// maxInflight 最多同时处理的请求数量, 小于1时不限制
+	maxInflight int
+
+	// requestTimeout 请求超时时间, 小于等于0时不限制
+	requestTimeout time.Duration
 
 	// recvChan 数据接入通道
-	recvChan chan []byte
+	// 缓冲区来自缓冲池, 处理完毕后由接收方归还
+	recvChan chan *proto.BytesBuffer
 
 	// sendChan  数据发关通道
-	sendChan chan []byte
+	// 缓冲区来自缓冲池, 发送完毕后由发送协程归还
+	sendChan chan *proto.BytesBuffer
+
+	// reason 断开连接的原因, 见DisconnectReason
+	reason int32
 
 	// recvExitChan  接收退出信息号
 	recvExitChan chan struct{}
@@ -79,9 +122,6 @@ type Client struct {
 	// die 死亡信号
 	die chan struct{}
 
-	// cacheBytes 缓存空间
-	cacheBytes []byte
-
 	// logger 日志
 	logger log.Logger
 
@@ -94,51 +134,62 @@ type Client struct {
 
 func (s *Client) recv(readDeadline time.Duration, maxMessageSize int64) {
 	defer func() {
+		s.SetReason(DisconnectClientClosed)
 		close(s.recvExitChan)
 	}()
 
 	s.readyedChan <- struct{}{}
 	switch s.protoTypes {
-	case proto.Socket:
-		s.recvFromSocket(readDeadline)
+	case proto.Socket, proto.KCP:
+		s.recvFrames(func() {
+			s.socketConn.SetReadDeadline(time.Now().Add(readDeadline))
+		})
 
 	case proto.Websocket:
-		s.recvFromWebSocket(readDeadline, maxMessageSize)
+		s.websocketConn.SetReadLimit(maxMessageSize)
+		s.websocketConn.SetPongHandler(func(string) error {
+			s.websocketConn.SetReadDeadline(time.Now().Add(readDeadline))
+			return nil
+		})
+
+		s.recvFrames(func() {
+			s.websocketConn.SetReadDeadline(time.Now().Add(readDeadline))
+		})
 
 	case proto.None:
 	}
 }
 
-func (s *Client) recvFromSocket(readDeadline time.Duration) {
+// recvFrames 读取数据帧, 分片的帧重组后才交给recvChan
+// deadline 每次读取前设置读取超时
+func (s *Client) recvFrames(deadline func()) {
 	var logger log.Logger
 	{
 		logger = s.logger
 	}
 
-	header := make([]byte, 2)
 	for {
-		// 写入超时与读取超时
-		s.socketConn.SetReadDeadline(time.Now().Add(readDeadline))
-		n, err := io.ReadFull(s.socketConn, header)
+		deadline()
+		payload, err := s.reader.ReadFrame()
 		if err != nil {
-			return
-		}
+			switch {
+			case err == io.ErrUnexpectedEOF, err == proto.ErrFrameTooLarge:
+				kitlog.Error(logger).Log("error", "read payload failed", "reason", err.Error())
 
-		size := binary.BigEndian.Uint16(header)
-		payload := make([]byte, size)
-		n, err = io.ReadFull(s.socketConn, payload)
-		if err != nil {
-			kitlog.Error(logger).Log("error", "read payload failed", "reason", err.Error(), "size", n)
+			case websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure):
+				kitlog.Error(logger).Log("error", err)
+			}
 			return
 		}
 
-		s.SID(1)
 		select {
 		case s.recvChan <- payload:
 		case <-s.die:
+			proto.ReleaseBytesBuffer(payload)
 			return
 
 		case <-s.sendExitChan:
+			proto.ReleaseBytesBuffer(payload)
 			return
 		}
 
@@ -148,46 +199,39 @@ func (s *Client) recvFromSocket(readDeadline time.Duration) {
 	}
 }
 
-func (s *Client) recvFromWebSocket(readDeadline time.Duration, maxMessageSize int64) {
-	var logger log.Logger
-	{
-		logger = s.logger
-	}
+// webSocketReader 将连续的websocket二进制信息作为数据流读取
+// 每条信息包含一个或多个带长度头的数据帧, 与socket的格式相同
+type webSocketReader struct {
+	conn *websocket.Conn
+	r    io.Reader
+}
 
+func (w *webSocketReader) Read(p []byte) (int, error) {
 	for {
-		// 写入超时与读取超时
-		s.websocketConn.SetReadLimit(maxMessageSize)
-		s.websocketConn.SetReadDeadline(time.Now().Add(readDeadline))
-		s.websocketConn.SetPongHandler(func(string) error {
-			s.websocketConn.SetReadDeadline(time.Now().Add(readDeadline))
-			return nil
-		})
+		if w.r == nil {
+			frameType, r, err := w.conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
 
-		frameType, payload, err := s.websocketConn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				kitlog.Error(logger).Log("error", err)
+			if frameType != websocket.BinaryMessage {
+				return 0, ErrInvalidMessageType
 			}
-			return
-		}
 
-		if frameType != websocket.BinaryMessage {
-			return
+			w.r = r
 		}
 
-		s.SID(1)
-		select {
-		case s.recvChan <- payload[2:]:
-		case <-s.die:
-			return
+		n, err := w.r.Read(p)
+		if err == io.EOF {
+			w.r = nil
+			if n < 1 {
+				continue
+			}
 
-		case <-s.sendExitChan:
-			return
+			err = nil
 		}
 
-		if s.Flag()&FlagKickedOut != 0 {
-			return
-		}
+		return n, err
 	}
 }
 
@@ -203,6 +247,11 @@ func (s *Client) send(writeDeadline time.Duration) {
 		close(s.sendExitChan)
 	}()
 
+	var (
+		framing    = proto.FramingUint16
+		compressor proto.Compressor
+	)
+
 	s.readyedChan <- struct{}{}
 	for {
 		select {
@@ -211,20 +260,37 @@ func (s *Client) send(writeDeadline time.Duration) {
 				return
 			}
 
-			flag := s.Flag()
-			if flag&FlagEncrypt != 0 {
-				s.encoder.XORKeyStream(frame, frame)
-			} else if flag&FlagKeyexcg != 0 {
-				flag &^= FlagKeyexcg
-				flag |= FlagEncrypt
-				s.Flag(flag)
+			// Disconnect放入的结束标记, 之前的数据已经发送完毕
+			if frame == nil {
+				s.closeWithReason(writeDeadline)
+				s.Kicked()
+				return
+			}
+
+			if compressor != nil && frame.Len()-frameHeaderSize >= s.compressThreshold {
+				frame = compressFrame(frame, compressor)
 			}
 
-			if err := s.write(frame, writeDeadline); err != nil {
+			flag := s.Flag()
+			err := s.writeFrame(frame, framing, flag&FlagEncrypt != 0, writeDeadline)
+			proto.ReleaseBytesBuffer(frame)
+			if err == proto.ErrFrameTooLarge {
+				// 超出长度头格式的数据无法发送, 丢弃但是保留连接
+				kitlog.Warn(logger).Log("error", err, "sid", s.ID())
+			} else if err != nil {
 				kitlog.Error(logger).Log("error", err)
+				s.SetReason(DisconnectSendFailed)
 				return
 			}
 
+			if flag&(FlagEncrypt|FlagKeyexcg) == FlagKeyexcg {
+				flag &^= FlagKeyexcg
+				flag |= FlagEncrypt
+				s.Flag(flag)
+				framing = s.Framing()
+				compressor, _ = proto.GetCompressor(s.Compression())
+			}
+
 		case <-ticker.C:
 			// websocket ping
 			if s.protoTypes != proto.Websocket {
@@ -233,6 +299,7 @@ func (s *Client) send(writeDeadline time.Duration) {
 
 			s.websocketConn.SetWriteDeadline(time.Now().Add(writeDeadline))
 			if err := s.websocketConn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				s.SetReason(DisconnectSendFailed)
 				return
 			}
 
@@ -249,29 +316,63 @@ func (s *Client) send(writeDeadline time.Duration) {
 	}
 }
 
-func (s *Client) write(frame []byte, writeDeadline time.Duration) (err error) {
-	switch s.protoTypes {
-	case proto.Socket:
+// compressFrame 压缩frame, 压缩后没有变小时返回原来的frame
+func compressFrame(frame *proto.BytesBuffer, compressor proto.Compressor) *proto.BytesBuffer {
+	w := NewFrame()
+	if err := proto.CompressFrame(w, frame.Data()[frameHeaderSize:], compressor); err != nil || w.Len() >= frame.Len() {
+		proto.ReleaseBytesBuffer(w)
+		return frame
+	}
+
+	proto.ReleaseBytesBuffer(frame)
+	return w
+}
 
+// writeFrame 在缓冲区内原地加密并写入长度头后发送
+// frame由NewFrame创建, 预留的长度头之后为需要发送的数据
+func (s *Client) writeFrame(frame *proto.BytesBuffer, framing proto.Framing, encrypt bool, writeDeadline time.Duration) error {
+	if encrypt {
+		frame.Grow(s.cipher.Overhead())
+		m, err := s.cipher.Encrypt(frame.Data()[frameHeaderSize:])
+		if err != nil {
+			return err
+		}
+
+		// 加密结果在缓冲区内时这里不会发生复制
+		frame.Truncate(frameHeaderSize)
+		if err := frame.WriteBytes(m...); err != nil {
+			return err
+		}
+	}
+
+	// 长度头紧靠数据写入预留空间的末尾
+	var header [frameHeaderSize]byte
+	data := frame.Data()
+	m, err := framing.AppendHeader(header[:0], len(data)-frameHeaderSize, false)
+	if err != nil {
+		return err
+	}
+
+	offset := frameHeaderSize - len(m)
+	copy(data[offset:], m)
+	return s.write(data[offset:], writeDeadline)
+}
+
+func (s *Client) write(data []byte, writeDeadline time.Duration) (err error) {
+	switch s.protoTypes {
+	case proto.Socket, proto.KCP:
 		if writeDeadline.Nanoseconds() > 0 {
 			s.socketConn.SetWriteDeadline(time.Now().Add(writeDeadline))
 		}
 
-		err = s.writeToSocket(frame)
-		if err != nil {
-			return
-		}
+		_, err = s.socketConn.Write(data)
 
 	case proto.Websocket:
-
 		if writeDeadline.Nanoseconds() > 0 {
 			s.websocketConn.SetWriteDeadline(time.Now().Add(writeDeadline))
 		}
 
-		err = s.writeToWebSocket(frame)
-		if err != nil {
-			return
-		}
+		err = s.websocketConn.WriteMessage(websocket.BinaryMessage, data)
 
 	case proto.None:
 	}
@@ -279,33 +380,45 @@ func (s *Client) write(frame []byte, writeDeadline time.Duration) (err error) {
 	return
 }
 
-func (s *Client) writeToSocket(frame []byte) error {
-	size := len(frame)
-	binary.BigEndian.PutUint16(s.cacheBytes, uint16(size))
-	copy(s.cacheBytes[2:], frame)
-	_, err := s.socketConn.Write(s.cacheBytes[:size+2])
-	return err
+// SetFraming 设置协商的长度头格式
+// 接收方向从下一帧开始使用新的格式, 发送方向在密钥交换的响应发出后切换
+func (s *Client) SetFraming(framing proto.Framing) {
+	atomic.StoreInt32(&s.framing, int32(framing))
+	if s.reader != nil {
+		s.reader.SetFraming(framing)
+	}
 }
 
-func (s *Client) writeToWebSocket(frame []byte) (err error) {
-	size := len(frame)
-	binary.BigEndian.PutUint16(s.cacheBytes, uint16(size))
-	copy(s.cacheBytes[2:], frame)
+// Framing 协商的长度头格式
+func (s *Client) Framing() proto.Framing {
+	return proto.Framing(atomic.LoadInt32(&s.framing))
+}
 
-	var w io.WriteCloser
-	{
-		w, err = s.websocketConn.NextWriter(websocket.BinaryMessage)
-		if err != nil {
-			return
-		}
+// SetCompression 设置协商的压缩算法
+// 接收方向立即接受压缩的数据, 发送方向在密钥交换的响应发出后开始压缩
+func (s *Client) SetCompression(compression proto.Compression) {
+	atomic.StoreInt32(&s.compression, int32(compression))
+}
+
+// Compression 协商的压缩算法
+func (s *Client) Compression() proto.Compression {
+	return proto.Compression(atomic.LoadInt32(&s.compression))
+}
+
+// Decompress 解压请求中压缩的Content, 解压后的数据写入w
+// 解压后的长度不能超过信息最大长度
+func (s *Client) Decompress(w *proto.BytesBuffer, req *proto.RequestBytes) error {
+	if req.V()&proto.VerCompressed == 0 {
+		return nil
 	}
 
-	w.Write(s.cacheBytes[:size+2])
-	if err = w.Close(); err != nil {
-		return
+	compressor, ok := proto.GetCompressor(s.Compression())
+	if !ok {
+		return proto.ErrCompressorNotFound
 	}
 
-	return err
+	w.SetLimit(s.maxMessageSize)
+	return req.Decompress(w, compressor)
 }
 
 // Flag 客户端状态
@@ -350,18 +463,14 @@ func (s *Client) GetLogger() log.Logger {
 	return s.logger
 }
 
-// SetEncoder RC4加密
-func (s *Client) SetEncoder(encoder *rc4.Cipher) {
-	s.encoder = encoder
-}
-
-// SetDecoder rc4解密
-func (s *Client) SetDecoder(decoder *rc4.Cipher) {
-	s.decoder = decoder
+// SetCipher 设置数据加密与解密
+func (s *Client) SetCipher(cipher Cipher) {
+	s.cipher = cipher
 }
 
 // GetRecvChan 获取接收通道
-func (s *Client) GetRecvChan() chan []byte {
+// 收到的缓冲区处理完毕后需要调用proto.ReleaseBytesBuffer归还
+func (s *Client) GetRecvChan() chan *proto.BytesBuffer {
 	return s.recvChan
 }
 
@@ -376,39 +485,74 @@ func (s *Client) GetSendExitChan() <-chan struct{} {
 }
 
 // EncodeFrame 加密信息
-func (s *Client) EncodeFrame(frame []byte) []byte {
-	if s.encoder != nil {
-		s.encoder.XORKeyStream(frame, frame)
+func (s *Client) EncodeFrame(frame []byte) ([]byte, error) {
+	if s.cipher != nil {
+		return s.cipher.Encrypt(frame)
 	}
 
-	return frame
+	return frame, nil
 }
 
 // DecodeFrame 解密信息
-func (s *Client) DecodeFrame(frame []byte) []byte {
-	if s.decoder != nil {
-		s.decoder.XORKeyStream(frame, frame)
+// 已经接收过的帧返回ErrReplayedFrame并计数
+func (s *Client) DecodeFrame(frame []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return frame, nil
 	}
-	return frame
+
+	frame, err := s.cipher.Decrypt(frame)
+	if err == ErrReplayedFrame {
+		atomic.AddUint64(&s.replayed, 1)
+	}
+
+	return frame, err
+}
+
+// EnableSequence 启用帧序号, 加密不支持帧序号时返回false
+// 需要在SetCipher之后, 开始加密之前调用
+func (s *Client) EnableSequence() bool {
+	c, ok := s.cipher.(SequencedCipher)
+	if !ok {
+		return false
+	}
+
+	return c.EnableSequence()
+}
+
+// Replayed 丢弃的重复帧数量
+func (s *Client) Replayed() uint64 {
+	return atomic.LoadUint64(&s.replayed)
 }
 
 // Kicked 踢掉客户端
+// 可能同时在多个协程中调用, 只关闭一次
 func (s *Client) Kicked() {
-	flag := atomic.LoadInt32(&s.flag)
-	if flag&FlagKickedOut != 0 {
-		return
-	}
+	for {
+		flag := atomic.LoadInt32(&s.flag)
+		if flag&FlagKickedOut != 0 {
+			return
+		}
 
-	flag |= FlagKickedOut
-	atomic.StoreInt32(&s.flag, flag)
-	close(s.die)
+		if atomic.CompareAndSwapInt32(&s.flag, flag, flag|FlagKickedOut) {
+			close(s.die)
+			return
+		}
+	}
 }
 
 // ID 获取ID
 func (s *Client) ID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	return s.id
 }
 
+func (s *Client) setID(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.id = id
+}
+
 // SID 信息ID
 func (s *Client) SID(v ...uint32) uint32 {
 	if len(v) > 0 {
@@ -418,15 +562,39 @@ func (s *Client) SID(v ...uint32) uint32 {
 	return atomic.LoadUint32(&s.seqID)
 }
 
+// NewFrame 从缓冲池获取发送缓冲区, 已经预留了长度头
+// 写入数据后交给SendFrame发送, 数据长度受协商的长度头格式限制
+func NewFrame() *proto.BytesBuffer {
+	frame := proto.AcquireBytesBuffer()
+	frame.SetLimit(proto.MaxFrameSize)
+	frame.WriteZeros(frameHeaderSize)
+	return frame
+}
+
 // Send 发送数据
+// 数据被复制到缓冲池的缓冲区, 调用后frame可以继续使用
 func (s *Client) Send(frame []byte) error {
 	if frame == nil {
 		return nil
 	}
+
+	b := NewFrame()
+	if err := b.WriteBytes(frame...); err != nil {
+		proto.ReleaseBytesBuffer(b)
+		return err
+	}
+
+	return s.SendFrame(b)
+}
+
+// SendFrame 发送由NewFrame创建的缓冲区
+// 调用后缓冲区归session所有, 发送完毕或者失败时归还缓冲池
+func (s *Client) SendFrame(frame *proto.BytesBuffer) error {
 	select {
 	case s.sendChan <- frame:
 	default:
-		kitlog.Warn(s.logger).Log("error", "chanfull", "sid", s.id)
+		proto.ReleaseBytesBuffer(frame)
+		kitlog.Warn(s.logger).Log("error", "chanfull", "sid", s.ID())
 		return errors.New("chanfull")
 	}
 	return nil
@@ -0,0 +1,256 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"crypto/rc4"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/go-kit/kit/log"
+)
+
+// pipeConn net.Pipe的超时设置每次都会创建定时器, 基准测试中忽略超时
+type pipeConn struct {
+	net.Conn
+}
+
+func (c pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c pipeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestClientFraming(t *testing.T) {
+	server, client := net.Pipe()
+	store := NewStore(log.NewNopLogger())
+	sess := store.NewClient(pipeConn{server}, "", time.Minute, time.Minute, 1<<20)
+	defer func() {
+		client.Close()
+		store.RemoveAndExit(sess.ID())
+	}()
+
+	// 模拟密钥交换: 接收方向立即切换, 交换的响应仍使用2字节长度头
+	sess.SetCipher(NewRC4Cipher(mustRC4(t), mustRC4(t)))
+	sess.Flag(FlagKeyexcg)
+	sess.SetFraming(proto.FramingUint32)
+	sess.SetCompression(proto.CompressionSnappy)
+	sess.Send([]byte("handshake"))
+
+	header := make([]byte, 4)
+	io.ReadFull(client, header[:2])
+	if size := binary.BigEndian.Uint16(header); size != 9 {
+		t.Fatalf("expected uint16 header 9, got %d", size)
+	}
+
+	io.ReadFull(client, make([]byte, 9))
+
+	// 分片发送大于65535的消息
+	message := make([]byte, 100000)
+	go func() {
+		var w []byte
+		w, _ = proto.FramingUint32.AppendHeader(w, 60000, true)
+		w = append(w, message[:60000]...)
+		w, _ = proto.FramingUint32.AppendHeader(w, 40000, false)
+		w = append(w, message[60000:]...)
+		client.Write(w)
+	}()
+
+	frame := <-sess.GetRecvChan()
+	if frame.Len() != len(message) {
+		t.Fatalf("expected reassembled size %d, got %d", len(message), frame.Len())
+	}
+
+	proto.ReleaseBytesBuffer(frame)
+	if err := sess.Send(message); err != nil {
+		t.Fatal(err)
+	}
+
+	// 交换完成后使用4字节长度头, 数据先压缩再加密
+	io.ReadFull(client, header)
+	size := binary.BigEndian.Uint32(header)
+	if size < 1 || size >= uint32(len(message)) {
+		t.Fatalf("expected compressed uint32 header, got %d", size)
+	}
+
+	frame = proto.NewBytesBuffer(make([]byte, size))
+	io.ReadFull(client, frame.Data())
+	mustRC4(t).XORKeyStream(frame.Data(), frame.Data())
+
+	req := &proto.RequestBytes{}
+	req.Unmarshal(frame.Data())
+	compressor, _ := proto.GetCompressor(proto.CompressionSnappy)
+	w := proto.NewBytesBuffer(nil)
+	w.SetLimit(proto.MaxFrameSize)
+	if err := req.Decompress(w, compressor); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(req.Content)+9 != len(message) {
+		t.Fatalf("expected decompressed size %d, got %d", len(message), len(req.Content)+9)
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+	server, client := net.Pipe()
+	store := NewStore(log.NewNopLogger())
+	store.SetRequestLimits(20*time.Millisecond, 1)
+	sess := store.NewClient(pipeConn{server}, "", time.Minute, time.Minute, 0)
+	defer func() {
+		client.Close()
+		store.RemoveAndExit(sess.ID())
+	}()
+
+	timeout := make(chan uint32, 1)
+	r, err := sess.BeginRequest(1, func() { timeout <- 1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sess.BeginRequest(2, func() {}); err != ErrTooManyRequests {
+		t.Fatalf("expected ErrTooManyRequests, got %v", err)
+	}
+
+	// 超时后立即回调, 但是直到Done之前仍然占用名额
+	select {
+	case <-timeout:
+	case <-time.After(time.Second):
+		t.Fatal("request 1 not timed out")
+	}
+
+	if r.Context().Err() == nil || sess.Inflight() != 1 {
+		t.Fatalf("unexpected state after timeout: %v, %d", r.Context().Err(), sess.Inflight())
+	}
+
+	if r.Done() {
+		t.Fatal("late response of request 1 should be dropped")
+	}
+
+	r, err = sess.BeginRequest(3, func() { t.Error("request 3 timed out") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Done() || sess.Inflight() != 0 {
+		t.Fatalf("request 3 should be answered, inflight %d", sess.Inflight())
+	}
+
+	r, _ = sess.BeginRequest(4, func() {})
+	sess.CancelRequests()
+	if r.Context().Err() == nil || r.Done() {
+		t.Fatal("request 4 should be cancelled")
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	server, client := net.Pipe()
+	store := NewStore(log.NewNopLogger())
+	sess := store.NewClient(pipeConn{server}, "", time.Minute, time.Minute, 0)
+	defer func() {
+		client.Close()
+		store.RemoveAndExit(sess.ID())
+	}()
+
+	// 之前放入发送缓冲区的数据先发送, 然后是bye帧
+	sess.Send([]byte("balala"))
+	go sess.Disconnect(DisconnectRPMExceeded, "bye")
+
+	readFrame := func() []byte {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(client, header); err != nil {
+			t.Fatal(err)
+		}
+
+		frame := make([]byte, binary.BigEndian.Uint16(header))
+		io.ReadFull(client, frame)
+		return frame
+	}
+
+	if frame := readFrame(); string(frame) != "balala" {
+		t.Fatalf("expected queued frame, got %q", frame)
+	}
+
+	resp := &proto.ResponseBytes{}
+	if err := resp.Unmarshal(readFrame()); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Cmd != proto.InternalKick || resp.SubCmd != proto.Command(DisconnectRPMExceeded) || string(resp.Content) != "bye" {
+		t.Fatalf("unexpected bye frame %+v", resp)
+	}
+
+	select {
+	case <-sess.GetSendExitChan():
+	case <-time.After(time.Second):
+		t.Fatal("send loop not closed after bye frame")
+	}
+
+	// 只记录第一个原因
+	sess.Disconnect(DisconnectShutdown, "")
+	if sess.Reason() != DisconnectRPMExceeded || sess.Reason().CloseCode() != 4003 {
+		t.Fatalf("unexpected reason %v, %d", sess.Reason(), sess.Reason().CloseCode())
+	}
+}
+
+func mustRC4(t *testing.T) *rc4.Cipher {
+	c, err := rc4.NewCipher([]byte("balala"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// BenchmarkClientEcho 每帧经过读取, 处理, 组包, 加密与发送的完整路径
+func BenchmarkClientEcho(b *testing.B) {
+	server, client := net.Pipe()
+	store := NewStore(log.NewNopLogger())
+	sess := store.NewClient(pipeConn{server}, "", time.Minute, time.Minute, 0)
+	cipher, err := NewAEADCipher(CipherChaCha20Poly1305, make([]byte, 32), make([]byte, 32))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	sess.SetCipher(cipher)
+	sess.Flag(FlagEncrypt)
+	defer func() {
+		client.Close()
+		store.RemoveAndExit(sess.ID())
+	}()
+
+	go func() {
+		for {
+			select {
+			case frame := <-sess.GetRecvChan():
+				resp := &proto.ResponseBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 1, Content: frame.Bytes()}
+				w := NewFrame()
+				resp.MarshalTo(w)
+				proto.ReleaseBytesBuffer(frame)
+				sess.SendFrame(w)
+
+			case <-sess.GetRecvExitChan():
+				return
+			}
+		}
+	}()
+
+	request := make([]byte, 2+128)
+	binary.BigEndian.PutUint16(request, 128)
+	response := make([]byte, 2+proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Write(request); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := io.ReadFull(client, response[:2]); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := io.ReadFull(client, response[2:2+binary.BigEndian.Uint16(response)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
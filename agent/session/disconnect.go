@@ -0,0 +1,166 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/doublemo/balala/cores/proto"
+	"github.com/gorilla/websocket"
+)
+
+// DisconnectReason 断开连接的原因
+// 服务器主动断开时作为InternalKick的子命令号发送给客户端
+type DisconnectReason int32
+
+// 断开连接的原因定义
+const (
+	// DisconnectNone 没有断开
+	DisconnectNone DisconnectReason = iota
+
+	// DisconnectLoginElsewhere 用户在其它地方登录
+	DisconnectLoginElsewhere
+
+	// DisconnectProtocolViolation 客户端违反协议, 例如数据无法解析、请求编号错误、解密失败、帧序号跳跃
+	DisconnectProtocolViolation
+
+	// DisconnectRPMExceeded 每分钟请求数量超过限制
+	DisconnectRPMExceeded
+
+	// DisconnectHandshakeTimeout 没有在规定时间内完成密钥交换
+	DisconnectHandshakeTimeout
+
+	// DisconnectAuthTimeout 没有在规定时间内完成认证
+	DisconnectAuthTimeout
+
+	// DisconnectShutdown 服务器关闭
+	DisconnectShutdown
+
+	// DisconnectClientClosed 客户端关闭了连接或者读取失败
+	DisconnectClientClosed
+
+	// DisconnectSendFailed 发送数据失败
+	DisconnectSendFailed
+)
+
+// byeTimeout 发送缓冲区已满时等待放入bye帧的时间
+const byeTimeout = time.Second
+
+var disconnectReasonNames = map[DisconnectReason]string{
+	DisconnectNone:              "none",
+	DisconnectLoginElsewhere:    "login_elsewhere",
+	DisconnectProtocolViolation: "protocol_violation",
+	DisconnectRPMExceeded:       "rpm_exceeded",
+	DisconnectHandshakeTimeout:  "handshake_timeout",
+	DisconnectAuthTimeout:       "auth_timeout",
+	DisconnectShutdown:          "shutdown",
+	DisconnectClientClosed:      "client_closed",
+	DisconnectSendFailed:        "send_failed",
+}
+
+// String 原因名称, 用于日志与监控
+func (r DisconnectReason) String() string {
+	if name, ok := disconnectReasonNames[r]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// CloseCode websocket关闭代码
+// 标准代码无法表达的原因使用4000 + 原因
+func (r DisconnectReason) CloseCode() int {
+	switch r {
+	case DisconnectNone, DisconnectClientClosed:
+		return websocket.CloseNormalClosure
+
+	case DisconnectShutdown:
+		return websocket.CloseGoingAway
+
+	case DisconnectProtocolViolation:
+		return websocket.CloseProtocolError
+
+	case DisconnectSendFailed:
+		return websocket.CloseInternalServerErr
+	}
+
+	return 4000 + int(r)
+}
+
+// Disconnect 发送bye帧后断开连接
+// bye帧经过sendChan发送, 之前已经放入发送缓冲区的数据会先发送,
+// 内容为InternalKick, 子命令号为原因, 数据为message.
+// websocket连接随后发送带关闭代码的关闭帧. 只有第一次调用有效
+func (s *Client) Disconnect(reason DisconnectReason, message string) {
+	if !s.setReason(reason) {
+		return
+	}
+
+	resp := &proto.ResponseBytes{
+		Ver:     1,
+		Cmd:     proto.InternalKick,
+		SubCmd:  proto.Command(reason),
+		SeqID:   proto.PushSeqID,
+		Content: []byte(message),
+	}
+
+	// nil通知发送协程在之前的数据发送完毕后关闭连接
+	frames := []*proto.BytesBuffer{nil}
+	if frame := NewFrame(); resp.MarshalTo(frame) == nil {
+		frames = []*proto.BytesBuffer{frame, nil}
+	} else {
+		proto.ReleaseBytesBuffer(frame)
+	}
+
+	timer := time.NewTimer(byeTimeout)
+	defer timer.Stop()
+
+	for _, m := range frames {
+		select {
+		case s.sendChan <- m:
+		case <-timer.C:
+			if m != nil {
+				proto.ReleaseBytesBuffer(m)
+			}
+
+			s.Kicked()
+			return
+
+		case <-s.die:
+			if m != nil {
+				proto.ReleaseBytesBuffer(m)
+			}
+			return
+		}
+	}
+}
+
+// Reason 断开连接的原因, 连接没有断开时为DisconnectNone
+func (s *Client) Reason() DisconnectReason {
+	return DisconnectReason(atomic.LoadInt32(&s.reason))
+}
+
+// SetReason 记录断开连接的原因, 已经有原因时忽略
+func (s *Client) SetReason(reason DisconnectReason) {
+	s.setReason(reason)
+}
+
+func (s *Client) setReason(reason DisconnectReason) bool {
+	return atomic.CompareAndSwapInt32(&s.reason, int32(DisconnectNone), int32(reason))
+}
+
+// closeWithReason 发送websocket关闭帧
+func (s *Client) closeWithReason(writeDeadline time.Duration) {
+	if s.protoTypes != proto.Websocket {
+		return
+	}
+
+	reason := s.Reason()
+	deadline := time.Now().Add(writeDeadline)
+	if writeDeadline <= 0 {
+		deadline = time.Now().Add(byeTimeout)
+	}
+
+	s.websocketConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(reason.CloseCode(), reason.String()), deadline)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/doublemo/balala/cores/proto"
+)
+
+var (
+	// ErrTooManyRequests 等待响应的请求数量已达上限
+	ErrTooManyRequests = proto.NewError(proto.CodeTooManyRequests, "ErrTooManyRequests").WithRetryable()
+
+	// ErrRequestTimeout 请求超时
+	ErrRequestTimeout = proto.NewError(proto.CodeTimeout, "ErrRequestTimeout").WithRetryable()
+)
+
+// Request 等待后端服务响应的请求
+// 由BeginRequest登记, 处理完毕后必须调用Done
+type Request struct {
+	// SeqID 请求编号
+	SeqID uint32
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	timer  *time.Timer
+	sess   *Client
+}
+
+// Context 请求的上下文, 请求超时或者session关闭时被取消
+func (r *Request) Context() context.Context {
+	return r.ctx
+}
+
+// Done 请求处理完毕
+// 返回false时请求已经超时或者session已经关闭, 响应需要丢弃
+func (r *Request) Done() bool {
+	ok := r.sess.removeRequest(r)
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	r.cancel()
+	atomic.AddInt32(&r.sess.inflight, -1)
+	return ok
+}
+
+// BeginRequest 按SeqID登记等待响应的请求
+// 等待响应的请求超过上限时返回ErrTooManyRequests, 超时后调用timeout, 之后该请求的响应将被丢弃
+// 超时的请求在Done之前仍然占用名额, 防止后端服务没有响应时协程无限增长
+func (s *Client) BeginRequest(seqID uint32, timeout func()) (*Request, error) {
+	if n := atomic.AddInt32(&s.inflight, 1); s.maxInflight > 0 && int(n) > s.maxInflight {
+		atomic.AddInt32(&s.inflight, -1)
+		return nil, ErrTooManyRequests
+	}
+
+	r := &Request{SeqID: seqID, sess: s}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	s.requestsMutex.Lock()
+	s.requests[seqID] = r
+	s.requestsMutex.Unlock()
+
+	if s.requestTimeout > 0 {
+		r.timer = time.AfterFunc(s.requestTimeout, func() {
+			if s.removeRequest(r) {
+				r.cancel()
+				timeout()
+			}
+		})
+	}
+
+	return r, nil
+}
+
+// Inflight 等待响应的请求数量
+func (s *Client) Inflight() int {
+	return int(atomic.LoadInt32(&s.inflight))
+}
+
+// CancelRequests 取消所有等待响应的请求, 之后收到的响应都将被丢弃
+func (s *Client) CancelRequests() {
+	s.requestsMutex.Lock()
+	requests := s.requests
+	s.requests = make(map[uint32]*Request)
+	s.requestsMutex.Unlock()
+
+	for _, r := range requests {
+		r.cancel()
+	}
+}
+
+// removeRequest 移除登记的请求, 请求已经不存在时返回false
+func (s *Client) removeRequest(r *Request) bool {
+	s.requestsMutex.Lock()
+	defer s.requestsMutex.Unlock()
+
+	if m, ok := s.requests[r.SeqID]; !ok || m != r {
+		return false
+	}
+
+	delete(s.requests, r.SeqID)
+	return true
+}
@@ -3,19 +3,52 @@
 package session
 
 import (
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/doublemo/balala/cores/networks"
 	"github.com/doublemo/balala/cores/proto"
+	"github.com/doublemo/balala/cores/types"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 	"github.com/gorilla/websocket"
 	uuid "github.com/satori/go.uuid"
+	kcp "github.com/xtaci/kcp-go"
+)
+
+var (
+	// ErrTooManySessions 用户在线session数量已达上限
+	ErrTooManySessions = errors.New("ErrTooManySessions")
 )
 
 // Store session 存储
 type Store struct {
-	store  sync.Map
+	store sync.Map
+
+	// users 用户ID索引, 同一用户的session按认证先后排列
+	users map[types.UID][]*Client
+
+	// usersMutex users lock
+	usersMutex sync.RWMutex
+
+	// compressThreshold 新建session的压缩阈值
+	compressThreshold int32
+
+	// maxInflight 新建session最多同时处理的请求数量
+	maxInflight int32
+
+	// requestTimeout 新建session的请求超时时间
+	requestTimeout int64
+
+	// disconnects 按原因统计断开的连接
+	disconnects metrics.Counter
+
+	// onRemove 已认证的session删除时调用
+	onRemove func(*Client)
+
 	logger log.Logger
 }
 
@@ -28,9 +61,8 @@ func (ss *Store) NewClient(conn interface{}, sid string, readDeadline, writeDead
 	s.die = make(chan struct{})
 	s.recvExitChan = make(chan struct{})
 	s.sendExitChan = make(chan struct{})
-	s.recvChan = make(chan []byte)
-	s.sendChan = make(chan []byte, 1024)
-	s.cacheBytes = make([]byte, 65535)
+	s.recvChan = make(chan *proto.BytesBuffer)
+	s.sendChan = make(chan *proto.BytesBuffer, 1024)
 	s.readyedChan = make(chan struct{}, 2)
 	s.protoTypes = proto.None
 	s.logger = ss.logger
@@ -42,6 +74,11 @@ func (ss *Store) NewClient(conn interface{}, sid string, readDeadline, writeDead
 		s.websocketConn = c
 		s.SetParam("RemoteAddr", c.RemoteAddr().String())
 
+	case *kcp.UDPSession, *networks.KCPStream:
+		s.protoTypes = proto.KCP
+		s.socketConn = c.(net.Conn)
+		s.SetParam("RemoteAddr", s.socketConn.RemoteAddr().String())
+
 	case net.Conn:
 		s.protoTypes = proto.Socket
 		s.socketConn = c
@@ -52,6 +89,27 @@ func (ss *Store) NewClient(conn interface{}, sid string, readDeadline, writeDead
 		s.id = uuid.NewV4().String()
 	}
 
+	// 没有设置时与2字节长度头的限制一致
+	if maxMessageSize <= 0 {
+		maxMessageSize = proto.PacketMaxLimit
+	}
+
+	s.maxMessageSize = int(maxMessageSize)
+	s.compressThreshold = int(atomic.LoadInt32(&ss.compressThreshold))
+	s.requests = make(map[uint32]*Request)
+	s.maxInflight = int(atomic.LoadInt32(&ss.maxInflight))
+	s.requestTimeout = time.Duration(atomic.LoadInt64(&ss.requestTimeout))
+
+	switch s.protoTypes {
+	case proto.Socket, proto.KCP:
+		s.reader = proto.NewFrameReader(s.socketConn)
+		s.reader.SetMaxSize(int(maxMessageSize))
+
+	case proto.Websocket:
+		s.reader = proto.NewFrameReader(&webSocketReader{conn: s.websocketConn})
+		s.reader.SetMaxSize(int(maxMessageSize))
+	}
+
 	s.SetParam("CreateAt", time.Now())
 
 	go s.recv(readDeadline, maxMessageSize)
@@ -76,26 +134,143 @@ func (ss *Store) Get(sid string) *Client {
 	return s.(*Client)
 }
 
+// GetByUserID 获取用户的所有session
+func (ss *Store) GetByUserID(uid types.UID) []*Client {
+	ss.usersMutex.RLock()
+	defer ss.usersMutex.RUnlock()
+
+	clients := make([]*Client, len(ss.users[uid]))
+	copy(clients, ss.users[uid])
+	return clients
+}
+
 // Remove 删除session
 func (ss *Store) Remove(sid string) {
+	s, ok := ss.store.Load(sid)
+	if !ok {
+		return
+	}
+
 	ss.store.Delete(sid)
+	ss.unindex(s.(*Client))
 }
 
 // Store 保存session
 func (ss *Store) Store(s *Client) {
-	ss.store.Store(s.id, s)
+	ss.store.Store(s.ID(), s)
+}
+
+// Authorize 认证通过后更换session的id并设置为已认证
+// 新id先写入存储再删除旧id, 过程中session始终可以被查到.
+// max > 0 时同一用户最多保留max个session:
+// reject为true时超出上限返回ErrTooManySessions且不做任何修改,
+// 否则从用户索引中移除最早的session并返回, 由调用者负责踢出
+func (ss *Store) Authorize(s *Client, sid string, uid types.UID, max int, reject bool) ([]*Client, error) {
+	ss.usersMutex.Lock()
+	clients := ss.users[uid]
+	var evicted []*Client
+	if max > 0 && len(clients) >= max {
+		if reject {
+			ss.usersMutex.Unlock()
+			return nil, ErrTooManySessions
+		}
+
+		n := len(clients) - max + 1
+		evicted = make([]*Client, n)
+		copy(evicted, clients[:n])
+		clients = clients[n:]
+	}
+
+	ss.users[uid] = append(clients[:len(clients):len(clients)], s)
+	ss.usersMutex.Unlock()
+
+	oldID := s.ID()
+	s.SetParam("UserID", uid)
+	s.SetParam("AuthorizeAt", time.Now())
+	s.setID(sid)
+	ss.store.Store(sid, s)
+	ss.store.Delete(oldID)
+	s.Flag(s.Flag() | FlagAuthorized)
+	return evicted, nil
+}
+
+// unindex 从用户索引中移除session
+func (ss *Store) unindex(s *Client) {
+	m, ok := s.Param("UserID")
+	if !ok {
+		return
+	}
+
+	uid, ok := m.(types.UID)
+	if !ok {
+		return
+	}
+
+	ss.usersMutex.Lock()
+	defer ss.usersMutex.Unlock()
+
+	clients := ss.users[uid]
+	for i, c := range clients {
+		if c != s {
+			continue
+		}
+
+		if len(clients) == 1 {
+			delete(ss.users, uid)
+			return
+		}
+
+		newClients := make([]*Client, 0, len(clients)-1)
+		newClients = append(newClients, clients[:i]...)
+		ss.users[uid] = append(newClients, clients[i+1:]...)
+		return
+	}
 }
 
 // RemoveAndExit 删除session并退出
+// 断开的原因计入SetDisconnectCounter设置的统计
 func (ss *Store) RemoveAndExit(sid string) {
 	if sess := ss.Get(sid); sess != nil {
 		sess.Kicked()
+		if ss.disconnects != nil {
+			ss.disconnects.With("reason", sess.Reason().String()).Add(1)
+		}
+
+		if ss.onRemove != nil && sess.Flag()&FlagAuthorized != 0 {
+			ss.onRemove(sess)
+		}
 	}
 
 	ss.Remove(sid)
 }
 
+// SetCompressThreshold 设置压缩阈值, 只影响之后创建的session
+// 协商了压缩的session只压缩长度不小于n的数据帧
+func (ss *Store) SetCompressThreshold(n int) {
+	atomic.StoreInt32(&ss.compressThreshold, int32(n))
+}
+
+// SetRequestLimits 设置请求超时时间与最多同时处理的请求数量, 只影响之后创建的session
+func (ss *Store) SetRequestLimits(timeout time.Duration, maxInflight int) {
+	atomic.StoreInt64(&ss.requestTimeout, int64(timeout))
+	atomic.StoreInt32(&ss.maxInflight, int32(maxInflight))
+}
+
+// SetDisconnectCounter 设置断开连接的统计, 标签reason为断开的原因
+// 需要在创建session之前调用
+func (ss *Store) SetDisconnectCounter(counter metrics.Counter) {
+	ss.disconnects = counter
+}
+
+// OnRemove 设置已认证的session删除时的回调, 需要在创建session之前调用
+func (ss *Store) OnRemove(fn func(*Client)) {
+	ss.onRemove = fn
+}
+
 // NewStore 创建session存储器
 func NewStore(logger log.Logger) *Store {
-	return &Store{logger: logger}
+	return &Store{
+		users:  make(map[types.UID][]*Client),
+		logger: logger,
+	}
 }
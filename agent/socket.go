@@ -3,6 +3,7 @@
 package agent
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -18,8 +19,8 @@ import (
 	kitlog "github.com/go-kit/kit/log/level"
 )
 
-func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, logger log.Logger) *process.RuntimeActor {
-	socketOpts := opts.Socket
+func makeSocketRuntimeActor(serviceOpts *services.Options, conf *ConfigureOptions, store *session.Store, rt *router, logger log.Logger) *process.RuntimeActor {
+	socketOpts := conf.Read().Socket
 	if socketOpts == nil {
 		return nil
 	}
@@ -28,12 +29,18 @@ func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store
 	var socket networks.Socket
 	{
 		socket.CallBack(func(conn net.Conn, exit chan struct{}) {
-			sess := store.NewClient(conn, "", time.Duration(socketOpts.ReadDeadline)*time.Second, time.Duration(socketOpts.WriteDeadline)*time.Second, 0)
+			// 超时, 信息大小与rpm限制使用最新的配置
+			o := socketOpts
+			if m := conf.Read().Socket; m != nil {
+				o = m
+			}
+
+			sess := store.NewClient(conn, "", time.Duration(o.ReadDeadline)*time.Second, time.Duration(o.WriteDeadline)*time.Second, o.MaxMessageSize)
 			defer func() {
 				store.RemoveAndExit(sess.ID())
 			}()
 
-			socketLoop(sess, exit, socketOpts.RPMLimit, logger)
+			socketLoop(sess, exit, o.RPMLimit, rt, logger)
 		})
 	}
 
@@ -52,10 +59,23 @@ func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store
 			logger.Log("transport", "socket", "on", "shutdown")
 			socket.Shutdown()
 		},
+
+		Restart: process.RestartOnFailure,
 	}
 }
 
-func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger log.Logger) {
+var (
+	// ErrInvalidProtoVersion 无法解析的数据帧
+	ErrInvalidProtoVersion = errors.New("ErrorInvalidProtoVersion")
+
+	// ErrInvalidSeqID 请求编号与接收顺序不一致
+	ErrInvalidSeqID = errors.New("ErrorInvalidSEQID")
+)
+
+// disconnectTimeout 断开连接时等待bye帧发送完毕的时间
+const disconnectTimeout = 2 * time.Second
+
+func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, rt *router, logger log.Logger) {
 	defer func() {
 		if r := recover(); r != nil {
 			kitlog.Error(logger).Log("panic", fmt.Sprint(r))
@@ -72,7 +92,8 @@ func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger l
 	ticker := time.NewTicker(time.Second)
 	defer func() {
 		ticker.Stop()
-		kitlog.Info(logger).Log("offline", sess.ID())
+		sess.CancelRequests()
+		kitlog.Info(logger).Log("offline", sess.ID(), "reason", sess.Reason(), "replayed", sess.Replayed())
 	}()
 
 	createAt := time.Now()
@@ -87,13 +108,15 @@ func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger l
 			}
 
 			packetCounter++
-			b, err := handleFrame(sess, frame, logger)
+			b, err := handleFrame(sess, frame.Bytes(), rt, logger)
+			proto.ReleaseBytesBuffer(frame)
 			if err != nil {
+				disconnect(sess, session.DisconnectProtocolViolation, err.Error(), logger)
 				return
 			}
 
 			if b != nil {
-				sess.Send(b)
+				sess.SendFrame(b)
 			}
 
 		case <-ticker.C:
@@ -103,6 +126,7 @@ func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger l
 				if rpm1Min >= 60 {
 					// 如果在1分钟内超过了200个包,踢掉
 					if packetCounter > rpmLimit {
+						disconnect(sess, session.DisconnectRPMExceeded, "", logger)
 						return
 					}
 
@@ -113,8 +137,10 @@ func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger l
 				date := time.Now().Sub(createAt)
 				diff := date.Seconds()
 				if diff > 5 && flag&session.FlagEncrypt == 0 {
+					disconnect(sess, session.DisconnectHandshakeTimeout, "", logger)
 					return
 				} else if diff > 60 {
+					disconnect(sess, session.DisconnectAuthTimeout, "", logger)
 					return
 				}
 			}
@@ -126,24 +152,150 @@ func socketLoop(sess *session.Client, exit chan struct{}, rpmLimit int, logger l
 			return
 
 		case <-exit:
+			disconnect(sess, session.DisconnectShutdown, "", logger)
 			return
 		}
 	}
 }
 
-func handleFrame(sess *session.Client, frame []byte, logger log.Logger) ([]byte, error) {
+// handleFrame 处理一帧数据, 返回的响应由session.NewFrame创建
+// frame的内容在返回后可能被复用, 处理过程中不能保留对它的引用
+func handleFrame(sess *session.Client, frame []byte, rt *router, logger log.Logger) (*proto.BytesBuffer, error) {
 	if sess.Flag()&session.FlagEncrypt != 0 {
-		frame = sess.DecodeFrame(frame)
+		var err error
+		frame, err = sess.DecodeFrame(frame)
+		if err == session.ErrReplayedFrame {
+			kitlog.Debug(logger).Log("error", err, "sid", sess.ID())
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
 	}
 
+	// 只有通过认证的帧才计入请求编号
+	seqID := sess.SID(1)
 	req := &proto.RequestBytes{}
 	if err := req.Unmarshal(frame); err != nil {
-		return nil, errors.New("ErrorInvalidProtoVersion")
+		return nil, ErrInvalidProtoVersion
+	}
+
+	if req.V()&proto.VerCompressed != 0 {
+		w := proto.AcquireBytesBuffer()
+		defer proto.ReleaseBytesBuffer(w)
+		if err := sess.Decompress(w, req); err != nil {
+			return nil, err
+		}
 	}
 
-	if req.SID() != sess.SID() {
-		return nil, errors.New("ErrorInvalidSEQID")
+	if req.SID() != seqID {
+		return nil, ErrInvalidSeqID
 	}
 
+	// 密钥交换由代理服务器自己处理
+	// 完成交换之前不接受其它命令
+	if req.Command() == proto.InternalHandshake {
+		resp, err := handleHandshake(sess, req)
+		if err != nil {
+			return nil, err
+		}
+
+		b := session.NewFrame()
+		if err := resp.MarshalTo(b); err != nil {
+			proto.ReleaseBytesBuffer(b)
+			return nil, err
+		}
+
+		return b, nil
+	}
+
+	if sess.Flag()&session.FlagEncrypt == 0 {
+		return nil, ErrHandshakeRequired
+	}
+
+	// 本地命令会修改session状态, 按接收顺序处理
+	if rt.Local(req.Command()) {
+		resp, err := rt.Call(context.Background(), sess, req)
+		return newResponseFrame(sess, req, resp, err, logger), nil
+	}
+
+	callAsync(sess, req, rt, logger)
 	return nil, nil
 }
+
+// disconnect 发送bye帧后断开连接, 最多等待disconnectTimeout
+func disconnect(sess *session.Client, reason session.DisconnectReason, message string, logger log.Logger) {
+	kitlog.Debug(logger).Log("disconnect", sess.ID(), "reason", reason, "message", message)
+	sess.Disconnect(reason, message)
+
+	select {
+	case <-sess.GetSendExitChan():
+	case <-time.After(disconnectTimeout):
+	}
+}
+
+// callAsync 转发请求到后端服务, 不阻塞session的接收
+// 请求按SeqID登记, 超时后立即回复超时错误, 之后到达的响应将被丢弃
+func callAsync(sess *session.Client, req *proto.RequestBytes, rt *router, logger log.Logger) {
+	// req引用的数据在handleFrame返回后会被复用
+	req.Content = append([]byte(nil), req.Content...)
+	r, err := sess.BeginRequest(req.SID(), func() {
+		if b := newResponseFrame(sess, req, nil, session.ErrRequestTimeout, logger); b != nil {
+			sess.SendFrame(b)
+		}
+	})
+
+	if err != nil {
+		if b := newResponseFrame(sess, req, nil, err, logger); b != nil {
+			sess.SendFrame(b)
+		}
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				kitlog.Error(logger).Log("panic", fmt.Sprint(r), "cmd", req.Command(), "sid", sess.ID())
+			}
+		}()
+
+		resp, err := rt.Call(r.Context(), sess, req)
+		if !r.Done() {
+			kitlog.Debug(logger).Log("error", "late response dropped", "cmd", req.Command(), "seqid", req.SID(), "sid", sess.ID())
+			return
+		}
+
+		if b := newResponseFrame(sess, req, resp, err, logger); b != nil {
+			sess.SendFrame(b)
+		}
+	}()
+}
+
+// newResponseFrame 创建请求的响应, 出错时响应为错误信息
+// 无法封包时返回nil
+func newResponseFrame(sess *session.Client, req *proto.RequestBytes, resp *proto.ResponseBytes, err error, logger log.Logger) *proto.BytesBuffer {
+	if err != nil {
+		// 内部错误不会返回给客户端, 在这里记录原始错误
+		if proto.FromError(err) == proto.ErrInternal {
+			kitlog.Error(logger).Log("error", err, "cmd", req.Command(), "sid", sess.ID())
+		} else {
+			kitlog.Debug(logger).Log("error", err, "cmd", req.Command(), "sid", sess.ID())
+		}
+
+		resp = &proto.ResponseBytes{
+			Ver:    req.V(),
+			Cmd:    req.Command(),
+			SubCmd: req.SubCommand(),
+			SeqID:  req.SID(),
+			Err:    err,
+		}
+	}
+
+	b := session.NewFrame()
+	if err := resp.MarshalTo(b); err != nil {
+		proto.ReleaseBytesBuffer(b)
+		kitlog.Error(logger).Log("error", err, "cmd", req.Command(), "sid", sess.ID())
+		return nil
+	}
+
+	return b
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/doublemo/balala/agent/service"
+	"github.com/doublemo/balala/agent/session"
+	"github.com/doublemo/balala/cores/process"
+	"github.com/doublemo/balala/cores/types"
+	"github.com/doublemo/balala/internal/serviceid"
+	"github.com/doublemo/balala/sss/proto/pb"
+	sssservice "github.com/doublemo/balala/sss/service"
+	ssstransport "github.com/doublemo/balala/sss/transport"
+	"github.com/go-kit/kit/log"
+	kitlog "github.com/go-kit/kit/log/level"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// kickEvent pb.EventKick 事件内容
+type kickEvent struct {
+	// UserID 用户ID
+	UserID uint64 `json:"uid"`
+
+	// Before sss分配的创建时间戳, 只踢掉创建时间戳在此之前的连接
+	// 各代理服务器的本地时间不一定一致, 连接的先后以sss的时间戳为准
+	Before int64 `json:"before"`
+}
+
+// sessionVersionParam 连接在sss中的创建时间戳, 保存在session参数中
+const sessionVersionParam = "SSSVersion"
+
+// sessionStateTimeout 请求sss的超时时间
+const sessionStateTimeout = 5 * time.Second
+
+// sessionState 会话状态服务(sss)连接
+// 已认证的连接登记到sss, 用于统计用户在集群中的连接数以及在多个代理服务器之间同步踢人
+type sessionState struct {
+	// id 订阅者唯一ID
+	id string
+
+	// machineID 当前代理服务器的机器码, 登记连接时作为RemoteServID
+	machineID string
+
+	// store session存储
+	store *session.Store
+
+	// jwtToken 内部服务通信认证
+	jwtToken []byte
+
+	// addr 当前连接的sss地址
+	addr string
+
+	// conn 当前连接
+	conn *grpc.ClientConn
+
+	// cli sss服务接口
+	cli sssservice.GRPC
+
+	// logger 日志
+	logger log.Logger
+
+	// mutex lock
+	mutex sync.Mutex
+}
+
+// Sessions 查询用户在集群中的所有连接, 按sss分配的创建时间戳排序
+func (ss *sessionState) Sessions(ctx context.Context, uid types.UID) ([]*pb.SessionStateServerAPI_NewRequest, error) {
+	conn, cli, err := ss.client()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.SessionStateServerAPI_FindByParamRequest{Key: "UserID", Value: strconv.FormatUint(uid.Uint64(), 10)}
+	var sessions []*pb.SessionStateServerAPI_NewRequest
+	for {
+		resp, err := cli.FindByParam(ctx, req)
+		if err != nil {
+			ss.reset(conn)
+			return nil, err
+		}
+
+		sessions = append(sessions, resp.GetSessions()...)
+		if resp.GetNextCursor() == "" {
+			break
+		}
+
+		req.Cursor = resp.GetNextCursor()
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].GetVersion() < sessions[j].GetVersion()
+	})
+
+	return sessions, nil
+}
+
+// Register 将认证通过的连接登记到sss, 返回用户在集群中的所有连接
+// sss分配的创建时间戳保存在session参数SSSVersion中, 踢人时以此判断连接的先后
+func (ss *sessionState) Register(ctx context.Context, uid types.UID, sess *session.Client) ([]*pb.SessionStateServerAPI_NewRequest, error) {
+	conn, cli, err := ss.client()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = cli.New(ctx, &pb.SessionStateServerAPI_NewRequest{
+		ClientID:     sess.ID(),
+		RemoteServID: ss.machineID,
+		Params: []*pb.SessionStateServerAPI_Param{
+			{Key: "UserID", Value: strconv.FormatUint(uid.Uint64(), 10)},
+		},
+	})
+
+	if err != nil {
+		ss.reset(conn)
+		return nil, err
+	}
+
+	sessions, err := ss.Sessions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range sessions {
+		if m.GetClientID() == sess.ID() {
+			sess.SetParam(sessionVersionParam, m.GetVersion())
+			break
+		}
+	}
+
+	return sessions, nil
+}
+
+// Unregister 连接断开后从sss中删除, 异步进行, 失败时只记录日志
+// 删除失败的连接在代理服务器的注册信息删除后由sss清理
+func (ss *sessionState) Unregister(sess *session.Client) {
+	sid := sess.ID()
+	go func() {
+		conn, cli, err := ss.client()
+		if err != nil {
+			kitlog.Debug(ss.logger).Log("error", err, "unregister", sid)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sessionStateTimeout)
+		defer cancel()
+
+		if _, err = cli.Remove(ctx, &pb.SessionStateServerAPI_NewRequest{ClientID: sid}); err != nil {
+			kitlog.Error(ss.logger).Log("error", err, "unregister", sid)
+			ss.reset(conn)
+		}
+	}()
+}
+
+// Kick 通知所有代理服务器踢掉用户在sss中创建时间戳早于before的连接, 不包括sid
+// 广播异步进行, 失败时只记录日志
+func (ss *sessionState) Kick(uid types.UID, sid string, before int64) {
+	body, err := json.Marshal(&kickEvent{UserID: uid.Uint64(), Before: before})
+	if err != nil {
+		kitlog.Error(ss.logger).Log("error", err)
+		return
+	}
+
+	go func() {
+		conn, cli, err := ss.client()
+		if err != nil {
+			kitlog.Error(ss.logger).Log("error", err, "event", "kick", "uid", uid)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sessionStateTimeout)
+		defer cancel()
+
+		_, err = cli.Broadcast(ctx, &pb.SessionStateServerAPI_BroadcastRequest{
+			Actions: []*pb.SessionStateServerAPI_Event{
+				{Action: pb.EventKick, ClientID: sid, Body: body},
+			},
+		})
+
+		if err != nil {
+			kitlog.Error(ss.logger).Log("error", err, "event", "kick", "uid", uid)
+			ss.reset(conn)
+		}
+	}()
+}
+
+// handleEvent 处理sss推送的事件
+func (ss *sessionState) handleEvent(frame *pb.SessionStateServerAPI_BroadcastResponse) error {
+	for _, action := range frame.GetActions() {
+		switch action.GetAction() {
+		case pb.EventKick:
+			var event kickEvent
+			if err := json.Unmarshal(action.GetBody(), &event); err != nil {
+				kitlog.Error(ss.logger).Log("error", err, "event", "kick")
+				continue
+			}
+
+			ss.kickBefore(types.UID(event.UserID), action.GetClientID(), event.Before)
+		}
+	}
+
+	return nil
+}
+
+// kickBefore 踢掉用户在sss中创建时间戳早于before的本地连接, 不包括sid
+// 没有登记到sss的连接无法判断先后, 不会被踢掉
+func (ss *sessionState) kickBefore(uid types.UID, sid string, before int64) {
+	for _, c := range ss.store.GetByUserID(uid) {
+		if c.ID() == sid {
+			continue
+		}
+
+		m, ok := c.Param(sessionVersionParam)
+		if !ok {
+			continue
+		}
+
+		if version, ok := m.(int64); !ok || version >= before {
+			continue
+		}
+
+		kitlog.Debug(ss.logger).Log("kick", c.ID(), "uid", uid, "reason", ErrLoginElsewhere)
+		go c.Disconnect(session.DisconnectLoginElsewhere, ErrLoginElsewhere.Error())
+	}
+}
+
+// client 获取sss连接, 没有连接时随机选择一个sss服务
+func (ss *sessionState) client() (*grpc.ClientConn, sssservice.GRPC, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.cli != nil {
+		return ss.conn, ss.cli, nil
+	}
+
+	instance, ok := service.Caches.RndOnce(serviceid.SessionStateID)
+	if !ok {
+		return nil, nil, ErrServiceUnavailable
+	}
+
+	addr := instance.IP + ":" + instance.Port
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ss.addr = addr
+	ss.conn = conn
+	ss.cli = ssstransport.NewGRPCClient(conn, stdopentracing.GlobalTracer(), nil, ss.jwtToken, ss.logger)
+	return ss.conn, ss.cli, nil
+}
+
+// reset 连接出错后关闭连接, 下次使用时重新选择sss服务
+func (ss *sessionState) reset(conn *grpc.ClientConn) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.conn == nil || ss.conn != conn {
+		return
+	}
+
+	ss.conn.Close()
+	ss.conn = nil
+	ss.cli = nil
+	ss.addr = ""
+}
+
+// Close 关闭连接
+func (ss *sessionState) Close() {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.conn != nil {
+		ss.conn.Close()
+		ss.conn = nil
+		ss.cli = nil
+	}
+}
+
+// newSessionState 创建sss连接
+func newSessionState(id, machineID string, store *session.Store, jwtToken []byte, logger log.Logger) *sessionState {
+	return &sessionState{
+		id:        id,
+		machineID: machineID,
+		store:     store,
+		jwtToken:  jwtToken,
+		logger:    logger,
+	}
+}
+
+// makeSessionStateRuntimeActor 订阅sss事件, 连接断开后自动重连
+func makeSessionStateRuntimeActor(ss *sessionState, logger log.Logger) *process.RuntimeActor {
+	exitChan := make(chan struct{})
+	return &process.RuntimeActor{
+		Exec: func() error {
+			param := map[string]string{
+				"id":        ss.id,
+				"serviceid": strconv.FormatInt(int64(serviceid.AgentID), 10),
+				"events":    strconv.FormatInt(int64(pb.EventKick), 10),
+			}
+
+			for {
+				conn, _, err := ss.client()
+				if err == nil {
+					var stream ssstransport.GRPCStream
+					stream, err = ssstransport.NewGRPCSubscribe(conn, ss.jwtToken, param)
+					if err == nil {
+						done := make(chan struct{})
+						go func() {
+							select {
+							case <-exitChan:
+								stream.Close()
+							case <-done:
+							}
+						}()
+
+						err = stream.Recv(ss.handleEvent)
+						close(done)
+						stream.Close()
+					}
+
+					ss.reset(conn)
+				}
+
+				select {
+				case <-exitChan:
+					return nil
+				case <-time.After(time.Second):
+				}
+
+				if err != ErrServiceUnavailable {
+					kitlog.Debug(logger).Log("sss", "subscribe", "error", err)
+				}
+			}
+		},
+
+		Interrupt: func(err error) {
+			if err != nil {
+				kitlog.Error(logger).Log("sss", "subscribe", "error", err)
+			}
+		},
+
+		Close: func() {
+			close(exitChan)
+			ss.Close()
+		},
+	}
+}
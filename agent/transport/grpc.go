@@ -49,8 +49,8 @@ func (s *GRPCServer) Call(ctx context.Context, in *pb.Request) (*pb.Response, er
 
 // Stream 流支持
 func (s *GRPCServer) Stream(stream pb.Internal_StreamServer) error {
-	s.stream.ServeGRPC(context.Background(), stream)
-	return nil
+	_, _, err := s.stream.ServeGRPC(stream.Context(), stream)
+	return err
 }
 
 // NewGRPCServer 创建内部服务grpc server
@@ -137,8 +137,8 @@ func MakeRetry(instancer sd.Instancer, factory sd.Factory, retryMax int, retryTi
 }
 
 // MakeRetryStream 流服务支持
-func MakeRetryStream(instancer sd.Instancer, logger log.Logger) endpoint.Endpoint {
-	endpointer := sd.NewEndpointer(instancer, MakeFactoryStream(), logger)
+func MakeRetryStream(instancer sd.Instancer, jwtToken []byte, logger log.Logger) endpoint.Endpoint {
+	endpointer := sd.NewEndpointer(instancer, MakeFactoryStream(jwtToken), logger)
 	balancer := lb.NewRoundRobin(endpointer)
 	return func(ctx context.Context, req interface{}) (response interface{}, err error) {
 		fn, err := balancer.Endpoint()
@@ -155,33 +155,52 @@ func MakeRetryStream(instancer sd.Instancer, logger log.Logger) endpoint.Endpoin
 }
 
 // MakeFactoryStream 创建流服务支持
-func MakeFactoryStream() sd.Factory {
+func MakeFactoryStream(jwtToken []byte) sd.Factory {
 	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
-		conn, err := grpc.Dial(instance, grpc.WithInsecure())
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure())
 		if err != nil {
 			return nil, nil, err
 		}
-		return makeStreamEndpoint(conn), conn, nil
+		return makeStreamEndpoint(conn, jwtToken), conn, nil
 	}
 }
 
-func makeStreamEndpoint(conn *grpc.ClientConn) endpoint.Endpoint {
+func makeStreamEndpoint(conn *grpc.ClientConn, jwtToken []byte) endpoint.Endpoint {
 	return func(ctx context.Context, req interface{}) (response interface{}, err error) {
 		param, ok := req.(map[string]string)
 		if !ok {
 			return nil, errors.New("Invalid params")
 		}
 
-		cli := pb.NewInternalClient(conn)
-		ctx, cancel := context.WithCancel(context.Background())
-		stream, err := cli.Stream(metadata.NewOutgoingContext(ctx, metadata.New(param)))
-		if err != nil {
-			cancel()
-			return nil, err
-		}
+		return NewGRPCStream(conn, jwtToken, param)
+	}
+}
 
-		return &DefaultGRPCStream{stream: stream, cancel: cancel}, nil
+// NewGRPCStream 在指定连接上创建流
+// 用于直接连接持有目标session的代理服务器
+func NewGRPCStream(conn *grpc.ClientConn, jwtToken []byte, param map[string]string) (GRPCStream, error) {
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{}).SignedString(jwtToken)
+	if err != nil {
+		return nil, err
 	}
+
+	md := metadata.New(param)
+	md.Set("authorization", "Bearer "+token)
+
+	cli := pb.NewInternalClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cli.Stream(metadata.NewOutgoingContext(ctx, md))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &DefaultGRPCStream{stream: stream, cancel: cancel}, nil
 }
 
 // MakeFactoryCall Call
@@ -17,8 +17,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, logger log.Logger) *process.RuntimeActor {
-	websocketOpts := opts.WebSocket
+func makeWebsocketRuntimeActor(serviceOpts *services.Options, conf *ConfigureOptions, store *session.Store, rt *router, logger log.Logger) *process.RuntimeActor {
+	websocketOpts := conf.Read().WebSocket
 	if websocketOpts == nil {
 		return nil
 	}
@@ -46,7 +46,13 @@ func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, sto
 			return
 		}
 
-		webscoketHandler(ctx.Writer, ctx.Request, webSocketUpgrader, store, websocketOpts, logger)
+		// 超时, 帧大小与rpm限制使用最新的配置
+		o := websocketOpts
+		if m := conf.Read().WebSocket; m != nil {
+			o = m
+		}
+
+		webscoketHandler(ctx.Writer, ctx.Request, webSocketUpgrader, store, o, rt, logger)
 	})
 
 	// http server
@@ -77,11 +83,13 @@ func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, sto
 			logger.Log("transport", "websocket", "on", "shutdown")
 			s.Shutdown(context.Background())
 		},
+
+		Restart: process.RestartOnFailure,
 	}
 }
 
 // webscoketHandler WebSocket 处理
-func webscoketHandler(w http.ResponseWriter, req *http.Request, upgrader websocket.Upgrader, store *session.Store, websocketOpts *WebSocketOptions, logger log.Logger) {
+func webscoketHandler(w http.ResponseWriter, req *http.Request, upgrader websocket.Upgrader, store *session.Store, websocketOpts *WebSocketOptions, rt *router, logger log.Logger) {
 	conn, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
 		kitlog.Error(logger).Log("error", err)
@@ -97,5 +105,5 @@ func webscoketHandler(w http.ResponseWriter, req *http.Request, upgrader websock
 		conn.Close()
 	}()
 
-	socketLoop(sess, exit, websocketOpts.RPMLimit, logger)
+	socketLoop(sess, exit, websocketOpts.RPMLimit, rt, logger)
 }
@@ -0,0 +1,796 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/doublemo/balala/cores/proto"
+)
+
+// basicType 基础类型的读写方法
+type basicType struct {
+	// kind 类型分类 bool, int, uint, float, string
+	kind string
+
+	// method BytesBuffer读写方法的后缀
+	method string
+
+	// native 读写方法使用的类型
+	native string
+}
+
+var basicTypes = map[string]basicType{
+	"bool":    {"bool", "Bool", "bool"},
+	"int":     {"int", "", "int64"},
+	"int8":    {"int", "Int8", "int8"},
+	"int16":   {"int", "Int16", "int16"},
+	"int32":   {"int", "Int32", "int32"},
+	"rune":    {"int", "Int32", "int32"},
+	"int64":   {"int", "Int64", "int64"},
+	"uint":    {"uint", "", "uint64"},
+	"uint8":   {"uint", "Uint8", "uint8"},
+	"byte":    {"uint", "Uint8", "uint8"},
+	"uint16":  {"uint", "Uint16", "uint16"},
+	"uint32":  {"uint", "Uint32", "uint32"},
+	"uint64":  {"uint", "Uint64", "uint64"},
+	"float32": {"float", "Float32", "float32"},
+	"float64": {"float", "Float64", "float64"},
+	"string":  {"string", "", "string"},
+}
+
+// binField 参与编码的字段
+type binField struct {
+	name string
+	typ  ast.Expr
+	opts proto.BinTag
+}
+
+// generator 代码生成
+type generator struct {
+	// pkg 包名
+	pkg string
+
+	// proto proto包的引用前缀
+	proto string
+
+	// types 包内定义的类型
+	types map[string]ast.Expr
+
+	// queue 等待生成的结构体
+	queue []string
+
+	// generated 已经生成的结构体
+	generated map[string]bool
+
+	// names 生成的结构体, 按生成顺序
+	names []string
+
+	// depth 循环变量深度
+	depth int
+
+	// sampling 生成测试数据时正在赋值的结构体
+	sampling map[string]bool
+
+	// seq 测试数据的序号, 使各字段的值不同
+	seq int
+
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate 生成封包与解包方法
+func (g *generator) generate(names []string) ([]byte, error) {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if err := g.enqueue(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var body bytes.Buffer
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+
+		g.buf.Reset()
+		if err := g.generateType(name); err != nil {
+			return nil, err
+		}
+
+		body.Write(g.buf.Bytes())
+	}
+
+	g.buf.Reset()
+	g.printf("// Code generated by packgen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkg)
+	if g.proto != "" {
+		g.printf("import \"github.com/doublemo/balala/cores/proto\"\n\n")
+	}
+
+	g.buf.Write(body.Bytes())
+	return format.Source(g.buf.Bytes())
+}
+
+// generateTest 生成测试与基准测试
+// 通过底层类型相同但没有方法的类型对比反射方式, 测试数据由sample生成
+func (g *generator) generateTest() ([]byte, error) {
+	g.buf.Reset()
+	g.printf("// Code generated by packgen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import (\n\"bytes\"\n\"reflect\"\n\"testing\"\n\n")
+	if g.proto != "" {
+		g.printf("\"github.com/doublemo/balala/cores/proto\"\n")
+	}
+	g.printf(")\n\n")
+
+	p := g.proto
+	names := append([]string{}, g.names...)
+	sort.Strings(names)
+	for _, name := range names {
+		title := strings.Title(name)
+		reflectName := "packgenReflect" + title
+		sampleName := "packgenSample" + title
+		g.printf("type %s %s\n\n", reflectName, name)
+
+		g.printf("func %s() *%s {\nv := &%s{}\n", sampleName, name, name)
+		g.sampling = map[string]bool{name: true}
+		if err := g.sampleFields("v", g.types[name].(*ast.StructType)); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		g.printf("return v\n}\n\n")
+
+		g.printf("func TestPackgen%s(t *testing.T) {\n", title)
+		g.printf("v := %s()\n", sampleName)
+		g.printf("var w1, w2 %sBytesBuffer\n", p)
+		g.printf("data, err := %sPack(&w1, v)\nif err != nil {\nt.Fatal(err)\n}\n\n", p)
+		g.printf("expected, err := %sPack(&w2, (*%s)(v))\nif err != nil {\nt.Fatal(err)\n}\n\n", p, reflectName)
+		g.printf("if !bytes.Equal(data, expected) {\nt.Fatalf(\"Not Equal:\\nReceived: '%%v'\\nExpected: '%%v'\\n\", data, expected)\n}\n\n")
+		g.printf("out, out2 := &%s{}, &%s{}\n", name, reflectName)
+		g.printf("if err := %sUnpack(%sNewBytesBuffer(data), out); err != nil {\nt.Fatal(err)\n}\n\n", p, p)
+		g.printf("if err := %sUnpack(%sNewBytesBuffer(data), out2); err != nil {\nt.Fatal(err)\n}\n\n", p, p)
+		g.printf("if !reflect.DeepEqual(out, (*%s)(out2)) {\nt.Fatalf(\"Not Equal:\\nReceived: '%%+v'\\nExpected: '%%+v'\\n\", out, out2)\n}\n", name)
+		g.printf("}\n\n")
+
+		for _, m := range []struct{ suffix, value, assign, call string }{
+			{"Pack", sampleName + "()", "err", "v.Pack(%sNewBytesBuffer(buf[:0]))"},
+			{"PackReflect", "(*" + reflectName + ")(" + sampleName + "())", "_, err", "%sPack(%sNewBytesBuffer(buf[:0]), v)"},
+		} {
+			g.printf("func BenchmarkPackgen%s%s(b *testing.B) {\n", title, m.suffix)
+			g.printf("v := %s\nbuf := make([]byte, 0, %sPacketMaxLimit)\n", m.value, p)
+			g.benchLoop(m.assign, m.call)
+		}
+
+		for _, m := range []struct{ suffix, typ, call string }{
+			{"Unpack", name, "v.Unpack(%sNewBytesBuffer(data))"},
+			{"UnpackReflect", reflectName, "%sUnpack(%sNewBytesBuffer(data), v)"},
+		} {
+			g.printf("func BenchmarkPackgen%s%s(b *testing.B) {\n", title, m.suffix)
+			g.printf("var w %sBytesBuffer\ndata, err := %sPack(&w, %s())\nif err != nil {\nb.Fatal(err)\n}\n\n", p, p, sampleName)
+			g.printf("v := &%s{}\n", m.typ)
+			g.benchLoop("err", m.call)
+		}
+	}
+
+	return format.Source(g.buf.Bytes())
+}
+
+// benchLoop 生成基准测试的循环, call中的%s为proto包的引用前缀
+func (g *generator) benchLoop(assign, call string) {
+	call = strings.Replace(call, "%s", g.proto, -1)
+	g.printf("b.ReportAllocs()\nb.ResetTimer()\n")
+	g.printf("for i := 0; i < b.N; i++ {\nif %s := %s; err != nil {\nb.Fatal(err)\n}\n}\n}\n\n", assign, call)
+}
+
+// sampleFields 生成为结构体字段赋值的代码
+func (g *generator) sampleFields(expr string, st *ast.StructType) error {
+	fields, err := g.fields(expr, st)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if err := g.sample(expr+"."+field.name, field.typ, field.opts); err != nil {
+			return fmt.Errorf("%s: %v", field.name, err)
+		}
+	}
+
+	return nil
+}
+
+// sample 生成为expr赋非零值的代码, 值满足bin标签的要求
+// 指向正在赋值的结构体的指针与切片保持零值, 避免无限递归
+func (g *generator) sample(expr string, t ast.Expr, opts proto.BinTag) error {
+	underlying, structName, isStruct := g.resolve(t)
+	if isStruct {
+		if g.sampling[structName] {
+			return fmt.Errorf("recursive type %s", structName)
+		}
+
+		g.sampling[structName] = true
+		defer delete(g.sampling, structName)
+		return g.sampleFields(expr, underlying.(*ast.StructType))
+	}
+
+	switch m := underlying.(type) {
+	case *ast.Ident:
+		basic, ok := basicTypes[m.Name]
+		if !ok {
+			return fmt.Errorf("unsupported type %s", m.Name)
+		}
+
+		g.seq++
+		switch basic.kind {
+		case "bool":
+			g.printf("%s = true\n", expr)
+
+		case "string":
+			n := opts.Fixed
+			if n < 1 {
+				n = 3
+			}
+
+			g.printf("%s = %q\n", expr, strings.Repeat(string(rune('a'+g.seq%26)), n))
+
+		case "float":
+			g.printf("%s = %d.5\n", expr, g.seq%100)
+
+		default:
+			g.printf("%s = %d\n", expr, g.seq%100+1)
+		}
+
+		return nil
+
+	case *ast.StarExpr:
+		if g.recursive(m.X) {
+			return nil
+		}
+
+		g.printf("%s = new(%s)\n", expr, typeString(m.X))
+		return g.sample("(*"+expr+")", m.X, opts)
+
+	case *ast.ArrayType:
+		if m.Len == nil {
+			if g.recursive(m.Elt) {
+				return nil
+			}
+
+			n := opts.Fixed
+			if n < 1 {
+				n = 2
+			}
+
+			g.printf("%s = make(%s, %d)\n", expr, typeString(t), n)
+		}
+
+		i := g.loopVar()
+		g.printf("for %s := range %s {\n", i, expr)
+		if err := g.sample(expr+"["+i+"]", m.Elt, opts.Elem()); err != nil {
+			return err
+		}
+
+		g.printf("}\n")
+		return nil
+
+	case *ast.StructType:
+		return g.sampleFields(expr, m)
+	}
+
+	return fmt.Errorf("unsupported type %s", typeString(t))
+}
+
+// recursive 类型是否经过指针或切片引用正在赋值的结构体
+func (g *generator) recursive(t ast.Expr) bool {
+	for {
+		underlying, structName, isStruct := g.resolve(t)
+		if isStruct {
+			return g.sampling[structName]
+		}
+
+		switch m := underlying.(type) {
+		case *ast.StarExpr:
+			t = m.X
+
+		case *ast.ArrayType:
+			t = m.Elt
+
+		default:
+			return false
+		}
+	}
+}
+
+// enqueue 加入生成队列, 只接受包内定义的结构体
+func (g *generator) enqueue(name string) error {
+	if g.generated[name] {
+		return nil
+	}
+
+	t, ok := g.types[name]
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+
+	if _, ok := t.(*ast.StructType); !ok {
+		return fmt.Errorf("type %s is not a struct", name)
+	}
+
+	g.generated[name] = true
+	g.names = append(g.names, name)
+	g.queue = append(g.queue, name)
+	return nil
+}
+
+func (g *generator) generateType(name string) error {
+	st := g.types[name].(*ast.StructType)
+	fields, err := g.fields(name, st)
+	if err != nil {
+		return err
+	}
+
+	g.printf("// Pack 封包, 实现%sFastPack\n", g.proto)
+	g.printf("func (v *%s) Pack(w *%sBytesBuffer) error {\n", name, g.proto)
+	for _, field := range fields {
+		if err := g.pack("v."+field.name, field.typ, field.opts); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, field.name, err)
+		}
+
+		g.printf("\n")
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.printf("// Unpack 解包, 实现%sFastUnpack\n", g.proto)
+	g.printf("func (v *%s) Unpack(r *%sBytesBuffer) error {\n", name, g.proto)
+	for _, field := range fields {
+		if err := g.unpack("v."+field.name, field.typ, field.opts); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, field.name, err)
+		}
+
+		g.printf("\n")
+	}
+	g.printf("return nil\n}\n\n")
+	return nil
+}
+
+// fields 返回结构体参与编码的字段, 排序规则与proto包一致
+func (g *generator) fields(name string, st *ast.StructType) ([]binField, error) {
+	var (
+		fields []binField
+		tags   []proto.BinTag
+	)
+
+	for _, f := range st.Fields.List {
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			tag = reflect.StructTag(s).Get("bin")
+		}
+
+		opts, err := proto.ParseBinTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+
+		names := f.Names
+		if len(names) < 1 {
+			// 嵌入字段
+			m := embeddedName(f.Type)
+			if m == "" && !opts.Skip {
+				return nil, fmt.Errorf("%s: unsupported embedded field %s", name, typeString(f.Type))
+			}
+
+			names = []*ast.Ident{ast.NewIdent(m)}
+		}
+
+		for _, n := range names {
+			if n.Name == "_" && !opts.Skip {
+				return nil, fmt.Errorf("%s: blank field is not supported", name)
+			}
+
+			fields = append(fields, binField{name: n.Name, typ: f.Type, opts: opts})
+			tags = append(tags, opts)
+		}
+	}
+
+	// 字段顺序与反射方式使用相同的规则
+	order := proto.BinOrder(tags)
+	m := make([]binField, len(order))
+	for i, index := range order {
+		m[i] = fields[index]
+	}
+
+	return m, nil
+}
+
+// resolve 解析类型, 返回底层类型以及是否为包内的结构体
+func (g *generator) resolve(t ast.Expr) (ast.Expr, string, bool) {
+	for {
+		ident, ok := t.(*ast.Ident)
+		if !ok {
+			return t, "", false
+		}
+
+		if _, ok := basicTypes[ident.Name]; ok {
+			return t, "", false
+		}
+
+		m, ok := g.types[ident.Name]
+		if !ok {
+			return t, "", false
+		}
+
+		if _, ok := m.(*ast.StructType); ok {
+			return m, ident.Name, true
+		}
+
+		t = m
+	}
+}
+
+func (g *generator) loopVar() string {
+	g.depth++
+	return fmt.Sprintf("i%d", g.depth)
+}
+
+// pack 生成expr的封包代码
+func (g *generator) pack(expr string, t ast.Expr, opts proto.BinTag) error {
+	underlying, structName, isStruct := g.resolve(t)
+	if opts.Optional {
+		zero, err := g.zero(underlying, isStruct)
+		if err != nil {
+			return err
+		}
+
+		g.printf("if %s == %s {\nif err := w.WriteBool(false); err != nil {\nreturn err\n}\n} else {\n", expr, zero)
+		g.printf("if err := w.WriteBool(true); err != nil {\nreturn err\n}\n")
+		if err := g.pack(expr, t, opts.Value()); err != nil {
+			return err
+		}
+
+		g.printf("}\n")
+		return nil
+	}
+
+	if isStruct {
+		if err := g.enqueue(structName); err != nil {
+			return err
+		}
+
+		g.printf("if err := %s.Pack(w); err != nil {\nreturn err\n}\n", expr)
+		return nil
+	}
+
+	switch m := underlying.(type) {
+	case *ast.Ident:
+		basic, ok := basicTypes[m.Name]
+		if !ok {
+			return fmt.Errorf("unsupported type %s", m.Name)
+		}
+
+		return g.packBasic(expr, basic, opts)
+
+	case *ast.StarExpr:
+		g.printf("if %s != nil {\n", expr)
+		if err := g.pack("(*"+expr+")", m.X, opts); err != nil {
+			return err
+		}
+
+		g.printf("}\n")
+		return nil
+
+	case *ast.ArrayType:
+		if m.Len != nil {
+			i := g.loopVar()
+			g.printf("for %s := range %s {\n", i, expr)
+			if err := g.pack(expr+"["+i+"]", m.Elt, opts.Elem()); err != nil {
+				return err
+			}
+
+			g.printf("}\n")
+			return nil
+		}
+
+		if g.isByte(m.Elt) {
+			g.packLen(expr, opts)
+			g.printf("if err := w.WriteBytes(%s...); err != nil {\nreturn err\n}\n", expr)
+			return nil
+		}
+
+		g.packLen(expr, opts)
+		i := g.loopVar()
+		g.printf("for %s := range %s {\n", i, expr)
+		if err := g.pack(expr+"["+i+"]", m.Elt, opts.Elem()); err != nil {
+			return err
+		}
+
+		g.printf("}\n")
+		return nil
+
+	case *ast.StructType:
+		fields, err := g.fields(expr, m)
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			if err := g.pack(expr+"."+field.name, field.typ, field.opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported type %s", typeString(t))
+}
+
+// packLen 生成长度前缀的封包代码
+func (g *generator) packLen(expr string, opts proto.BinTag) {
+	switch {
+	case opts.Fixed > 0:
+		g.printf("if len(%s) != %d {\nreturn %sErrFixedLength\n}\n", expr, opts.Fixed, g.proto)
+
+	case opts.Len32:
+		g.printf("if uint64(len(%s)) > 0xffffffff {\nreturn %sErrLengthOverflow\n}\n", expr, g.proto)
+		g.printf("if err := w.WriteUint32(uint32(len(%s))); err != nil {\nreturn err\n}\n", expr)
+
+	default:
+		g.printf("if len(%s) > 0xffff {\nreturn %sErrLengthOverflow\n}\n", expr, g.proto)
+		g.printf("if err := w.WriteUint16(uint16(len(%s))); err != nil {\nreturn err\n}\n", expr)
+	}
+}
+
+func (g *generator) packBasic(expr string, basic basicType, opts proto.BinTag) error {
+	switch {
+	case basic.kind == "string":
+		g.packLen(expr, opts)
+		g.printf("if err := w.WriteBytes([]byte(%s)...); err != nil {\nreturn err\n}\n", expr)
+		return nil
+
+	case opts.Varint && basic.kind == "int":
+		g.printf("if err := w.WriteVarint(int64(%s)); err != nil {\nreturn err\n}\n", expr)
+		return nil
+
+	case opts.Varint && basic.kind == "uint":
+		g.printf("if err := w.WriteUvarint(uint64(%s)); err != nil {\nreturn err\n}\n", expr)
+		return nil
+
+	case basic.method == "":
+		return fmt.Errorf("type %s requires bin:\"varint\"", basic.native)
+	}
+
+	g.printf("if err := w.Write%s(%s(%s)); err != nil {\nreturn err\n}\n", basic.method, basic.native, expr)
+	return nil
+}
+
+// unpack 生成expr的解包代码
+func (g *generator) unpack(expr string, t ast.Expr, opts proto.BinTag) error {
+	underlying, structName, isStruct := g.resolve(t)
+	if opts.Optional {
+		zero, err := g.zero(underlying, isStruct)
+		if err != nil {
+			return err
+		}
+
+		g.printf("{\nok, err := r.ReadBool()\nif err != nil {\nreturn err\n}\n\n")
+		g.printf("if !ok {\n%s = %s\n} else {\n", expr, zero)
+		if err := g.unpack(expr, t, opts.Value()); err != nil {
+			return err
+		}
+
+		g.printf("}\n}\n")
+		return nil
+	}
+
+	if isStruct {
+		if err := g.enqueue(structName); err != nil {
+			return err
+		}
+
+		g.printf("if err := %s.Unpack(r); err != nil {\nreturn err\n}\n", expr)
+		return nil
+	}
+
+	typ := typeString(t)
+	switch m := underlying.(type) {
+	case *ast.Ident:
+		basic, ok := basicTypes[m.Name]
+		if !ok {
+			return fmt.Errorf("unsupported type %s", m.Name)
+		}
+
+		return g.unpackBasic(expr, typ, basic, opts)
+
+	case *ast.StarExpr:
+		// pack不写入nil指针, 解包时总是创建新的对象
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, typeString(m.X))
+		return g.unpack("(*"+expr+")", m.X, opts)
+
+	case *ast.ArrayType:
+		if m.Len != nil {
+			i := g.loopVar()
+			g.printf("for %s := range %s {\n", i, expr)
+			if err := g.unpack(expr+"["+i+"]", m.Elt, opts.Elem()); err != nil {
+				return err
+			}
+
+			g.printf("}\n")
+			return nil
+		}
+
+		g.printf("{\n")
+		g.unpackLen(opts)
+		if g.isByte(m.Elt) {
+			g.printf("m, err := r.ReadBytes(int(n))\nif err != nil {\nreturn err\n}\n\n")
+			g.printf("%s = make(%s, len(m))\ncopy(%s, m)\n}\n", expr, typ, expr)
+			return nil
+		}
+
+		// 每个元素至少占用一个字节, 防止错误的长度分配过多内存
+		g.printf("if int(n) > len(r.Bytes()) {\nreturn %sErrOutOfRange\n}\n\n", g.proto)
+		g.printf("%s = make(%s, n)\n", expr, typ)
+		i := g.loopVar()
+		g.printf("for %s := range %s {\n", i, expr)
+		if err := g.unpack(expr+"["+i+"]", m.Elt, opts.Elem()); err != nil {
+			return err
+		}
+
+		g.printf("}\n}\n")
+		return nil
+
+	case *ast.StructType:
+		fields, err := g.fields(expr, m)
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			if err := g.unpack(expr+"."+field.name, field.typ, field.opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported type %s", typ)
+}
+
+// unpackLen 生成读取长度前缀的代码, 长度保存在变量n
+func (g *generator) unpackLen(opts proto.BinTag) {
+	switch {
+	case opts.Fixed > 0:
+		g.printf("n := %d\n", opts.Fixed)
+
+	case opts.Len32:
+		g.printf("n, err := r.ReadUint32()\nif err != nil {\nreturn err\n}\n\n")
+		g.printf("if n > 0x7fffffff {\nreturn %sErrLengthOverflow\n}\n\n", g.proto)
+
+	default:
+		g.printf("n, err := r.ReadUint16()\nif err != nil {\nreturn err\n}\n\n")
+	}
+}
+
+func (g *generator) unpackBasic(expr, typ string, basic basicType, opts proto.BinTag) error {
+	switch {
+	case basic.kind == "string":
+		g.printf("{\n")
+		g.unpackLen(opts)
+		g.printf("m, err := r.ReadBytes(int(n))\nif err != nil {\nreturn err\n}\n\n")
+		g.printf("%s = %s(m)\n}\n", expr, typ)
+		return nil
+
+	case opts.Varint && basic.kind == "int":
+		g.printf("{\nm, err := r.ReadVarint()\nif err != nil {\nreturn err\n}\n\n")
+		g.printf("if int64(%s(m)) != m {\nreturn %sErrValueOverflow\n}\n\n", typ, g.proto)
+		g.printf("%s = %s(m)\n}\n", expr, typ)
+		return nil
+
+	case opts.Varint && basic.kind == "uint":
+		g.printf("{\nm, err := r.ReadUvarint()\nif err != nil {\nreturn err\n}\n\n")
+		g.printf("if uint64(%s(m)) != m {\nreturn %sErrValueOverflow\n}\n\n", typ, g.proto)
+		g.printf("%s = %s(m)\n}\n", expr, typ)
+		return nil
+
+	case basic.method == "":
+		return fmt.Errorf("type %s requires bin:\"varint\"", basic.native)
+	}
+
+	g.printf("{\nm, err := r.Read%s()\nif err != nil {\nreturn err\n}\n\n", basic.method)
+	g.printf("%s = %s(m)\n}\n", expr, typ)
+	return nil
+}
+
+// zero 返回optional字段的零值表达式, 与reflect.Value.IsZero一致
+func (g *generator) zero(t ast.Expr, isStruct bool) (string, error) {
+	if isStruct {
+		return "", fmt.Errorf("optional struct is not supported, use a pointer")
+	}
+
+	switch m := t.(type) {
+	case *ast.Ident:
+		if basic, ok := basicTypes[m.Name]; ok {
+			switch basic.kind {
+			case "bool":
+				return "false", nil
+
+			case "string":
+				return `""`, nil
+			}
+
+			return "0", nil
+		}
+
+	case *ast.StarExpr:
+		return "nil", nil
+
+	case *ast.ArrayType:
+		if m.Len == nil {
+			return "nil", nil
+		}
+	}
+
+	return "", fmt.Errorf("optional %s is not supported", typeString(t))
+}
+
+// isByte 是否为byte类型
+func (g *generator) isByte(t ast.Expr) bool {
+	underlying, _, _ := g.resolve(t)
+	if ident, ok := underlying.(*ast.Ident); ok {
+		return ident.Name == "byte" || ident.Name == "uint8"
+	}
+
+	return false
+}
+
+// embeddedName 嵌入字段的名称
+func embeddedName(t ast.Expr) string {
+	switch m := t.(type) {
+	case *ast.Ident:
+		return m.Name
+
+	case *ast.StarExpr:
+		return embeddedName(m.X)
+
+	case *ast.SelectorExpr:
+		return m.Sel.Name
+	}
+
+	return ""
+}
+
+func typeString(t ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), t)
+	return buf.String()
+}
+
+func newGenerator(pkg string, types map[string]ast.Expr) *generator {
+	g := &generator{
+		pkg:       pkg,
+		proto:     "proto.",
+		types:     types,
+		generated: make(map[string]bool),
+	}
+
+	if pkg == "proto" {
+		g.proto = ""
+	}
+
+	return g
+}
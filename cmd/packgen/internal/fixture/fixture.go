@@ -0,0 +1,52 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package fixture
+
+//go:generate go run github.com/doublemo/balala/cmd/packgen -type=Message
+
+// Raw 二进制
+type Raw []byte
+
+// Level 等级
+type Level uint16
+
+// Items 列表
+type Items []*Item
+
+// Item 物品
+type Item struct {
+	ID    uint32
+	Name  string
+	Level Level
+}
+
+// Message 测试消息
+type Message struct {
+	Skip    string `bin:"-"`
+	Last    uint8  `bin:"order=2"`
+	First   uint8  `bin:"order=1"`
+	B       bool
+	I8      int8
+	I16     int16
+	I32     int32
+	I64     int64
+	U64     uint64
+	F32     float32
+	F64     float64
+	N       int     `bin:"varint"`
+	U       uint32  `bin:"zigzag"`
+	Ids     []int64 `bin:"varint,len32"`
+	Code    [4]byte
+	Name    string `bin:"fixed=3"`
+	Data    []byte
+	Raw     Raw `bin:"len32"`
+	Item    Item
+	Ptr     *Item `bin:"optional"`
+	Items   Items
+	Opt     []uint16 `bin:"optional"`
+	OptName string   `bin:"optional"`
+	Matrix  [][]int16
+	Inline  struct {
+		X, Y int32
+	}
+}
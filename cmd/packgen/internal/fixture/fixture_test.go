@@ -0,0 +1,69 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package fixture
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/doublemo/balala/cores/proto"
+)
+
+// TestMessage 生成的代码与反射方式结果一致
+func TestMessage(t *testing.T) {
+	v := &Message{
+		Last:    2,
+		First:   1,
+		B:       true,
+		I8:      -1,
+		I16:     -2,
+		I32:     -3,
+		I64:     -4,
+		U64:     5,
+		F32:     1.5,
+		F64:     2.5,
+		N:       -300,
+		U:       300,
+		Ids:     []int64{-1, 1 << 40},
+		Code:    [4]byte{1, 2, 3, 4},
+		Name:    "abc",
+		Data:    []byte{1},
+		Raw:     Raw{2, 3},
+		Item:    Item{ID: 1, Name: "x", Level: 3},
+		Ptr:     &Item{ID: 2},
+		Items:   Items{{ID: 3}, {ID: 4, Name: "y"}},
+		OptName: "o",
+		Matrix:  [][]int16{{1, 2}, {}, {3}},
+	}
+
+	v.Inline.X, v.Inline.Y = 7, -8
+
+	var w1, w2 proto.BytesBuffer
+	data, err := proto.Pack(&w1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proto.Pack(&w2, (*packgenReflectMessage)(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out := &Message{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	out2 := &packgenReflectMessage{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, v) || !reflect.DeepEqual((*Message)(out2), v) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, v)
+	}
+}
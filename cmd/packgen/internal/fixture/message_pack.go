@@ -0,0 +1,626 @@
+// Code generated by packgen. DO NOT EDIT.
+
+package fixture
+
+import "github.com/doublemo/balala/cores/proto"
+
+// Pack 封包, 实现proto.FastPack
+func (v *Message) Pack(w *proto.BytesBuffer) error {
+	if err := w.WriteUint8(uint8(v.First)); err != nil {
+		return err
+	}
+
+	if err := w.WriteUint8(uint8(v.Last)); err != nil {
+		return err
+	}
+
+	if err := w.WriteBool(bool(v.B)); err != nil {
+		return err
+	}
+
+	if err := w.WriteInt8(int8(v.I8)); err != nil {
+		return err
+	}
+
+	if err := w.WriteInt16(int16(v.I16)); err != nil {
+		return err
+	}
+
+	if err := w.WriteInt32(int32(v.I32)); err != nil {
+		return err
+	}
+
+	if err := w.WriteInt64(int64(v.I64)); err != nil {
+		return err
+	}
+
+	if err := w.WriteUint64(uint64(v.U64)); err != nil {
+		return err
+	}
+
+	if err := w.WriteFloat32(float32(v.F32)); err != nil {
+		return err
+	}
+
+	if err := w.WriteFloat64(float64(v.F64)); err != nil {
+		return err
+	}
+
+	if err := w.WriteVarint(int64(v.N)); err != nil {
+		return err
+	}
+
+	if err := w.WriteUvarint(uint64(v.U)); err != nil {
+		return err
+	}
+
+	if uint64(len(v.Ids)) > 0xffffffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint32(uint32(len(v.Ids))); err != nil {
+		return err
+	}
+	for i1 := range v.Ids {
+		if err := w.WriteVarint(int64(v.Ids[i1])); err != nil {
+			return err
+		}
+	}
+
+	for i2 := range v.Code {
+		if err := w.WriteUint8(uint8(v.Code[i2])); err != nil {
+			return err
+		}
+	}
+
+	if len(v.Name) != 3 {
+		return proto.ErrFixedLength
+	}
+	if err := w.WriteBytes([]byte(v.Name)...); err != nil {
+		return err
+	}
+
+	if len(v.Data) > 0xffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint16(uint16(len(v.Data))); err != nil {
+		return err
+	}
+	if err := w.WriteBytes(v.Data...); err != nil {
+		return err
+	}
+
+	if uint64(len(v.Raw)) > 0xffffffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint32(uint32(len(v.Raw))); err != nil {
+		return err
+	}
+	if err := w.WriteBytes(v.Raw...); err != nil {
+		return err
+	}
+
+	if err := v.Item.Pack(w); err != nil {
+		return err
+	}
+
+	if v.Ptr == nil {
+		if err := w.WriteBool(false); err != nil {
+			return err
+		}
+	} else {
+		if err := w.WriteBool(true); err != nil {
+			return err
+		}
+		if v.Ptr != nil {
+			if err := (*v.Ptr).Pack(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(v.Items) > 0xffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint16(uint16(len(v.Items))); err != nil {
+		return err
+	}
+	for i3 := range v.Items {
+		if v.Items[i3] != nil {
+			if err := (*v.Items[i3]).Pack(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	if v.Opt == nil {
+		if err := w.WriteBool(false); err != nil {
+			return err
+		}
+	} else {
+		if err := w.WriteBool(true); err != nil {
+			return err
+		}
+		if len(v.Opt) > 0xffff {
+			return proto.ErrLengthOverflow
+		}
+		if err := w.WriteUint16(uint16(len(v.Opt))); err != nil {
+			return err
+		}
+		for i4 := range v.Opt {
+			if err := w.WriteUint16(uint16(v.Opt[i4])); err != nil {
+				return err
+			}
+		}
+	}
+
+	if v.OptName == "" {
+		if err := w.WriteBool(false); err != nil {
+			return err
+		}
+	} else {
+		if err := w.WriteBool(true); err != nil {
+			return err
+		}
+		if len(v.OptName) > 0xffff {
+			return proto.ErrLengthOverflow
+		}
+		if err := w.WriteUint16(uint16(len(v.OptName))); err != nil {
+			return err
+		}
+		if err := w.WriteBytes([]byte(v.OptName)...); err != nil {
+			return err
+		}
+	}
+
+	if len(v.Matrix) > 0xffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint16(uint16(len(v.Matrix))); err != nil {
+		return err
+	}
+	for i5 := range v.Matrix {
+		if len(v.Matrix[i5]) > 0xffff {
+			return proto.ErrLengthOverflow
+		}
+		if err := w.WriteUint16(uint16(len(v.Matrix[i5]))); err != nil {
+			return err
+		}
+		for i6 := range v.Matrix[i5] {
+			if err := w.WriteInt16(int16(v.Matrix[i5][i6])); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.WriteInt32(int32(v.Inline.X)); err != nil {
+		return err
+	}
+	if err := w.WriteInt32(int32(v.Inline.Y)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unpack 解包, 实现proto.FastUnpack
+func (v *Message) Unpack(r *proto.BytesBuffer) error {
+	{
+		m, err := r.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		v.First = uint8(m)
+	}
+
+	{
+		m, err := r.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		v.Last = uint8(m)
+	}
+
+	{
+		m, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		v.B = bool(m)
+	}
+
+	{
+		m, err := r.ReadInt8()
+		if err != nil {
+			return err
+		}
+
+		v.I8 = int8(m)
+	}
+
+	{
+		m, err := r.ReadInt16()
+		if err != nil {
+			return err
+		}
+
+		v.I16 = int16(m)
+	}
+
+	{
+		m, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+
+		v.I32 = int32(m)
+	}
+
+	{
+		m, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+
+		v.I64 = int64(m)
+	}
+
+	{
+		m, err := r.ReadUint64()
+		if err != nil {
+			return err
+		}
+
+		v.U64 = uint64(m)
+	}
+
+	{
+		m, err := r.ReadFloat32()
+		if err != nil {
+			return err
+		}
+
+		v.F32 = float32(m)
+	}
+
+	{
+		m, err := r.ReadFloat64()
+		if err != nil {
+			return err
+		}
+
+		v.F64 = float64(m)
+	}
+
+	{
+		m, err := r.ReadVarint()
+		if err != nil {
+			return err
+		}
+
+		if int64(int(m)) != m {
+			return proto.ErrValueOverflow
+		}
+
+		v.N = int(m)
+	}
+
+	{
+		m, err := r.ReadUvarint()
+		if err != nil {
+			return err
+		}
+
+		if uint64(uint32(m)) != m {
+			return proto.ErrValueOverflow
+		}
+
+		v.U = uint32(m)
+	}
+
+	{
+		n, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		if n > 0x7fffffff {
+			return proto.ErrLengthOverflow
+		}
+
+		if int(n) > len(r.Bytes()) {
+			return proto.ErrOutOfRange
+		}
+
+		v.Ids = make([]int64, n)
+		for i7 := range v.Ids {
+			{
+				m, err := r.ReadVarint()
+				if err != nil {
+					return err
+				}
+
+				if int64(int64(m)) != m {
+					return proto.ErrValueOverflow
+				}
+
+				v.Ids[i7] = int64(m)
+			}
+		}
+	}
+
+	for i8 := range v.Code {
+		{
+			m, err := r.ReadUint8()
+			if err != nil {
+				return err
+			}
+
+			v.Code[i8] = byte(m)
+		}
+	}
+
+	{
+		n := 3
+		m, err := r.ReadBytes(int(n))
+		if err != nil {
+			return err
+		}
+
+		v.Name = string(m)
+	}
+
+	{
+		n, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		m, err := r.ReadBytes(int(n))
+		if err != nil {
+			return err
+		}
+
+		v.Data = make([]byte, len(m))
+		copy(v.Data, m)
+	}
+
+	{
+		n, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		if n > 0x7fffffff {
+			return proto.ErrLengthOverflow
+		}
+
+		m, err := r.ReadBytes(int(n))
+		if err != nil {
+			return err
+		}
+
+		v.Raw = make(Raw, len(m))
+		copy(v.Raw, m)
+	}
+
+	if err := v.Item.Unpack(r); err != nil {
+		return err
+	}
+
+	{
+		ok, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			v.Ptr = nil
+		} else {
+			if v.Ptr == nil {
+				v.Ptr = new(Item)
+			}
+			if err := (*v.Ptr).Unpack(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	{
+		n, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		if int(n) > len(r.Bytes()) {
+			return proto.ErrOutOfRange
+		}
+
+		v.Items = make(Items, n)
+		for i9 := range v.Items {
+			if v.Items[i9] == nil {
+				v.Items[i9] = new(Item)
+			}
+			if err := (*v.Items[i9]).Unpack(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	{
+		ok, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			v.Opt = nil
+		} else {
+			{
+				n, err := r.ReadUint16()
+				if err != nil {
+					return err
+				}
+
+				if int(n) > len(r.Bytes()) {
+					return proto.ErrOutOfRange
+				}
+
+				v.Opt = make([]uint16, n)
+				for i10 := range v.Opt {
+					{
+						m, err := r.ReadUint16()
+						if err != nil {
+							return err
+						}
+
+						v.Opt[i10] = uint16(m)
+					}
+				}
+			}
+		}
+	}
+
+	{
+		ok, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			v.OptName = ""
+		} else {
+			{
+				n, err := r.ReadUint16()
+				if err != nil {
+					return err
+				}
+
+				m, err := r.ReadBytes(int(n))
+				if err != nil {
+					return err
+				}
+
+				v.OptName = string(m)
+			}
+		}
+	}
+
+	{
+		n, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		if int(n) > len(r.Bytes()) {
+			return proto.ErrOutOfRange
+		}
+
+		v.Matrix = make([][]int16, n)
+		for i11 := range v.Matrix {
+			{
+				n, err := r.ReadUint16()
+				if err != nil {
+					return err
+				}
+
+				if int(n) > len(r.Bytes()) {
+					return proto.ErrOutOfRange
+				}
+
+				v.Matrix[i11] = make([]int16, n)
+				for i12 := range v.Matrix[i11] {
+					{
+						m, err := r.ReadInt16()
+						if err != nil {
+							return err
+						}
+
+						v.Matrix[i11][i12] = int16(m)
+					}
+				}
+			}
+		}
+	}
+
+	{
+		m, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+
+		v.Inline.X = int32(m)
+	}
+	{
+		m, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+
+		v.Inline.Y = int32(m)
+	}
+
+	return nil
+}
+
+// Pack 封包, 实现proto.FastPack
+func (v *Item) Pack(w *proto.BytesBuffer) error {
+	if err := w.WriteUint32(uint32(v.ID)); err != nil {
+		return err
+	}
+
+	if len(v.Name) > 0xffff {
+		return proto.ErrLengthOverflow
+	}
+	if err := w.WriteUint16(uint16(len(v.Name))); err != nil {
+		return err
+	}
+	if err := w.WriteBytes([]byte(v.Name)...); err != nil {
+		return err
+	}
+
+	if err := w.WriteUint16(uint16(v.Level)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unpack 解包, 实现proto.FastUnpack
+func (v *Item) Unpack(r *proto.BytesBuffer) error {
+	{
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.ID = uint32(m)
+	}
+
+	{
+		n, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		m, err := r.ReadBytes(int(n))
+		if err != nil {
+			return err
+		}
+
+		v.Name = string(m)
+	}
+
+	{
+		m, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		v.Level = Level(m)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+// Code generated by packgen. DO NOT EDIT.
+
+package fixture
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/doublemo/balala/cores/proto"
+)
+
+type packgenReflectItem Item
+
+func packgenSampleItem() *Item {
+	v := &Item{}
+	v.ID = 2
+	v.Name = "ccc"
+	v.Level = 4
+	return v
+}
+
+func TestPackgenItem(t *testing.T) {
+	v := packgenSampleItem()
+	var w1, w2 proto.BytesBuffer
+	data, err := proto.Pack(&w1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proto.Pack(&w2, (*packgenReflectItem)(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out, out2 := &Item{}, &packgenReflectItem{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, (*Item)(out2)) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, out2)
+	}
+}
+
+func BenchmarkPackgenItemPack(b *testing.B) {
+	v := packgenSampleItem()
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Pack(proto.NewBytesBuffer(buf[:0])); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenItemPackReflect(b *testing.B) {
+	v := (*packgenReflectItem)(packgenSampleItem())
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Pack(proto.NewBytesBuffer(buf[:0]), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenItemUnpack(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleItem())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &Item{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Unpack(proto.NewBytesBuffer(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenItemUnpackReflect(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleItem())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &packgenReflectItem{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := proto.Unpack(proto.NewBytesBuffer(data), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type packgenReflectMessage Message
+
+func packgenSampleMessage() *Message {
+	v := &Message{}
+	v.First = 5
+	v.Last = 6
+	v.B = true
+	v.I8 = 8
+	v.I16 = 9
+	v.I32 = 10
+	v.I64 = 11
+	v.U64 = 12
+	v.F32 = 12.5
+	v.F64 = 13.5
+	v.N = 15
+	v.U = 16
+	v.Ids = make([]int64, 2)
+	for i13 := range v.Ids {
+		v.Ids[i13] = 17
+	}
+	for i14 := range v.Code {
+		v.Code[i14] = 18
+	}
+	v.Name = "sss"
+	v.Data = make([]byte, 2)
+	for i15 := range v.Data {
+		v.Data[i15] = 20
+	}
+	v.Raw = make(Raw, 2)
+	for i16 := range v.Raw {
+		v.Raw[i16] = 21
+	}
+	v.Item.ID = 22
+	v.Item.Name = "www"
+	v.Item.Level = 24
+	v.Ptr = new(Item)
+	(*v.Ptr).ID = 25
+	(*v.Ptr).Name = "zzz"
+	(*v.Ptr).Level = 27
+	v.Items = make(Items, 2)
+	for i17 := range v.Items {
+		v.Items[i17] = new(Item)
+		(*v.Items[i17]).ID = 28
+		(*v.Items[i17]).Name = "ccc"
+		(*v.Items[i17]).Level = 30
+	}
+	v.Opt = make([]uint16, 2)
+	for i18 := range v.Opt {
+		v.Opt[i18] = 31
+	}
+	v.OptName = "fff"
+	v.Matrix = make([][]int16, 2)
+	for i19 := range v.Matrix {
+		v.Matrix[i19] = make([]int16, 2)
+		for i20 := range v.Matrix[i19] {
+			v.Matrix[i19][i20] = 33
+		}
+	}
+	v.Inline.X = 34
+	v.Inline.Y = 35
+	return v
+}
+
+func TestPackgenMessage(t *testing.T) {
+	v := packgenSampleMessage()
+	var w1, w2 proto.BytesBuffer
+	data, err := proto.Pack(&w1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proto.Pack(&w2, (*packgenReflectMessage)(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out, out2 := &Message{}, &packgenReflectMessage{}
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proto.Unpack(proto.NewBytesBuffer(data), out2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, (*Message)(out2)) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, out2)
+	}
+}
+
+func BenchmarkPackgenMessagePack(b *testing.B) {
+	v := packgenSampleMessage()
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Pack(proto.NewBytesBuffer(buf[:0])); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenMessagePackReflect(b *testing.B) {
+	v := (*packgenReflectMessage)(packgenSampleMessage())
+	buf := make([]byte, 0, proto.PacketMaxLimit)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Pack(proto.NewBytesBuffer(buf[:0]), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenMessageUnpack(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleMessage())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &Message{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Unpack(proto.NewBytesBuffer(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackgenMessageUnpackReflect(b *testing.B) {
+	var w proto.BytesBuffer
+	data, err := proto.Pack(&w, packgenSampleMessage())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := &packgenReflectMessage{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := proto.Unpack(proto.NewBytesBuffer(data), v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+// packgen 为结构体生成proto.FastPack与proto.FastUnpack实现
+// 生成的代码与proto.Pack/proto.Unpack的编码方式一致, 支持bin标签,
+// 同时生成以非零值与反射方式对比编码与解码结果的测试, 以及两种方式的基准测试.
+//
+// 用法:
+//	//go:generate go run github.com/doublemo/balala/cmd/packgen -type=Foo,Bar
+//
+// 生成 <文件名>_pack.go 与 <文件名>_pack_test.go.
+// 结构体引用的同一包内的结构体会一并生成
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var usageStr = `
+Usage: packgen [options] [directory]
+Options:
+	-type <names>                    Comma-separated list of struct type names; must be set
+	-output <file>                   Output file name; default <srcfile>_pack.go
+	-notest                          Do not generate tests and benchmarks
+
+Common Options:
+    -h, --help                       Show this message
+`
+
+func usage() {
+	fmt.Printf("%s\n", usageStr)
+	os.Exit(0)
+}
+
+func main() {
+	var (
+		typeNames string
+		output    string
+		noTest    bool
+		showHelp  bool
+	)
+
+	fs := flag.NewFlagSet("packgen", flag.ExitOnError)
+	fs.Usage = usage
+	fs.StringVar(&typeNames, "type", "", "comma-separated list of type names")
+	fs.StringVar(&output, "output", "", "output file name")
+	fs.BoolVar(&noTest, "notest", false, "do not generate tests")
+	fs.BoolVar(&showHelp, "h", false, "Show this message.")
+	fs.BoolVar(&showHelp, "help", false, "Show this message.")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		fatalf("%v", err)
+	}
+
+	if showHelp || typeNames == "" {
+		usage()
+	}
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	pkg, err := parsePackage(dir)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	g := newGenerator(pkg.name, pkg.types)
+	src, err := g.generate(strings.Split(typeNames, ","))
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	if output == "" {
+		base := strings.ToLower(strings.Split(typeNames, ",")[0])
+		if f := os.Getenv("GOFILE"); f != "" {
+			base = strings.TrimSuffix(f, ".go")
+		}
+
+		output = base + "_pack.go"
+	}
+
+	output = filepath.Join(dir, output)
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		fatalf("%v", err)
+	}
+
+	if noTest {
+		return
+	}
+
+	src, err = g.generateTest()
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	if err := ioutil.WriteFile(strings.TrimSuffix(output, ".go")+"_test.go", src, 0644); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// goPackage 解析后的包
+type goPackage struct {
+	// name 包名
+	name string
+
+	// types 包内定义的类型
+	types map[string]ast.Expr
+}
+
+// parsePackage 解析目录内的go文件, 不包括测试文件与生成的文件
+func parsePackage(dir string) (*goPackage, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(name, "_pack.go")
+	}, parser.ParseComments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("packgen: expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	pkg := &goPackage{types: make(map[string]ast.Expr)}
+	for name, m := range pkgs {
+		pkg.name = name
+		for _, file := range m.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					pkg.types[ts.Name.Name] = ts.Type
+				}
+			}
+		}
+	}
+
+	return pkg, nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "packgen: "+format+"\n", args...)
+	os.Exit(1)
+}
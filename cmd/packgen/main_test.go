@@ -0,0 +1,53 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// TestGenerate 生成的代码与internal/fixture中的一致
+// 修改生成规则后在internal/fixture执行go generate更新, fixture中的测试随go test ./...一起执行
+func TestGenerate(t *testing.T) {
+	pkg, err := parsePackage("internal/fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator(pkg.name, pkg.types)
+	src, err := g.generate([]string{"Message"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSrc, err := g.generateTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, data := range map[string][]byte{"message_pack.go": src, "message_pack_test.go": testSrc} {
+		expected, err := ioutil.ReadFile("internal/fixture/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("%s is out of date", file)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	pkg, err := parsePackage("internal/fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Unknown", "Level"} {
+		if _, err := newGenerator(pkg.name, pkg.types).generate([]string{name}); err == nil {
+			t.Fatalf("%s: Unexpected nil error", name)
+		}
+	}
+}
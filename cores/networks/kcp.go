@@ -61,7 +61,9 @@ func (s *KCP) Serve(c *KCPConfig) error {
 	go s.serve()
 	err := <-s.done
 	close(s.exit)
-	s.listen.Close()
+	if s.listen != nil {
+		s.listen.Close()
+	}
 
 	// waiting ...
 	s.wg.Wait()
@@ -125,6 +127,10 @@ func (s *KCP) accept(connChan chan *kcp.UDPSession) error {
 }
 
 func (s *KCP) listenTo() (err error) {
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
 	block, err := s.config.BlockCrypt()
 	if err != nil {
 		return err
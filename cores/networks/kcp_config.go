@@ -15,6 +15,42 @@ import (
 var (
 	// ErrInvalidCrypt 错误的加密信息
 	ErrInvalidCrypt = errors.New("ErrInvalidCrypt")
+
+	// ErrInvalidMode 错误的延迟模式或者manual模式参数
+	ErrInvalidMode = errors.New("ErrInvalidMode")
+
+	// ErrInvalidMTU MTU超出范围
+	ErrInvalidMTU = errors.New("ErrInvalidMTU")
+
+	// ErrInvalidWindow 窗口大小超出范围
+	ErrInvalidWindow = errors.New("ErrInvalidWindow")
+
+	// ErrInvalidShard 纠错分片数量错误
+	ErrInvalidShard = errors.New("ErrInvalidShard")
+
+	// ErrInvalidDSCP DSCP超出范围
+	ErrInvalidDSCP = errors.New("ErrInvalidDSCP")
+)
+
+// KCP 参数范围
+const (
+	// KCPMinMTU 最小MTU
+	KCPMinMTU = 50
+
+	// KCPMaxMTU 最大MTU, 与kcp-go的接收缓存一致
+	KCPMaxMTU = 1500
+
+	// KCPMaxWindow 最大窗口, kcp包头中窗口为16位
+	KCPMaxWindow = 65535
+
+	// KCPMaxShards 数据分片与校验分片的总数上限
+	KCPMaxShards = 256
+
+	// KCPMinInterval manual模式最小刷新间隔(毫秒)
+	KCPMinInterval = 10
+
+	// KCPMaxInterval manual模式最大刷新间隔(毫秒)
+	KCPMaxInterval = 5000
 )
 
 // KCPConfig 配置文件
@@ -30,6 +66,18 @@ type KCPConfig struct {
 	// Mode fast3, fast2, fast, normal, manual
 	Mode string
 
+	// NoDelay manual模式 是否启用nodelay 0:关闭 1:启用
+	NoDelay int
+
+	// Interval manual模式 内部刷新间隔(毫秒) 10-5000
+	Interval int
+
+	// Resend manual模式 快速重传的跨越次数, 0关闭快速重传
+	Resend int
+
+	// NoCongestion manual模式 是否关闭拥塞控制 0:启用 1:关闭
+	NoCongestion int
+
 	// MTU set maximum transmission unit for UDP packets
 	MTU int
 
@@ -64,16 +112,20 @@ type KCPConfig struct {
 	ReadDeadline time.Duration
 }
 
+// Delay 根据Mode返回kcp的nodelay参数
+// 未知模式按normal处理
 func (c *KCPConfig) Delay() (noDelay int, interval int, resend int, noCongestion int) {
 	switch c.Mode {
 	case "normal":
 		noDelay, interval, resend, noCongestion = 0, 40, 2, 1
 	case "fast":
-		noDelay, interval, resend, noCongestion = 0, 40, 2, 1
+		noDelay, interval, resend, noCongestion = 0, 30, 2, 1
 	case "fast2":
-		noDelay, interval, resend, noCongestion = 0, 40, 2, 1
+		noDelay, interval, resend, noCongestion = 1, 20, 2, 1
 	case "fast3":
-		noDelay, interval, resend, noCongestion = 0, 40, 2, 1
+		noDelay, interval, resend, noCongestion = 1, 10, 2, 1
+	case "manual":
+		noDelay, interval, resend, noCongestion = c.NoDelay, c.Interval, c.Resend, c.NoCongestion
 	default:
 		noDelay, interval, resend, noCongestion = 0, 40, 2, 1
 	}
@@ -81,6 +133,55 @@ func (c *KCPConfig) Delay() (noDelay int, interval int, resend int, noCongestion
 	return
 }
 
+// Validate 检查配置是否有效
+func (c *KCPConfig) Validate() error {
+	switch c.Mode {
+	case "", "normal", "fast", "fast2", "fast3":
+	case "manual":
+		if c.NoDelay != 0 && c.NoDelay != 1 {
+			return ErrInvalidMode
+		}
+
+		if c.Interval < KCPMinInterval || c.Interval > KCPMaxInterval {
+			return ErrInvalidMode
+		}
+
+		if c.Resend < 0 {
+			return ErrInvalidMode
+		}
+
+		if c.NoCongestion != 0 && c.NoCongestion != 1 {
+			return ErrInvalidMode
+		}
+
+	default:
+		return ErrInvalidMode
+	}
+
+	if c.MTU < KCPMinMTU || c.MTU > KCPMaxMTU {
+		return ErrInvalidMTU
+	}
+
+	if c.SndWnd < 1 || c.SndWnd > KCPMaxWindow || c.RcvWnd < 1 || c.RcvWnd > KCPMaxWindow {
+		return ErrInvalidWindow
+	}
+
+	// 分片数同时为0时关闭纠错, 否则都必须大于0
+	if c.DataShard < 0 || c.ParityShard < 0 || c.DataShard+c.ParityShard > KCPMaxShards {
+		return ErrInvalidShard
+	}
+
+	if (c.DataShard == 0) != (c.ParityShard == 0) {
+		return ErrInvalidShard
+	}
+
+	if c.DSCP < 0 || c.DSCP > 63 {
+		return ErrInvalidDSCP
+	}
+
+	return nil
+}
+
 func (c *KCPConfig) BlockCrypt() (block kcp.BlockCrypt, err error) {
 	pass := pbkdf2.Key([]byte(c.Key), []byte("foxchat-kcp-go"), 4096, 32, sha1.New)
 	crypt := c.Crypt
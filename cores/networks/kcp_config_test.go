@@ -0,0 +1,58 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package networks
+
+import "testing"
+
+func TestKCPConfigDelay(t *testing.T) {
+	cases := []struct {
+		mode                                  string
+		noDelay, interval, resend, congestion int
+	}{
+		{"normal", 0, 40, 2, 1},
+		{"fast", 0, 30, 2, 1},
+		{"fast2", 1, 20, 2, 1},
+		{"fast3", 1, 10, 2, 1},
+		{"manual", 1, 15, 3, 0},
+		{"", 0, 40, 2, 1},
+	}
+
+	for _, c := range cases {
+		config := NewKCPDefaultConfig()
+		config.Mode = c.mode
+		config.NoDelay, config.Interval, config.Resend, config.NoCongestion = 1, 15, 3, 0
+		noDelay, interval, resend, congestion := config.Delay()
+		if noDelay != c.noDelay || interval != c.interval || resend != c.resend || congestion != c.congestion {
+			t.Fatalf("mode %q: unexpected delay %d, %d, %d, %d", c.mode, noDelay, interval, resend, congestion)
+		}
+	}
+}
+
+func TestKCPConfigValidate(t *testing.T) {
+	cases := []struct {
+		fn  func(*KCPConfig)
+		err error
+	}{
+		{func(c *KCPConfig) {}, nil},
+		{func(c *KCPConfig) { c.Mode = "turbo" }, ErrInvalidMode},
+		{func(c *KCPConfig) { c.Mode, c.Interval = "manual", 5 }, ErrInvalidMode},
+		{func(c *KCPConfig) { c.Mode, c.Interval, c.NoDelay = "manual", 20, 2 }, ErrInvalidMode},
+		{func(c *KCPConfig) { c.Mode, c.Interval, c.NoDelay = "manual", 20, 1 }, nil},
+		{func(c *KCPConfig) { c.MTU = 1501 }, ErrInvalidMTU},
+		{func(c *KCPConfig) { c.MTU = 20 }, ErrInvalidMTU},
+		{func(c *KCPConfig) { c.SndWnd = 0 }, ErrInvalidWindow},
+		{func(c *KCPConfig) { c.RcvWnd = 65536 }, ErrInvalidWindow},
+		{func(c *KCPConfig) { c.DataShard, c.ParityShard = 0, 0 }, nil},
+		{func(c *KCPConfig) { c.DataShard, c.ParityShard = 10, 0 }, ErrInvalidShard},
+		{func(c *KCPConfig) { c.DataShard, c.ParityShard = 200, 100 }, ErrInvalidShard},
+		{func(c *KCPConfig) { c.DSCP = 64 }, ErrInvalidDSCP},
+	}
+
+	for i, c := range cases {
+		config := NewKCPDefaultConfig()
+		c.fn(config)
+		if err := config.Validate(); err != c.err {
+			t.Fatalf("case %d: expected %v, got %v", i, c.err, err)
+		}
+	}
+}
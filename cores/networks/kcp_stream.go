@@ -9,10 +9,12 @@ import (
 	"github.com/golang/snappy"
 )
 
+// KCPStream snappy 压缩的kcp连接
+// 除读写外的方法由内部连接提供, 可以作为net.Conn使用
 type KCPStream struct {
-	conn net.Conn
-	w    *snappy.Writer
-	r    *snappy.Reader
+	net.Conn
+	w *snappy.Writer
+	r *snappy.Reader
 }
 
 func (c *KCPStream) Read(p []byte) (n int, err error) {
@@ -26,12 +28,12 @@ func (c *KCPStream) Write(p []byte) (n int, err error) {
 }
 
 func (c *KCPStream) Close() error {
-	return c.conn.Close()
+	return c.Conn.Close()
 }
 
 func NewKCPStream(conn net.Conn) *KCPStream {
 	c := new(KCPStream)
-	c.conn = conn
+	c.Conn = conn
 	c.w = snappy.NewBufferedWriter(conn)
 	c.r = snappy.NewReader(conn)
 	return c
@@ -3,32 +3,302 @@
 package process
 
 import (
+	"errors"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+const (
+	// DefaultBackoff 默认第一次重启前的等待时间
+	DefaultBackoff = time.Second
+
+	// DefaultMaxBackoff 默认最大等待时间
+	DefaultMaxBackoff = time.Minute
+)
+
+var (
+	// ErrNotRunning 盒子没有运行
+	ErrNotRunning = errors.New("ErrNotRunning")
+
+	// ErrRuntimeActorNotFound 协程不存在
+	ErrRuntimeActorNotFound = errors.New("ErrRuntimeActorNotFound")
+
+	// ErrNotRestartable 协程已经关闭并且没有创建函数, 不能重新运行
+	ErrNotRestartable = errors.New("ErrNotRestartable")
+
+	// ErrNilRuntimeActor 创建函数没有返回协程
+	ErrNilRuntimeActor = errors.New("ErrNilRuntimeActor")
+)
+
+// RestartPolicy 协程退出后的重启策略
+type RestartPolicy int
+
+const (
+	// RestartNever 不重启
+	RestartNever RestartPolicy = iota
+
+	// RestartOnFailure Exec返回错误时重启
+	RestartOnFailure
+
+	// RestartAlways Exec返回时总是重启
+	RestartAlways
+)
+
+// RuntimeStatus 协程运行状态
+type RuntimeStatus int32
+
+const (
+	// StatusStopped 已停止或者等待运行
+	StatusStopped RuntimeStatus = iota
+
+	// StatusRunning 正在运行
+	StatusRunning
+
+	// StatusRestarting 等待重启
+	StatusRestarting
+)
+
+func (s RuntimeStatus) String() string {
+	switch s {
+	case StatusRunning:
+		return "running"
+
+	case StatusRestarting:
+		return "restarting"
+	}
+
+	return "stopped"
+}
+
+// RuntimeActorStatus 协程状态快照
+type RuntimeActorStatus struct {
+	// ID 协程ID
+	ID int32
+
+	// Status 运行状态
+	Status RuntimeStatus
+
+	// Restarts 重启次数
+	Restarts int
+
+	// Err 最后一次返回的错误
+	Err error
+
+	// StartedAt 最后一次运行时间
+	StartedAt time.Time
+}
+
 // RuntimeActor 协程
 type RuntimeActor struct {
 	id int32
 
 	stoped int32
 
+	// done Exec返回并且Interrupt处理完成后关闭
+	done chan struct{}
+
+	// factory 创建函数, 重启时用于重新创建协程
+	factory func() (*RuntimeActor, error)
+
+	// 以下字段由mutex保护
+	status    RuntimeStatus
+	closed    bool
+	stopChan  chan struct{}
+	err       error
+	restarts  int
+	startedAt time.Time
+	mutex     sync.Mutex
+
 	Exec func() error
 
 	Interrupt func(error)
 
 	Close func()
+
+	// Restart 重启策略, 默认不重启
+	Restart RestartPolicy
+
+	// Backoff 第一次重启前的等待时间, 之后每次加倍
+	Backoff time.Duration
+
+	// MaxBackoff 最大等待时间
+	MaxBackoff time.Duration
 }
 
 // Clone 复制协程
 func (ra *RuntimeActor) Clone() *RuntimeActor {
 	return &RuntimeActor{
-		id:        ra.id,
-		stoped:    ra.stoped,
-		Exec:      ra.Exec,
-		Interrupt: ra.Interrupt,
-		Close:     ra.Close,
+		id:         ra.id,
+		stoped:     ra.stoped,
+		factory:    ra.factory,
+		Exec:       ra.Exec,
+		Interrupt:  ra.Interrupt,
+		Close:      ra.Close,
+		Restart:    ra.Restart,
+		Backoff:    ra.Backoff,
+		MaxBackoff: ra.MaxBackoff,
+	}
+}
+
+// backoff 返回第一次与最大等待时间
+func (ra *RuntimeActor) backoff() (time.Duration, time.Duration) {
+	min, max := ra.Backoff, ra.MaxBackoff
+	if min <= 0 {
+		min = DefaultBackoff
+	}
+
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	if max < min {
+		max = min
+	}
+
+	return min, max
+}
+
+// shouldRestart 根据策略判断Exec返回后是否需要重启
+func (ra *RuntimeActor) shouldRestart(err error) bool {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	if ra.closed {
+		return false
+	}
+
+	switch ra.Restart {
+	case RestartAlways:
+	case RestartOnFailure:
+		if err == nil {
+			return false
+		}
+
+	default:
+		return false
+	}
+
+	ra.status = StatusRestarting
+	ra.restarts++
+	return true
+}
+
+// close 关闭协程, 只有第一次调用有效
+func (ra *RuntimeActor) close() {
+	ra.mutex.Lock()
+	if ra.closed {
+		ra.mutex.Unlock()
+		return
+	}
+
+	ra.closed = true
+	if ra.stopChan != nil {
+		close(ra.stopChan)
+	}
+
+	fn := ra.Close
+	ra.mutex.Unlock()
+	fn()
+}
+
+func (ra *RuntimeActor) isClosed() bool {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+	return ra.closed
+}
+
+// wait 等待重启, 协程被关闭时返回false
+func (ra *RuntimeActor) wait(d time.Duration) bool {
+	ra.mutex.Lock()
+	stopChan, closed := ra.stopChan, ra.closed
+	ra.mutex.Unlock()
+	if closed {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+
+	case <-stopChan:
+		return false
+	}
+}
+
+// renew 使用创建函数重新创建协程
+// 旧协程的资源先通过Close释放, 没有创建函数时沿用原来的Exec
+func (ra *RuntimeActor) renew() error {
+	if ra.factory == nil {
+		return nil
+	}
+
+	ra.mutex.Lock()
+	if ra.closed {
+		ra.mutex.Unlock()
+		return nil
+	}
+
+	fn := ra.Close
+	ra.Close = func() {}
+	ra.mutex.Unlock()
+	fn()
+
+	actor, err := ra.factory()
+	if err != nil {
+		return err
+	}
+
+	if actor == nil {
+		return ErrNilRuntimeActor
+	}
+
+	ra.mutex.Lock()
+	if ra.closed {
+		ra.mutex.Unlock()
+		actor.Close()
+		return nil
+	}
+
+	ra.Exec, ra.Interrupt, ra.Close = actor.Exec, actor.Interrupt, actor.Close
+	ra.mutex.Unlock()
+	return nil
+}
+
+// run 运行一次Exec
+func (ra *RuntimeActor) run() error {
+	ra.mutex.Lock()
+	exec, interrupt := ra.Exec, ra.Interrupt
+	ra.status = StatusRunning
+	ra.startedAt = time.Now()
+	ra.mutex.Unlock()
+
+	err := exec()
+	interrupt(err)
+	if err != nil {
+		ra.mutex.Lock()
+		ra.err = err
+		ra.mutex.Unlock()
+	}
+
+	return err
+}
+
+// snapshot 返回状态快照
+func (ra *RuntimeActor) snapshot() RuntimeActorStatus {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	return RuntimeActorStatus{
+		ID:        ra.id,
+		Status:    ra.status,
+		Restarts:  ra.restarts,
+		Err:       ra.err,
+		StartedAt: ra.startedAt,
 	}
 }
 
@@ -41,6 +311,21 @@ type runtimeError struct {
 type RuntimeContainer struct {
 	actors  sync.Map
 	counter int32
+
+	// running 是否正在运行
+	running bool
+
+	// parked 通过Stop(id)关闭的协程, 保留运行计数直到重新运行或者关闭盒子
+	parked map[int32]bool
+
+	// wg 正在运行的协程
+	wg sync.WaitGroup
+
+	// err 第一个返回的错误
+	err atomic.Value
+
+	// mutex 保护running以及替换过程
+	mutex sync.Mutex
 }
 
 // Add 增加协程服务到盒子
@@ -60,57 +345,254 @@ func (rc *RuntimeContainer) Add(actor *RuntimeActor, status bool) int32 {
 	return actor.id
 }
 
+// AddFunc 使用创建函数增加协程到盒子
+// 协程重启或者关闭后重新运行时都会调用fn重新创建. fn返回nil时不增加, 返回0
+func (rc *RuntimeContainer) AddFunc(fn func() (*RuntimeActor, error)) (int32, error) {
+	actor, err := fn()
+	if err != nil || actor == nil {
+		return 0, err
+	}
+
+	actor.factory = fn
+	return rc.Add(actor, true), nil
+}
+
 // Run 运行盒子内
+// 所有协程退出后返回第一个错误
 func (rc *RuntimeContainer) Run() error {
-	errors := make(chan runtimeError)
-	defer close(errors)
-
+	rc.mutex.Lock()
 	actors := rc.sortRuntimeContainer(1)
-	waitCounter := 0
 	for _, actor := range actors {
-		if atomic.LoadInt32(&actor.stoped) == 0 {
-			continue
+		rc.wg.Add(1)
+		rc.start(actor)
+	}
+
+	rc.running = true
+	rc.mutex.Unlock()
+
+	rc.wg.Wait()
+	rc.mutex.Lock()
+	rc.running = false
+	rc.mutex.Unlock()
+
+	if m, ok := rc.err.Load().(runtimeError); ok {
+		return m.err
+	}
+
+	return nil
+}
+
+// Replace 关闭id对应的协程并用fn创建的协程替换
+// 旧协程的Exec返回后才调用fn, 便于新协程重新监听相同的地址.
+// 新协程沿用原来的id以保持关闭顺序, id为0时分配新的id.
+// fn同时作为新协程的创建函数.
+// 盒子正在运行时立即运行新协程. fn返回nil时只关闭旧的协程, 返回0
+func (rc *RuntimeContainer) Replace(id int32, fn func() (*RuntimeActor, error)) (int32, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	// 先占用计数, 防止旧协程退出时Run提前返回
+	running := rc.running
+	if running && !rc.parked[id] {
+		rc.wg.Add(1)
+	}
+
+	delete(rc.parked, id)
+
+	if m, ok := rc.actors.Load(id); ok && id > 0 {
+		old := m.(*RuntimeActor)
+		if atomic.LoadInt32(&old.stoped) == 0 && old.done != nil {
+			old.close()
+			<-old.done
 		}
 
-		waitCounter++
-		atomic.StoreInt32(&actor.stoped, 0)
-		go func(a *RuntimeActor) {
-			errors <- runtimeError{id: a.id, err: a.Exec()}
-		}(actor)
+		rc.actors.Delete(id)
 	}
 
-	if waitCounter < 1 {
+	actor, err := fn()
+	if err != nil || actor == nil {
+		if running {
+			rc.wg.Done()
+		}
+
+		return 0, err
+	}
+
+	if id > 0 {
+		actor.id = id
+	} else {
+		actor.id = atomic.AddInt32(&rc.counter, 1)
+	}
+
+	actor.factory = fn
+	actor.stoped = 1
+	rc.actors.Store(actor.id, actor)
+	if running {
+		rc.start(actor)
+	}
+
+	return actor.id, nil
+}
+
+// Start 重新运行已经停止的协程
+// 有创建函数的协程先关闭再重新创建, 没有创建函数的协程沿用原来的Exec,
+// 已经被关闭时返回ErrNotRestartable. 正在运行的协程直接返回
+func (rc *RuntimeContainer) Start(id int32) error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if !rc.running {
+		return ErrNotRunning
+	}
+
+	m, ok := rc.actors.Load(id)
+	if !ok {
+		return ErrRuntimeActorNotFound
+	}
+
+	actor := m.(*RuntimeActor)
+	if atomic.LoadInt32(&actor.stoped) == 0 {
 		return nil
 	}
 
-	var (
-		doneCounter int
-		err         error
-	)
+	if actor.done != nil {
+		<-actor.done
+	}
 
-	for e := range errors {
-		doneCounter++
-		if m, ok := rc.actors.Load(e.id); ok {
-			actor := m.(*RuntimeActor)
-			if atomic.LoadInt32(&actor.stoped) == 0 {
-				actor.Interrupt(e.err)
-				atomic.StoreInt32(&actor.stoped, 1)
-			}
+	if actor.factory != nil {
+		actor.close()
+		newActor, err := actor.factory()
+		if err != nil {
+			return err
 		}
 
-		if err == nil && e.err != nil {
-			err = e.err
+		if newActor == nil {
+			return ErrNilRuntimeActor
 		}
 
-		if doneCounter >= waitCounter {
+		newActor.id = id
+		newActor.factory = actor.factory
+		newActor.stoped = 1
+		rc.actors.Store(id, newActor)
+		actor = newActor
+	} else if actor.isClosed() {
+		return ErrNotRestartable
+	}
+
+	rc.wg.Add(1)
+	rc.start(actor)
+	return nil
+}
+
+// start 运行协程, 调用者需要持有mutex并且已经增加wg计数
+func (rc *RuntimeContainer) start(actor *RuntimeActor) {
+	actor.done = make(chan struct{})
+	actor.mutex.Lock()
+	actor.stopChan = make(chan struct{})
+	actor.status = StatusRunning
+	actor.mutex.Unlock()
+
+	atomic.StoreInt32(&actor.stoped, 0)
+	go rc.exec(actor)
+}
+
+func (rc *RuntimeContainer) exec(actor *RuntimeActor) {
+	defer rc.wg.Done()
+
+	min, max := actor.backoff()
+	backoff := min
+
+	var err error
+	for {
+		err = actor.run()
+		if !actor.shouldRestart(err) {
+			break
+		}
+
+		// 运行时间超过最大等待时间视为已经恢复
+		if m := actor.snapshot(); time.Since(m.StartedAt) > max {
+			backoff = min
+		}
+
+		if !rc.backoff(actor, &backoff, max) {
 			break
 		}
 	}
-	return err
+
+	actor.mutex.Lock()
+	actor.status = StatusStopped
+	actor.mutex.Unlock()
+
+	atomic.StoreInt32(&actor.stoped, 1)
+	if err != nil && rc.err.Load() == nil {
+		rc.err.Store(runtimeError{id: actor.id, err: err})
+	}
+
+	close(actor.done)
+}
+
+// backoff 等待后重新创建协程, 创建失败时加倍等待时间再次尝试
+// 协程被关闭时返回false
+func (rc *RuntimeContainer) backoff(actor *RuntimeActor, backoff *time.Duration, max time.Duration) bool {
+	for {
+		if !actor.wait(*backoff) {
+			return false
+		}
+
+		if *backoff *= 2; *backoff > max {
+			*backoff = max
+		}
+
+		err := actor.renew()
+		if actor.isClosed() {
+			return false
+		}
+
+		if err == nil {
+			return true
+		}
+
+		actor.mutex.Lock()
+		actor.err = err
+		actor.restarts++
+		actor.mutex.Unlock()
+	}
 }
 
-// Stop 关闭盒子内所有服务
-func (rc *RuntimeContainer) Stop() {
+// Stop 关闭盒子内的服务
+// 指定id时只关闭对应的协程, 可以通过Start重新运行, Run不会因此返回.
+// 没有指定id时关闭所有服务, 盒子不再运行新的协程
+func (rc *RuntimeContainer) Stop(ids ...int32) {
+	if len(ids) > 0 {
+		rc.mutex.Lock()
+		defer rc.mutex.Unlock()
+
+		for _, id := range ids {
+			m, ok := rc.actors.Load(id)
+			if !ok || atomic.LoadInt32(&m.(*RuntimeActor).stoped) == 1 {
+				continue
+			}
+
+			if rc.running && !rc.parked[id] {
+				rc.parked[id] = true
+				rc.wg.Add(1)
+			}
+
+			m.(*RuntimeActor).close()
+		}
+
+		return
+	}
+
+	// 关闭后不再运行替换的协程
+	rc.mutex.Lock()
+	rc.running = false
+	for id := range rc.parked {
+		delete(rc.parked, id)
+		rc.wg.Done()
+	}
+	rc.mutex.Unlock()
+
 	actors := rc.sortRuntimeContainer(0)
 	for i := len(actors) - 1; i >= 0; i-- {
 		actor := (*RuntimeActor)(actors[i])
@@ -118,10 +600,25 @@ func (rc *RuntimeContainer) Stop() {
 			continue
 		}
 
-		actor.Close()
+		actor.close()
 	}
 }
 
+// Status 返回所有协程的状态, 按id排序
+func (rc *RuntimeContainer) Status() []RuntimeActorStatus {
+	data := make([]RuntimeActorStatus, 0)
+	rc.actors.Range(func(k, v interface{}) bool {
+		data = append(data, v.(*RuntimeActor).snapshot())
+		return true
+	})
+
+	sort.Slice(data, func(a, b int) bool {
+		return data[a].ID < data[b].ID
+	})
+
+	return data
+}
+
 func (rc *RuntimeContainer) sortRuntimeContainer(status int32) []*RuntimeActor {
 	data := make([]*RuntimeActor, 0)
 	rc.actors.Range(func(k, v interface{}) bool {
@@ -147,6 +644,8 @@ func (rc *RuntimeContainer) sortRuntimeContainer(status int32) []*RuntimeActor {
 
 // NewRuntimeContainer 创建盒子
 func NewRuntimeContainer() *RuntimeContainer {
-	c := &RuntimeContainer{}
+	c := &RuntimeContainer{
+		parked: make(map[int32]bool),
+	}
 	return c
 }
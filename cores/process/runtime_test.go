@@ -5,6 +5,7 @@ package process
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -22,7 +23,7 @@ func TestRuntimeContainer(t *testing.T) {
 		Close: func() {
 			fmt.Println("Close:", "TEST1")
 		},
-	})
+	}, true)
 
 	n.Add(&RuntimeActor{
 		Exec: func() error {
@@ -36,7 +37,7 @@ func TestRuntimeContainer(t *testing.T) {
 		Close: func() {
 			fmt.Println("Close:", "TEST2")
 		},
-	})
+	}, true)
 
 	go func() {
 		x := make(chan struct{})
@@ -58,7 +59,7 @@ func TestRuntimeContainer(t *testing.T) {
 				fmt.Println("Close:", "TEST3")
 				close(x)
 			},
-		})
+		}, true)
 	}()
 	time.Sleep(time.Millisecond * 100)
 	go func() {
@@ -73,3 +74,93 @@ func TestRuntimeContainer(t *testing.T) {
 
 	time.Sleep(time.Second * 5)
 }
+
+func TestRuntimeContainerRestart(t *testing.T) {
+	n := NewRuntimeContainer()
+
+	var created int32
+	id, err := n.AddFunc(func() (*RuntimeActor, error) {
+		failed := atomic.AddInt32(&created, 1) < 3
+		exitChan := make(chan struct{})
+		return &RuntimeActor{
+			Exec: func() error {
+				if failed {
+					return errors.New("failed")
+				}
+
+				<-exitChan
+				return nil
+			},
+
+			Interrupt: func(err error) {},
+
+			Close: func() {
+				close(exitChan)
+			},
+
+			Restart:    RestartOnFailure,
+			Backoff:    time.Millisecond * 10,
+			MaxBackoff: time.Millisecond * 50,
+		}, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runChan := make(chan error)
+	go func() {
+		runChan <- n.Run()
+	}()
+
+	status := func() RuntimeActorStatus {
+		for _, m := range n.Status() {
+			if m.ID == id {
+				return m
+			}
+		}
+
+		t.Fatal("status not found")
+		return RuntimeActorStatus{}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&created) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+	if m := status(); m.Status != StatusRunning || m.Restarts != 2 || m.Err == nil {
+		t.Fatalf("status: %v restarts: %d err: %v", m.Status, m.Restarts, m.Err)
+	}
+
+	// 关闭后不再重启
+	n.Stop(id)
+	time.Sleep(time.Millisecond * 50)
+	if m := status(); m.Status != StatusStopped {
+		t.Fatalf("status: %v", m.Status)
+	}
+
+	if err := n.Start(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := status(); m.Status != StatusRunning || atomic.LoadInt32(&created) != 4 {
+		t.Fatalf("status: %v created: %d", m.Status, atomic.LoadInt32(&created))
+	}
+
+	if err := n.Start(100); err != ErrRuntimeActorNotFound {
+		t.Fatal(err)
+	}
+
+	n.Stop()
+	select {
+	case err := <-runChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return")
+	}
+}
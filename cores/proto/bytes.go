@@ -4,17 +4,40 @@
 package proto
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 )
 
+var (
+	// ErrInvalidUnpackValue 解包对象必须是非nil指针
+	ErrInvalidUnpackValue = errors.New("ErrInvalidUnpackValue")
+
+	// ErrFixedLength 长度与fixed标签不一致
+	ErrFixedLength = errors.New("ErrFixedLength")
+
+	// ErrLengthOverflow 长度超出长度前缀的范围
+	ErrLengthOverflow = errors.New("ErrLengthOverflow")
+
+	// ErrValueOverflow 数值超出字段的范围
+	ErrValueOverflow = errors.New("ErrValueOverflow")
+)
+
 // FastPack 快速封包
 type FastPack interface {
 	// Pack 封包
 	Pack(w *BytesBuffer) error
 }
 
+// FastUnpack 快速解包
+type FastUnpack interface {
+	// Unpack 解包
+	Unpack(r *BytesBuffer) error
+}
+
 // Pack 封包
+// 结构体字段按bin标签编码, 见BinTag
 func Pack(w *BytesBuffer, tbl interface{}) ([]byte, error) {
 	if tbl == nil {
 		return w.Data(), nil
@@ -30,13 +53,33 @@ func Pack(w *BytesBuffer, tbl interface{}) ([]byte, error) {
 		return w.Data(), nil
 	}
 
-	if err := pack(reflect.ValueOf(tbl), w); err != nil {
+	if err := pack(reflect.ValueOf(tbl), w, BinTag{Order: -1}); err != nil {
 		return nil, err
 	}
 	return w.Data(), nil
 }
 
-func pack(v reflect.Value, w *BytesBuffer) (err error) {
+func pack(v reflect.Value, w *BytesBuffer, opts BinTag) (err error) {
+	if opts.Optional {
+		if v.IsZero() {
+			return w.WriteBool(false)
+		}
+
+		if err := w.WriteBool(true); err != nil {
+			return err
+		}
+	}
+
+	if opts.Varint {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return w.WriteVarint(v.Int())
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return w.WriteUvarint(v.Uint())
+		}
+	}
+
 	switch v.Kind() {
 	case reflect.Bool:
 		err = w.WriteBool(v.Bool())
@@ -61,33 +104,45 @@ func pack(v reflect.Value, w *BytesBuffer) (err error) {
 	case reflect.Float64:
 		err = w.WriteFloat64(v.Float())
 	case reflect.String:
-		err = w.WriteString(v.String())
+		err = packBytes([]byte(v.String()), w, opts)
 	case reflect.Ptr, reflect.Interface:
 		if v.IsNil() {
 			return nil
 		}
 
-		err = pack(v.Elem(), w)
+		err = pack(v.Elem(), w, opts.Value())
 
 	case reflect.Slice:
-		if bs, ok := v.Interface().([]byte); ok {
-			err = w.WriteUint16Bytes(bs)
-		} else {
-			size := v.Len()
-			if err := w.WriteUint16(uint16(size)); err != nil {
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return packBytes(v.Bytes(), w, opts)
+		}
+
+		size := v.Len()
+		if err := packLen(size, w, opts); err != nil {
+			return err
+		}
+
+		for i := 0; i < size; i++ {
+			if err := pack(v.Index(i), w, opts.Elem()); err != nil {
 				return err
 			}
+		}
 
-			for i := 0; i < size; i++ {
-				if err := pack(v.Index(i), w); err != nil {
-					return err
-				}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := pack(v.Index(i), w, opts.Elem()); err != nil {
+				return err
 			}
 		}
 
 	case reflect.Struct:
-		for i := 0; i < v.NumField(); i++ {
-			if err := pack(v.Field(i), w); err != nil {
+		fields, err := binFields(v.Type())
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			if err := pack(v.Field(field.index), w, field.opts); err != nil {
 				return err
 			}
 		}
@@ -98,3 +153,311 @@ func pack(v reflect.Value, w *BytesBuffer) (err error) {
 
 	return
 }
+
+// packLen 写入长度前缀
+func packLen(size int, w *BytesBuffer, opts BinTag) error {
+	switch {
+	case opts.Fixed > 0:
+		if size != opts.Fixed {
+			return ErrFixedLength
+		}
+
+		return nil
+
+	case opts.Len32:
+		if uint64(size) > math.MaxUint32 {
+			return ErrLengthOverflow
+		}
+
+		return w.WriteUint32(uint32(size))
+	}
+
+	if size > math.MaxUint16 {
+		return ErrLengthOverflow
+	}
+
+	return w.WriteUint16(uint16(size))
+}
+
+func packBytes(bs []byte, w *BytesBuffer, opts BinTag) error {
+	if err := packLen(len(bs), w, opts); err != nil {
+		return err
+	}
+
+	return w.WriteBytes(bs...)
+}
+
+// Unpack 解包, Pack的逆操作
+// out必须是非nil指针, 数据按pack的编码方式从r的当前位置读取
+func Unpack(r *BytesBuffer, out interface{}) error {
+	// 如果传的对象自有解包方法
+	// 那么就直接调用它
+	if fast, ok := out.(FastUnpack); ok {
+		return fast.Unpack(r)
+	}
+
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return ErrInvalidUnpackValue
+	}
+
+	return unpack(v.Elem(), r, BinTag{Order: -1})
+}
+
+func unpack(v reflect.Value, r *BytesBuffer, opts BinTag) error {
+	if !v.CanSet() && v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+		return fmt.Errorf("Unexpected unsettable value: %v", v.Type())
+	}
+
+	if opts.Optional {
+		ok, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			if v.CanSet() {
+				v.Set(reflect.Zero(v.Type()))
+			}
+
+			return nil
+		}
+	}
+
+	if opts.Varint {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			m, err := r.ReadVarint()
+			if err != nil {
+				return err
+			}
+
+			if v.OverflowInt(m) {
+				return ErrValueOverflow
+			}
+
+			v.SetInt(m)
+			return nil
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			m, err := r.ReadUvarint()
+			if err != nil {
+				return err
+			}
+
+			if v.OverflowUint(m) {
+				return ErrValueOverflow
+			}
+
+			v.SetUint(m)
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		m, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(m)
+
+	case reflect.Uint8:
+		m, err := r.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(uint64(m))
+
+	case reflect.Uint16:
+		m, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(uint64(m))
+
+	case reflect.Uint32:
+		m, err := r.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(uint64(m))
+
+	case reflect.Uint64:
+		m, err := r.ReadUint64()
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(m)
+
+	case reflect.Int8:
+		m, err := r.ReadInt8()
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(m))
+
+	case reflect.Int16:
+		m, err := r.ReadInt16()
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(m))
+
+	case reflect.Int32:
+		m, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(m))
+
+	case reflect.Int64:
+		m, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(m)
+
+	case reflect.Float32:
+		m, err := r.ReadFloat32()
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(float64(m))
+
+	case reflect.Float64:
+		m, err := r.ReadFloat64()
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(m)
+
+	case reflect.String:
+		bs, err := unpackBytes(r, opts)
+		if err != nil {
+			return err
+		}
+
+		v.SetString(string(bs))
+
+	case reflect.Ptr:
+		// pack不写入nil指针, 解包时总是创建新的对象
+		if v.IsNil() {
+			if !v.CanSet() {
+				return fmt.Errorf("Unexpected unsettable value: %v", v.Type())
+			}
+
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return unpack(v.Elem(), r, opts.Value())
+
+	case reflect.Interface:
+		// 接口只能解包到已经赋值的指针
+		if v.IsNil() || v.Elem().Kind() != reflect.Ptr {
+			return fmt.Errorf("Unexpected interface value: %v", v.Type())
+		}
+
+		return unpack(v.Elem(), r, opts.Value())
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			bs, err := unpackBytes(r, opts)
+			if err != nil {
+				return err
+			}
+
+			m := reflect.MakeSlice(v.Type(), len(bs), len(bs))
+			reflect.Copy(m, reflect.ValueOf(bs))
+			v.Set(m)
+			return nil
+		}
+
+		size, err := unpackLen(r, opts)
+		if err != nil {
+			return err
+		}
+
+		// 每个元素至少占用一个字节, 防止错误的长度分配过多内存
+		if size > len(r.Bytes()) {
+			return ErrOutOfRange
+		}
+
+		m := reflect.MakeSlice(v.Type(), size, size)
+		for i := 0; i < size; i++ {
+			if err := unpack(m.Index(i), r, opts.Elem()); err != nil {
+				return err
+			}
+		}
+
+		v.Set(m)
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := unpack(v.Index(i), r, opts.Elem()); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		fields, err := binFields(v.Type())
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			if err := unpack(v.Field(field.index), r, field.opts); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("Unexpected type: %v", v.Kind())
+	}
+
+	return nil
+}
+
+// unpackLen 读取长度前缀
+func unpackLen(r *BytesBuffer, opts BinTag) (int, error) {
+	switch {
+	case opts.Fixed > 0:
+		return opts.Fixed, nil
+
+	case opts.Len32:
+		m, err := r.ReadUint32()
+		if err != nil {
+			return 0, err
+		}
+
+		if uint64(m) > uint64(math.MaxInt32) {
+			return 0, ErrLengthOverflow
+		}
+
+		return int(m), nil
+	}
+
+	m, err := r.ReadUint16()
+	return int(m), err
+}
+
+// unpackBytes 读取二进制, 返回的数据引用r的内容
+func unpackBytes(r *BytesBuffer, opts BinTag) ([]byte, error) {
+	size, err := unpackLen(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ReadBytes(size)
+}
@@ -4,7 +4,9 @@
 package proto
 
 import (
+	"encoding/binary"
 	"errors"
+	"io"
 	"math"
 )
 
@@ -13,6 +15,14 @@ const (
 	PacketMaxLimit = 65535
 )
 
+var (
+	// ErrOutOfRange 读取超出数据范围
+	ErrOutOfRange = errors.New("BytesBuffer: out of range")
+
+	// ErrLimitExceeded 写入超出长度限制
+	ErrLimitExceeded = errors.New("BytesBuffer: limit is exceeded")
+)
+
 // BytesBuffer  封包处理
 type BytesBuffer struct {
 	// pos 包位置指针
@@ -20,6 +30,9 @@ type BytesBuffer struct {
 
 	// data 数据
 	data []byte
+
+	// limit 最大写入长度, 为0时使用PacketMaxLimit
+	limit int
 }
 
 // Data 获取所有数据
@@ -52,7 +65,7 @@ func (b *BytesBuffer) Len() int {
 // ReadByte 读取一个字节
 func (b *BytesBuffer) ReadByte() (m byte, err error) {
 	if b.data == nil || b.pos >= len(b.data) {
-		err = errors.New("BytesBuffer: out of range")
+		err = ErrOutOfRange
 		return
 	}
 
@@ -64,7 +77,7 @@ func (b *BytesBuffer) ReadByte() (m byte, err error) {
 // ReadBytes 读取指定长度字节
 func (b *BytesBuffer) ReadBytes(size int) (m []byte, err error) {
 	if b.data == nil || (b.pos+size) > len(b.data) {
-		err = errors.New("BytesBuffer: out of range")
+		err = ErrOutOfRange
 		return
 	}
 
@@ -215,7 +228,24 @@ func (b *BytesBuffer) ReadUint16Bytes() ([]byte, error) {
 
 	if b.pos+int(size) > len(b.data) {
 		b.pos -= 2
-		return nil, errors.New("BytesBuffer: out of range")
+		return nil, ErrOutOfRange
+	}
+
+	bytes := b.data[b.pos : b.pos+int(size)]
+	b.pos += int(size)
+	return bytes, nil
+}
+
+// ReadUint32Bytes 读取uint32长度的数据
+func (b *BytesBuffer) ReadUint32Bytes() ([]byte, error) {
+	size, err := b.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(b.pos)+uint64(size) > uint64(len(b.data)) {
+		b.pos -= 4
+		return nil, ErrOutOfRange
 	}
 
 	bytes := b.data[b.pos : b.pos+int(size)]
@@ -223,14 +253,35 @@ func (b *BytesBuffer) ReadUint16Bytes() ([]byte, error) {
 	return bytes, nil
 }
 
+// ReadUvarint 读取varint编码的uint64
+func (b *BytesBuffer) ReadUvarint() (uint64, error) {
+	m, n := binary.Uvarint(b.Bytes())
+	if n == 0 {
+		return 0, ErrOutOfRange
+	}
+
+	if n < 0 {
+		return 0, errors.New("BytesBuffer: varint overflows a 64-bit integer")
+	}
+
+	b.pos += n
+	return m, nil
+}
+
+// ReadVarint 读取zigzag varint编码的int64
+func (b *BytesBuffer) ReadVarint() (int64, error) {
+	m, err := b.ReadUvarint()
+	return int64(m>>1) ^ -int64(m&1), err
+}
+
 // WriteBytes 写入数量
 func (b *BytesBuffer) WriteBytes(bytes ...byte) error {
 	if b.data == nil {
 		b.data = make([]byte, 0)
 	}
 
-	if len(b.data)+len(bytes) > PacketMaxLimit {
-		return errors.New("BytesBuffer: limit on 65535 bytes is exceeded")
+	if len(b.data)+len(bytes) > b.maxLimit() {
+		return ErrLimitExceeded
 	}
 
 	b.data = append(b.data, bytes...)
@@ -239,7 +290,16 @@ func (b *BytesBuffer) WriteBytes(bytes ...byte) error {
 
 // WriteZeros 写入指定数量的0
 func (b *BytesBuffer) WriteZeros(n int) error {
-	return b.WriteBytes(make([]byte, n)...)
+	if len(b.data)+n > b.maxLimit() {
+		return ErrLimitExceeded
+	}
+
+	b.Grow(n)
+	for i := 0; i < n; i++ {
+		b.data = append(b.data, 0)
+	}
+
+	return nil
 }
 
 // WriteBool 写入一个布尔
@@ -327,20 +387,103 @@ func (b *BytesBuffer) WriteUint16Bytes(bytes []byte) error {
 		b.data = make([]byte, 0)
 	}
 
-	if len(b.data)+2+len(bytes) > PacketMaxLimit {
-		return errors.New("BytesBuffer: limit on 65535 bytes is exceeded")
+	if len(b.data)+2+len(bytes) > b.maxLimit() {
+		return ErrLimitExceeded
 	}
 
 	b.WriteUint16(uint16(len(bytes)))
 	return b.WriteBytes(bytes...)
 }
 
+// WriteUint32Bytes 写入带有长度为uint32的二进制
+func (b *BytesBuffer) WriteUint32Bytes(bytes []byte) error {
+	if b.data == nil {
+		b.data = make([]byte, 0)
+	}
+
+	if len(b.data)+4+len(bytes) > b.maxLimit() {
+		return ErrLimitExceeded
+	}
+
+	b.WriteUint32(uint32(len(bytes)))
+	return b.WriteBytes(bytes...)
+}
+
+// WriteUvarint 写入varint编码的uint64
+func (b *BytesBuffer) WriteUvarint(v uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return b.WriteBytes(buf[:n]...)
+}
+
+// WriteVarint 写入zigzag varint编码的int64
+func (b *BytesBuffer) WriteVarint(v int64) error {
+	return b.WriteUvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
 // Reset 重置
 func (b *BytesBuffer) Reset() {
 	b.pos = 0
 	b.data = make([]byte, 0)
 }
 
+// Grow 扩充容量, 保证还能写入n个字节而不需要重新分配内存
+func (b *BytesBuffer) Grow(n int) {
+	if n < 0 || cap(b.data)-len(b.data) >= n {
+		return
+	}
+
+	data := make([]byte, len(b.data), 2*cap(b.data)+n)
+	copy(data, b.data)
+	b.data = data
+}
+
+// Truncate 只保留前n个字节, 不释放容量
+func (b *BytesBuffer) Truncate(n int) {
+	if n < 0 || n > len(b.data) {
+		return
+	}
+
+	b.data = b.data[:n]
+	if b.pos > n {
+		b.pos = n
+	}
+}
+
+// ReadFrom 从r读取数据直到io.EOF并追加到末尾, 实现io.ReaderFrom
+func (b *BytesBuffer) ReadFrom(r io.Reader) (n int64, err error) {
+	for {
+		if len(b.data) == cap(b.data) {
+			b.Grow(bytesBufferPoolSize)
+		}
+
+		m, e := r.Read(b.data[len(b.data):cap(b.data)])
+		b.data = b.data[:len(b.data)+m]
+		n += int64(m)
+		if e == io.EOF {
+			return n, nil
+		}
+
+		if e != nil {
+			return n, e
+		}
+	}
+}
+
+// SetLimit 设置最大写入长度, 默认为PacketMaxLimit
+// 用于组装超过PacketMaxLimit的大数据帧
+func (b *BytesBuffer) SetLimit(n int) {
+	b.limit = n
+}
+
+func (b *BytesBuffer) maxLimit() int {
+	if b.limit > 0 {
+		return b.limit
+	}
+
+	return PacketMaxLimit
+}
+
 // ResetPos 重置pos的位置
 func (b *BytesBuffer) ResetPos() {
 	b.pos = 0
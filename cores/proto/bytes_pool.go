@@ -0,0 +1,39 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import "sync"
+
+const (
+	// bytesBufferPoolSize 缓冲池新建缓冲区的初始容量
+	bytesBufferPoolSize = 512
+
+	// bytesBufferPoolMaxSize 超过该容量的缓冲区不再放回缓冲池
+	// 防止偶发的大包长期占用内存
+	bytesBufferPoolMaxSize = PacketMaxLimit + 1024
+)
+
+// bytesBufferPool BytesBuffer缓冲池
+var bytesBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &BytesBuffer{data: make([]byte, 0, bytesBufferPoolSize)}
+	},
+}
+
+// AcquireBytesBuffer 从缓冲池获取一个空的BytesBuffer
+// 使用完毕后调用ReleaseBytesBuffer归还, 归还后不能再使用该缓冲区以及从中读取的数据
+func AcquireBytesBuffer() *BytesBuffer {
+	return bytesBufferPool.Get().(*BytesBuffer)
+}
+
+// ReleaseBytesBuffer 归还BytesBuffer到缓冲池
+func ReleaseBytesBuffer(b *BytesBuffer) {
+	if b == nil || cap(b.data) > bytesBufferPoolMaxSize {
+		return
+	}
+
+	b.pos = 0
+	b.limit = 0
+	b.data = b.data[:0]
+	bytesBufferPool.Put(b)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BinTag 字段的bin标签, 反射编码与packgen生成的代码共用
+// 标签格式为逗号分隔的选项, 例如 `bin:"order=1,varint"`, `bin:"-"` 跳过该字段.
+// order=N 字段顺序, 有order的字段按N升序排在前面, 其它字段按声明顺序排在后面;
+// varint 整数使用varint编码, 有符号整数使用zigzag编码, 可以用于int与uint, zigzag同varint;
+// len32 字符串与切片使用uint32长度前缀;
+// fixed=N 字符串与切片固定长度N, 不写入长度前缀;
+// optional 写入一个字节表示字段是否存在, 零值不写入字段内容.
+// 切片元素沿用字段的varint选项
+type BinTag struct {
+	// Skip 标签为"-", 字段不参与编码
+	Skip bool
+
+	// Order 字段顺序, 没有设置时为-1
+	Order int
+
+	Varint   bool
+	Len32    bool
+	Optional bool
+	Fixed    int
+}
+
+// Elem 切片元素使用的选项
+func (o BinTag) Elem() BinTag {
+	return BinTag{Order: -1, Varint: o.Varint}
+}
+
+// Value 指针指向的值使用的选项, 存在标记只写入一次
+func (o BinTag) Value() BinTag {
+	o.Optional = false
+	return o
+}
+
+// ParseBinTag 解析bin标签, 空字符串为默认选项
+func ParseBinTag(tag string) (BinTag, error) {
+	opts := BinTag{Order: -1}
+	if tag == "-" {
+		opts.Skip = true
+		return opts, nil
+	}
+
+	for _, name := range strings.Split(tag, ",") {
+		var value string
+		if idx := strings.Index(name, "="); idx > -1 {
+			name, value = name[:idx], name[idx+1:]
+		}
+
+		switch strings.TrimSpace(name) {
+		case "":
+		case "varint", "zigzag":
+			opts.Varint = true
+
+		case "len32":
+			opts.Len32 = true
+
+		case "optional":
+			opts.Optional = true
+
+		case "order", "fixed":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("Unexpected bin tag: %q", tag)
+			}
+
+			if name == "order" {
+				opts.Order = n
+			} else {
+				opts.Fixed = n
+			}
+
+		default:
+			return opts, fmt.Errorf("Unexpected bin tag: %q", tag)
+		}
+	}
+
+	return opts, nil
+}
+
+// BinOrder 按声明顺序的字段标签返回参与编码的字段下标, 按编码顺序排列
+func BinOrder(tags []BinTag) []int {
+	var ordered, others []int
+	for i, opts := range tags {
+		switch {
+		case opts.Skip:
+		case opts.Order > -1:
+			ordered = append(ordered, i)
+		default:
+			others = append(others, i)
+		}
+	}
+
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return tags[ordered[a]].Order < tags[ordered[b]].Order
+	})
+
+	return append(ordered, others...)
+}
+
+// binField 参与编码的字段
+type binField struct {
+	index int
+	opts  BinTag
+}
+
+// binFieldsCache 结构体字段缓存 key为reflect.Type
+var binFieldsCache sync.Map
+
+// binFields 返回结构体参与编码的字段, 按编码顺序排列
+func binFields(t reflect.Type) ([]binField, error) {
+	if m, ok := binFieldsCache.Load(t); ok {
+		return m.([]binField), nil
+	}
+
+	tags := make([]BinTag, t.NumField())
+	for i := range tags {
+		opts, err := ParseBinTag(t.Field(i).Tag.Get("bin"))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t.Name(), t.Field(i).Name, err)
+		}
+
+		tags[i] = opts
+	}
+
+	order := BinOrder(tags)
+	fields := make([]binField, len(order))
+	for i, index := range order {
+		fields[i] = binField{index: index, opts: tags[index]}
+	}
+
+	binFieldsCache.Store(t, fields)
+	return fields, nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testPackItem struct {
+	ID   uint32
+	Name string
+}
+
+type testPackRaw []byte
+
+type testPackValue struct {
+	B    bool
+	U8   uint8
+	U16  uint16
+	U32  uint32
+	U64  uint64
+	I8   int8
+	I16  int16
+	I32  int32
+	I64  int64
+	F32  float32
+	F64  float64
+	S    string
+	Data []byte
+	Raw  testPackRaw
+	Ids  []int32
+	Item testPackItem
+	Ptr  *testPackItem
+	List []*testPackItem
+}
+
+type testFastValue struct {
+	A uint16
+	B uint16
+}
+
+func (v *testFastValue) Pack(w *BytesBuffer) error {
+	w.WriteUint16(v.B)
+	return w.WriteUint16(v.A)
+}
+
+func (v *testFastValue) Unpack(r *BytesBuffer) (err error) {
+	if v.B, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	v.A, err = r.ReadUint16()
+	return
+}
+
+func TestUnpack(t *testing.T) {
+	in := &testPackValue{
+		B:    true,
+		U8:   0xff,
+		U16:  0xfffe,
+		U32:  0xfffffffd,
+		U64:  0xfffffffffffffffc,
+		I8:   -1,
+		I16:  -2,
+		I32:  -3,
+		I64:  -4,
+		F32:  1.5,
+		F64:  -2.25,
+		S:    "balala",
+		Data: []byte{1, 2, 3},
+		Raw:  testPackRaw{4, 5},
+		Ids:  []int32{1, -1, 65535},
+		Item: testPackItem{ID: 1, Name: "item"},
+		Ptr:  &testPackItem{ID: 2, Name: "ptr"},
+		List: []*testPackItem{{ID: 3}, {ID: 4, Name: "list"}},
+	}
+
+	var w BytesBuffer
+	data, err := Pack(&w, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &testPackValue{}
+	if err := Unpack(NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, in)
+	}
+
+	// 解包后的[]byte不能引用原始数据
+	for i := range data {
+		data[i] = 0
+	}
+
+	if !reflect.DeepEqual(out.Data, in.Data) || !reflect.DeepEqual(out.Raw, in.Raw) {
+		t.Fatalf("Unexpected bytes: %v %v", out.Data, out.Raw)
+	}
+
+	// 接口中的指针
+	var iface interface{} = &testPackItem{}
+	if err := Unpack(NewBytesBuffer(mustPack(t, &testPackItem{ID: 5, Name: "iface"})), &iface); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := iface.(*testPackItem); m.ID != 5 || m.Name != "iface" {
+		t.Fatalf("Unexpected item: %+v", m)
+	}
+}
+
+func TestUnpackFast(t *testing.T) {
+	in := &testFastValue{A: 1, B: 2}
+	out := &testFastValue{}
+	if err := Unpack(NewBytesBuffer(mustPack(t, in)), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if *in != *out {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, in)
+	}
+}
+
+func TestUnpackError(t *testing.T) {
+	var m testPackItem
+	if err := Unpack(NewBytesBuffer(nil), m); err != ErrInvalidUnpackValue {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 数据不完整
+	data := mustPack(t, &testPackItem{ID: 1, Name: "item"})
+	if err := Unpack(NewBytesBuffer(data[:len(data)-1]), &m); err == nil {
+		t.Fatal("Unexpected nil error")
+	}
+
+	var n struct{ N int }
+	if err := Unpack(NewBytesBuffer(make([]byte, 8)), &n); err == nil {
+		t.Fatal("Unexpected nil error")
+	}
+}
+
+func mustPack(t *testing.T, v interface{}) []byte {
+	var w BytesBuffer
+	data, err := Pack(&w, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+type testTagValue struct {
+	Skip     string           `bin:"-"`
+	Last     uint8            `bin:"order=2"`
+	First    uint8            `bin:"order=1"`
+	N        int              `bin:"varint"`
+	U        uint32           `bin:"zigzag"`
+	Ids      []int64          `bin:"varint,len32"`
+	Code     [4]byte          ``
+	Name     string           `bin:"fixed=3"`
+	Opt      *testPackItem    `bin:"optional"`
+	Missing  *testPackItem    `bin:"optional"`
+	OptSlice []uint16         `bin:"optional"`
+	Big      []byte           `bin:"len32"`
+	Map      map[string]int32 `bin:"-"`
+}
+
+func TestPackTags(t *testing.T) {
+	in := &testTagValue{
+		Skip:  "skip",
+		Last:  2,
+		First: 1,
+		N:     -300,
+		U:     300,
+		Ids:   []int64{-1, 1, 1 << 40},
+		Code:  [4]byte{'b', 'a', 'l', 'a'},
+		Name:  "abc",
+		Opt:   &testPackItem{ID: 7, Name: "opt"},
+		Big:   []byte{9},
+		Map:   map[string]int32{"a": 1},
+	}
+
+	data := mustPack(t, in)
+	expected := []byte{
+		1, 2, // order
+		0xd7, 0x04, // zigzag -300
+		0xac, 0x02, // varint 300
+		0, 0, 0, 3, 0x01, 0x02, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40, // len32 + zigzag
+		'b', 'a', 'l', 'a', // array
+		'a', 'b', 'c', // fixed
+		1, 0, 0, 0, 7, 0, 3, 'o', 'p', 't', // optional
+		0,             // missing
+		0,             // nil slice
+		0, 0, 0, 1, 9, // len32 bytes
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%v'\nExpected: '%v'\n", data, expected)
+	}
+
+	out := &testTagValue{}
+	if err := Unpack(NewBytesBuffer(data), out); err != nil {
+		t.Fatal(err)
+	}
+
+	in.Skip, in.Map = "", nil
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", out, in)
+	}
+
+	in.Name = "abcd"
+	var w BytesBuffer
+	if _, err := Pack(&w, in); err != ErrFixedLength {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var overflow struct {
+		N int8 `bin:"varint"`
+	}
+
+	if err := Unpack(NewBytesBuffer([]byte{0xac, 0x02}), &overflow); err != ErrValueOverflow {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var invalid struct {
+		N int8 `bin:"unknown"`
+	}
+
+	if _, err := Pack(&w, &invalid); err == nil {
+		t.Fatal("Unexpected nil error")
+	}
+}
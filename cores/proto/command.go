@@ -16,13 +16,27 @@ func (c Command) Int16() int16 {
 // 内部命令定义
 const (
 	InternalBad Command = 110
+
+	// InternalHandshake 密钥交换
+	InternalHandshake Command = 111
+
+	// InternalLogin 客户端认证
+	InternalLogin Command = 112
+
+	// InternalKick 服务器踢掉客户端, 内容为原因
+	InternalKick Command = 113
+)
+
+const (
+	// PushSeqID 服务器主动推送信息使用的编号
+	PushSeqID uint32 = 0xFFFFFFFF
 )
 
 // 错误信息定义
 var (
 
 	// ErrInvalidCommand 非法的命令
-	ErrInvalidCommand = errors.New("ErrInvalidCommand")
+	ErrInvalidCommand = NewError(CodeInvalidCommand, "ErrInvalidCommand")
 
 	// ErrInvalidMetadata 非法的metadata信息
 	ErrInvalidMetadata = errors.New("ErrInvalidMetadata")
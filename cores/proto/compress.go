@@ -0,0 +1,113 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/snappy"
+)
+
+// Compression 数据压缩算法, 在密钥交换时协商
+// 只协商已经注册实现的算法, 其它算法可以通过RegisterCompressor注册
+type Compression int8
+
+const (
+	// CompressionNone 不压缩
+	CompressionNone Compression = iota
+
+	// CompressionSnappy snappy块压缩, 默认支持
+	CompressionSnappy
+)
+
+// VerCompressed Ver的最高位, 表示Content经过压缩
+// 压缩只作用于Content, 头部保持不变
+const VerCompressed int8 = -0x80
+
+var (
+	// ErrCompressorNotFound 压缩算法没有注册
+	ErrCompressorNotFound = errors.New("ErrCompressorNotFound")
+)
+
+// Compressor 压缩算法实现
+type Compressor interface {
+	// Compress 压缩src并追加到w
+	Compress(w *BytesBuffer, src []byte) error
+
+	// Decompress 解压src并追加到w, 解压后的长度受w的写入长度限制
+	Decompress(w *BytesBuffer, src []byte) error
+}
+
+var (
+	compressors      = map[Compression]Compressor{CompressionSnappy: snappyCompressor{}}
+	compressorsMutex sync.RWMutex
+)
+
+// RegisterCompressor 注册压缩算法实现, 相同的算法会被替换
+func RegisterCompressor(c Compression, compressor Compressor) {
+	compressorsMutex.Lock()
+	defer compressorsMutex.Unlock()
+	compressors[c] = compressor
+}
+
+// GetCompressor 获取压缩算法实现
+func GetCompressor(c Compression) (Compressor, bool) {
+	compressorsMutex.RLock()
+	defer compressorsMutex.RUnlock()
+	compressor, ok := compressors[c]
+	return compressor, ok
+}
+
+// CompressFrame 压缩封包后的数据帧并追加到w
+// frame为RequestBytes或ResponseBytes封包后的数据, 压缩后Ver带有VerCompressed标记
+func CompressFrame(w *BytesBuffer, frame []byte, compressor Compressor) error {
+	if len(frame) < bytesHeaderSize {
+		return ErrOutOfRange
+	}
+
+	if err := w.WriteInt8(int8(frame[0]) | VerCompressed); err != nil {
+		return err
+	}
+
+	if err := w.WriteBytes(frame[1:bytesHeaderSize]...); err != nil {
+		return err
+	}
+
+	return compressor.Compress(w, frame[bytesHeaderSize:])
+}
+
+// snappyCompressor snappy块压缩
+type snappyCompressor struct{}
+
+func (snappyCompressor) Compress(w *BytesBuffer, src []byte) error {
+	size := snappy.MaxEncodedLen(len(src))
+	if size < 0 || len(w.data)+size > w.maxLimit() {
+		return ErrLimitExceeded
+	}
+
+	w.Grow(size)
+	m := snappy.Encode(w.data[len(w.data):cap(w.data)], src)
+	w.data = w.data[:len(w.data)+len(m)]
+	return nil
+}
+
+func (snappyCompressor) Decompress(w *BytesBuffer, src []byte) error {
+	size, err := snappy.DecodedLen(src)
+	if err != nil {
+		return err
+	}
+
+	if len(w.data)+size > w.maxLimit() {
+		return ErrLimitExceeded
+	}
+
+	w.Grow(size)
+	m, err := snappy.Decode(w.data[len(w.data):len(w.data)+size], src)
+	if err != nil {
+		return err
+	}
+
+	w.data = w.data[:len(w.data)+len(m)]
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/doublemo/balala/cores/proto/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 通用错误码
+// 业务服务自定义的错误码从CodeCustom开始
+const (
+	// CodeUnknown 未知错误
+	CodeUnknown int32 = 1
+
+	// CodeInvalidCommand 非法的命令
+	CodeInvalidCommand int32 = 2
+
+	// CodeInvalidRequest 非法的请求
+	CodeInvalidRequest int32 = 3
+
+	// CodeUnauthorized 未认证或者认证失败
+	CodeUnauthorized int32 = 4
+
+	// CodeServiceUnavailable 服务不可用
+	CodeServiceUnavailable int32 = 5
+
+	// CodeTimeout 请求超时
+	CodeTimeout int32 = 6
+
+	// CodeTooManyRequests 请求过于频繁
+	CodeTooManyRequests int32 = 7
+
+	// CodeCustom 业务服务自定义错误码的起始值
+	CodeCustom int32 = 1000
+)
+
+// ErrInternal 服务器内部错误, 不能直接返回给客户端的错误都转换为该错误
+var ErrInternal = NewError(CodeUnknown, "ErrInternal")
+
+// Error 返回给客户端的错误
+// 通过pb.Bad传递给客户端, 见ResponseBytes
+type Error struct {
+	// Code 错误码
+	Code int32
+
+	// Key 客户端国际化使用的信息key, 可以为空
+	Key string
+
+	// Message 错误信息
+	Message string
+
+	// Retryable 客户端是否可以重试
+	Retryable bool
+}
+
+// Error 错误信息, 没有信息时为错误码
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return strconv.FormatInt(int64(e.Code), 10)
+}
+
+// Is 错误码相同即为同一错误, 用于errors.Is
+func (e *Error) Is(target error) bool {
+	m, ok := target.(*Error)
+	return ok && m.Code == e.Code
+}
+
+// WithKey 返回设置了国际化key的副本
+func (e *Error) WithKey(key string) *Error {
+	m := *e
+	m.Key = key
+	return &m
+}
+
+// WithMessage 返回设置了错误信息的副本
+func (e *Error) WithMessage(message string) *Error {
+	m := *e
+	m.Message = message
+	return &m
+}
+
+// WithRetryable 返回可以重试的副本
+func (e *Error) WithRetryable() *Error {
+	m := *e
+	m.Retryable = true
+	return &m
+}
+
+// Bad 转换为pb.Bad
+func (e *Error) Bad(cmd, subcmd Command) *pb.Bad {
+	return &pb.Bad{
+		Command:    int32(cmd),
+		SubCommand: int32(subcmd),
+		Code:       e.Code,
+		Message:    e.Message,
+		Key:        e.Key,
+		Retryable:  e.Retryable,
+	}
+}
+
+// NewError 创建
+func NewError(code int32, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// NewErrorFromBad 从pb.Bad创建
+func NewErrorFromBad(bad *pb.Bad) *Error {
+	return &Error{
+		Code:      bad.GetCode(),
+		Key:       bad.GetKey(),
+		Message:   bad.GetMessage(),
+		Retryable: bad.GetRetryable(),
+	}
+}
+
+// FromError 将任意错误转换为Error
+// 信息为数字的错误使用该数字作为错误码, grpc错误按状态码转换,
+// 其它错误的信息可能包含服务器内部的细节, 统一转换为ErrInternal, 原始错误由调用者记录
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	// 兼容以错误码作为错误信息的旧错误
+	if m, e := strconv.ParseInt(err.Error(), 10, 32); e == nil {
+		return &Error{Code: int32(m), Message: err.Error()}
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return &Error{Code: CodeServiceUnavailable, Message: s.Message(), Retryable: true}
+
+		case codes.DeadlineExceeded:
+			return &Error{Code: CodeTimeout, Message: s.Message(), Retryable: true}
+
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return &Error{Code: CodeUnauthorized, Message: s.Message()}
+
+		case codes.InvalidArgument:
+			return &Error{Code: CodeInvalidRequest, Message: s.Message()}
+		}
+	}
+
+	return ErrInternal
+}
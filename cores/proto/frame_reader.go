@@ -0,0 +1,134 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+)
+
+// FrameReader 读取长度前缀的数据帧
+// 长度头格式见Framing, 带有分片标记的帧会被重组为一条完整的消息,
+// 数据读入缓冲池中的BytesBuffer
+type FrameReader struct {
+	r io.Reader
+
+	// framing 长度头格式, 可以在其它协程中修改
+	framing int32
+
+	// maxSize 消息的最大长度, 包括重组后的长度
+	maxSize int
+
+	header [MaxFrameHeaderSize]byte
+}
+
+// SetFraming 设置长度头格式, 从下一个长度头开始生效
+// 可以在ReadFrame阻塞时从其它协程调用
+func (fr *FrameReader) SetFraming(f Framing) {
+	atomic.StoreInt32(&fr.framing, int32(f))
+}
+
+// Framing 当前的长度头格式
+func (fr *FrameReader) Framing() Framing {
+	return Framing(atomic.LoadInt32(&fr.framing))
+}
+
+// SetMaxSize 设置消息的最大长度, 为0时只受长度头格式限制
+func (fr *FrameReader) SetMaxSize(n int) {
+	fr.maxSize = n
+}
+
+// ReadFrame 读取一条消息
+// 返回的缓冲区使用完毕后需要调用ReleaseBytesBuffer归还
+func (fr *FrameReader) ReadFrame() (*BytesBuffer, error) {
+	b := AcquireBytesBuffer()
+	for {
+		size, more, err := fr.readHeader()
+		if err != nil {
+			ReleaseBytesBuffer(b)
+			return nil, err
+		}
+
+		offset := len(b.data)
+		if fr.maxSize > 0 && offset+size > fr.maxSize {
+			ReleaseBytesBuffer(b)
+			return nil, ErrFrameTooLarge
+		}
+
+		b.Grow(size)
+		b.data = b.data[:offset+size]
+		if _, err := io.ReadFull(fr.r, b.data[offset:]); err != nil {
+			ReleaseBytesBuffer(b)
+			return nil, err
+		}
+
+		if !more {
+			return b, nil
+		}
+	}
+}
+
+// readHeader 读取长度头
+// 先读取第一个字节再确定格式, 使阻塞期间协商的格式对这个长度头生效
+func (fr *FrameReader) readHeader() (int, bool, error) {
+	if _, err := io.ReadFull(fr.r, fr.header[:1]); err != nil {
+		return 0, false, err
+	}
+
+	switch fr.Framing() {
+	case FramingUint32:
+		if _, err := io.ReadFull(fr.r, fr.header[1:4]); err != nil {
+			return 0, false, unexpectedEOF(err)
+		}
+
+		m := binary.BigEndian.Uint32(fr.header[:4])
+		return int(m &^ frameMoreBit), m&frameMoreBit != 0, nil
+
+	case FramingVarint:
+		var m uint64
+		for i := 0; ; i++ {
+			if i > 0 {
+				if _, err := io.ReadFull(fr.r, fr.header[i:i+1]); err != nil {
+					return 0, false, unexpectedEOF(err)
+				}
+			}
+
+			m |= uint64(fr.header[i]&0x7f) << (7 * uint(i))
+			if fr.header[i] < 0x80 {
+				break
+			}
+
+			if i == MaxFrameHeaderSize-1 {
+				return 0, false, ErrFrameTooLarge
+			}
+		}
+
+		if m>>1 > MaxFrameSize {
+			return 0, false, ErrFrameTooLarge
+		}
+
+		return int(m >> 1), m&1 != 0, nil
+	}
+
+	if _, err := io.ReadFull(fr.r, fr.header[1:2]); err != nil {
+		return 0, false, unexpectedEOF(err)
+	}
+
+	return int(binary.BigEndian.Uint16(fr.header[:2])), false, nil
+}
+
+// unexpectedEOF 长度头读取了一部分后遇到EOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// NewFrameReader 创建
+// 默认使用FramingUint16
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: r}
+}
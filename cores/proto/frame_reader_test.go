@@ -0,0 +1,231 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFrameReader(t *testing.T) {
+	var w bytes.Buffer
+	frames := [][]byte{[]byte("balala"), {}, bytes.Repeat([]byte{0x7f}, 4096)}
+	for _, frame := range frames {
+		binary.Write(&w, binary.BigEndian, uint16(len(frame)))
+		w.Write(frame)
+	}
+
+	fr := NewFrameReader(&w)
+	for _, frame := range frames {
+		b, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b.Bytes(), frame) {
+			t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", b.Bytes(), frame)
+		}
+
+		ReleaseBytesBuffer(b)
+	}
+
+	if _, err := fr.ReadFrame(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	// 长度头完整但数据不足
+	fr = NewFrameReader(bytes.NewReader([]byte{0, 8, 1, 2}))
+	if _, err := fr.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFrameReaderFraming(t *testing.T) {
+	message := bytes.Repeat([]byte("balala"), 20000)
+	for _, framing := range []Framing{FramingUint32, FramingVarint} {
+		var w []byte
+		var err error
+
+		// 一条完整的大消息, 一条分为3片的消息
+		w, _ = framing.AppendHeader(w, len(message), false)
+		w = append(w, message...)
+		for i, chunk := range [][]byte{message[:1], message[1:5000], message[5000:]} {
+			w, err = framing.AppendHeader(w, len(chunk), i < 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w = append(w, chunk...)
+		}
+
+		fr := NewFrameReader(bytes.NewReader(w))
+		fr.SetFraming(framing)
+		for i := 0; i < 2; i++ {
+			b, err := fr.ReadFrame()
+			if err != nil {
+				t.Fatal(framing, err)
+			}
+
+			if !bytes.Equal(b.Bytes(), message) {
+				t.Fatalf("%v: message %d mismatch, size %d", framing, i, b.Len())
+			}
+
+			ReleaseBytesBuffer(b)
+		}
+
+		// 重组后超出长度限制
+		fr = NewFrameReader(bytes.NewReader(w))
+		fr.SetFraming(framing)
+		fr.SetMaxSize(len(message) - 1)
+		if _, err := fr.ReadFrame(); err != ErrFrameTooLarge {
+			t.Fatalf("%v: expected ErrFrameTooLarge, got %v", framing, err)
+		}
+	}
+
+	if _, err := FramingUint16.AppendHeader(nil, 1, true); err != ErrFrameChunked {
+		t.Fatalf("expected ErrFrameChunked, got %v", err)
+	}
+
+	if _, err := FramingUint16.AppendHeader(nil, PacketMaxLimit+1, false); err != ErrFrameTooLarge {
+		t.Fatalf("expected ErrFrameTooLarge, got %v", err)
+	}
+
+	// varint长度头超过5个字节
+	fr := NewFrameReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0x01}))
+	fr.SetFraming(FramingVarint)
+	if _, err := fr.ReadFrame(); err != ErrFrameTooLarge {
+		t.Fatalf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
+
+func TestCompressFrame(t *testing.T) {
+	req := &RequestBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 154, Content: bytes.Repeat([]byte("balala"), 1000)}
+	frame, _ := req.Marshal()
+	compressor, ok := GetCompressor(CompressionSnappy)
+	if !ok {
+		t.Fatal("snappy is not registered")
+	}
+
+	w := AcquireBytesBuffer()
+	defer ReleaseBytesBuffer(w)
+	if err := CompressFrame(w, frame, compressor); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Len() >= len(frame) {
+		t.Fatalf("expected compressed size < %d, got %d", len(frame), w.Len())
+	}
+
+	compressed := &RequestBytes{}
+	compressed.Unmarshal(w.Data())
+	if compressed.Ver&VerCompressed == 0 {
+		t.Fatal("VerCompressed is not set")
+	}
+
+	// 解压后的长度受限制
+	r := AcquireBytesBuffer()
+	defer ReleaseBytesBuffer(r)
+	r.SetLimit(len(req.Content) - 1)
+	if err := compressed.Decompress(r, compressor); err != ErrLimitExceeded {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	r.SetLimit(len(req.Content))
+	if err := compressed.Decompress(r, compressor); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(compressed, req) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", compressed.Ver, req.Ver)
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	resp := &ResponseBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 154, Content: []byte("balala")}
+	b0, err := resp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := AcquireBytesBuffer()
+	defer ReleaseBytesBuffer(w)
+	w.WriteUint16(0)
+	if err := resp.MarshalTo(w); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(b0, w.Data()[2:]) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", w.Data()[2:], b0)
+	}
+}
+
+// benchFrames 基准测试使用的帧流
+func benchFrames(n int) []byte {
+	var w bytes.Buffer
+	frame := &RequestBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 1, Content: make([]byte, 128)}
+	for i := 0; i < n; i++ {
+		b, _ := frame.Marshal()
+		binary.Write(&w, binary.BigEndian, uint16(len(b)))
+		w.Write(b)
+	}
+
+	return w.Bytes()
+}
+
+// BenchmarkReadFrameAlloc 每帧分配新内存的读取方式, 作为对照
+func BenchmarkReadFrameAlloc(b *testing.B) {
+	data := benchFrames(1024)
+	r := bytes.NewReader(data)
+	header := make([]byte, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if r.Len() < 1 {
+			r.Reset(data)
+		}
+
+		io.ReadFull(r, header)
+		payload := make([]byte, binary.BigEndian.Uint16(header))
+		io.ReadFull(r, payload)
+
+		var req RequestBytes
+		req.Unmarshal(payload)
+	}
+}
+
+func BenchmarkFrameReader(b *testing.B) {
+	data := benchFrames(1024)
+	r := bytes.NewReader(data)
+	fr := NewFrameReader(r)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if r.Len() < 1 {
+			r.Reset(data)
+		}
+
+		frame, _ := fr.ReadFrame()
+		var req RequestBytes
+		req.Unmarshal(frame.Bytes())
+		ReleaseBytesBuffer(frame)
+	}
+}
+
+func BenchmarkResponseBytesMarshal(b *testing.B) {
+	resp := &ResponseBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 1, Content: make([]byte, 128)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp.Marshal()
+	}
+}
+
+func BenchmarkResponseBytesMarshalTo(b *testing.B) {
+	resp := &ResponseBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 1, Content: make([]byte, 128)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := AcquireBytesBuffer()
+		resp.MarshalTo(w)
+		ReleaseBytesBuffer(w)
+	}
+}
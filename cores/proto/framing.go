@@ -0,0 +1,90 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Framing 数据帧长度头格式, 在密钥交换时协商
+type Framing int32
+
+const (
+	// FramingUint16 2字节大端长度, 不支持分片, 默认格式
+	FramingUint16 Framing = iota
+
+	// FramingUint32 4字节大端长度, 最高位为分片标记
+	FramingUint32
+
+	// FramingVarint varint编码的长度, 长度左移一位后最低位为分片标记
+	FramingVarint
+)
+
+const (
+	// MaxFrameHeaderSize 长度头的最大长度
+	MaxFrameHeaderSize = 5
+
+	// MaxFrameSize 长度头能够表示的最大数据长度
+	MaxFrameSize = math.MaxInt32
+
+	// frameMoreBit FramingUint32的分片标记
+	frameMoreBit = 1 << 31
+)
+
+var (
+	// ErrFrameTooLarge 数据帧超出长度限制
+	ErrFrameTooLarge = errors.New("ErrFrameTooLarge")
+
+	// ErrFrameChunked 长度头格式不支持分片
+	ErrFrameChunked = errors.New("ErrFrameChunked")
+)
+
+// IsValid 是否是支持的格式
+func (f Framing) IsValid() bool {
+	return f >= FramingUint16 && f <= FramingVarint
+}
+
+// MaxSize 单帧数据的最大长度
+func (f Framing) MaxSize() int {
+	if f == FramingUint16 {
+		return math.MaxUint16
+	}
+
+	return MaxFrameSize
+}
+
+// AppendHeader 将长度头追加到dst
+// more 表示后面还有同一消息的分片, FramingUint16不支持分片
+func (f Framing) AppendHeader(dst []byte, size int, more bool) ([]byte, error) {
+	if size < 0 || size > f.MaxSize() {
+		return nil, ErrFrameTooLarge
+	}
+
+	switch f {
+	case FramingUint32:
+		m := uint32(size)
+		if more {
+			m |= frameMoreBit
+		}
+
+		return append(dst, byte(m>>24), byte(m>>16), byte(m>>8), byte(m)), nil
+
+	case FramingVarint:
+		m := uint64(size) << 1
+		if more {
+			m |= 1
+		}
+
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], m)
+		return append(dst, buf[:n]...), nil
+	}
+
+	if more {
+		return nil, ErrFrameChunked
+	}
+
+	return append(dst, byte(size>>8), byte(size)), nil
+}
@@ -25,6 +25,8 @@ type Bad struct {
 	SubCommand           int32    `protobuf:"varint,2,opt,name=SubCommand,proto3" json:"SubCommand,omitempty"`
 	Code                 int32    `protobuf:"varint,3,opt,name=Code,proto3" json:"Code,omitempty"`
 	Message              string   `protobuf:"bytes,4,opt,name=Message,proto3" json:"Message,omitempty"`
+	Key                  string   `protobuf:"bytes,5,opt,name=Key,proto3" json:"Key,omitempty"`
+	Retryable            bool     `protobuf:"varint,6,opt,name=Retryable,proto3" json:"Retryable,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -83,6 +85,20 @@ func (m *Bad) GetMessage() string {
 	return ""
 }
 
+func (m *Bad) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Bad) GetRetryable() bool {
+	if m != nil {
+		return m.Retryable
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*Bad)(nil), "pb.Bad")
 }
@@ -90,13 +106,15 @@ func init() {
 func init() { proto.RegisterFile("bad.proto", fileDescriptor_488ac25a43dcd766) }
 
 var fileDescriptor_488ac25a43dcd766 = []byte{
-	// 116 bytes of a gzipped FileDescriptorProto
+	// 149 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4c, 0x4a, 0x4c, 0xd1,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2a, 0x48, 0x52, 0xca, 0xe5, 0x62, 0x76, 0x4a, 0x4c,
-	0x11, 0x92, 0xe0, 0x62, 0x77, 0xce, 0xcf, 0xcd, 0x4d, 0xcc, 0x4b, 0x91, 0x60, 0x54, 0x60, 0xd4,
-	0x60, 0x0d, 0x82, 0x71, 0x85, 0xe4, 0xb8, 0xb8, 0x82, 0x4b, 0x93, 0x60, 0x92, 0x4c, 0x60, 0x49,
-	0x24, 0x11, 0x21, 0x21, 0x2e, 0x16, 0xe7, 0xfc, 0x94, 0x54, 0x09, 0x66, 0xb0, 0x0c, 0x98, 0x0d,
-	0x32, 0xcd, 0x37, 0xb5, 0xb8, 0x38, 0x31, 0x3d, 0x55, 0x82, 0x45, 0x81, 0x51, 0x83, 0x33, 0x08,
-	0xc6, 0x4d, 0x62, 0x03, 0xdb, 0x6c, 0x0c, 0x08, 0x00, 0x00, 0xff, 0xff, 0xa2, 0xef, 0x02, 0xcd,
-	0x86, 0x00, 0x00, 0x00,
+	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2a, 0x48, 0x52, 0x9a, 0xcb, 0xc8, 0xc5, 0xec, 0x94,
+	0x98, 0x22, 0x24, 0xc1, 0xc5, 0xee, 0x9c, 0x9f, 0x9b, 0x9b, 0x98, 0x97, 0x22, 0xc1, 0xa8, 0xc0,
+	0xa8, 0xc1, 0x1a, 0x04, 0xe3, 0x0a, 0xc9, 0x71, 0x71, 0x05, 0x97, 0x26, 0xc1, 0x24, 0x99, 0xc0,
+	0x92, 0x48, 0x22, 0x42, 0x42, 0x5c, 0x2c, 0xce, 0xf9, 0x29, 0xa9, 0x12, 0xcc, 0x60, 0x19, 0x30,
+	0x1b, 0x64, 0x9a, 0x6f, 0x6a, 0x71, 0x71, 0x62, 0x7a, 0xaa, 0x04, 0x8b, 0x02, 0xa3, 0x06, 0x67,
+	0x10, 0x8c, 0x2b, 0x24, 0xc0, 0xc5, 0xec, 0x9d, 0x5a, 0x29, 0xc1, 0x0a, 0x16, 0x05, 0x31, 0x85,
+	0x64, 0xb8, 0x38, 0x83, 0x52, 0x4b, 0x8a, 0x2a, 0x13, 0x93, 0x72, 0x52, 0x25, 0xd8, 0x14, 0x18,
+	0x35, 0x38, 0x82, 0x10, 0x02, 0x49, 0x6c, 0x60, 0xa7, 0x1a, 0x03, 0x06, 0x00, 0xb6, 0x70, 0x85,
+	0xb7, 0xb7, 0x00, 0x00, 0x00,
 }
@@ -21,4 +21,7 @@ const (
 
 	// HTTP http
 	HTTP
+
+	// KCP kcp udp 协议
+	KCP
 )
@@ -5,6 +5,10 @@ package proto
 
 import "errors"
 
+// bytesHeaderSize 请求与响应的头部长度
+// Ver(int8) + SeqID(uint32) + Cmd(int16) + SubCmd(int16)
+const bytesHeaderSize = 9
+
 // RequestBytes 数据流解析
 type RequestBytes struct {
 	Ver     int8
@@ -41,21 +45,27 @@ func (req *RequestBytes) Body() []byte {
 
 // Marshal 封包
 func (req *RequestBytes) Marshal() ([]byte, error) {
-	if !req.IsValid() {
-		return nil, errors.New("Unexpected data")
-	}
-
-	var b BytesBuffer
-	b.WriteInt8(req.Ver)
-	b.WriteUint32(req.SeqID)
-	b.WriteInt16(req.Cmd.Int16())
-	b.WriteInt16(req.SubCmd.Int16())
-	if err := b.WriteBytes(req.Content...); err != nil {
+	b := NewBytesBuffer(make([]byte, 0, bytesHeaderSize+len(req.Content)))
+	if err := req.MarshalTo(b); err != nil {
 		return nil, err
 	}
+
 	return b.Data(), nil
 }
 
+// MarshalTo 封包并追加到w, 配合AcquireBytesBuffer可以避免分配新的内存
+func (req *RequestBytes) MarshalTo(w *BytesBuffer) error {
+	if !req.IsValid() {
+		return errors.New("Unexpected data")
+	}
+
+	w.WriteInt8(req.Ver)
+	w.WriteUint32(req.SeqID)
+	w.WriteInt16(req.Cmd.Int16())
+	w.WriteInt16(req.SubCmd.Int16())
+	return w.WriteBytes(req.Content...)
+}
+
 // IsValid 检查数据是否合法
 func (req *RequestBytes) IsValid() bool {
 	if req.SeqID < 1 {
@@ -103,3 +113,20 @@ func (req *RequestBytes) Unmarshal(frame []byte) error {
 	req.Content = rd.Bytes()
 	return nil
 }
+
+// Decompress 解压带有VerCompressed标记的Content
+// 解压后的数据写入w, Content引用w的数据, Ver去掉压缩标记
+func (req *RequestBytes) Decompress(w *BytesBuffer, compressor Compressor) error {
+	if req.Ver&VerCompressed == 0 {
+		return nil
+	}
+
+	offset := w.Len()
+	if err := compressor.Decompress(w, req.Content); err != nil {
+		return err
+	}
+
+	req.Ver &^= VerCompressed
+	req.Content = w.Data()[offset:]
+	return nil
+}
@@ -1,6 +1,7 @@
 package proto
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -31,3 +32,36 @@ func TestRequestBytes(t *testing.T) {
 		return
 	}
 }
+
+func TestResponseBytesError(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected *Error
+	}{
+		{NewError(CodeCustom+1, "ErrNotEnoughGold").WithKey("error.gold").WithRetryable(), &Error{Code: CodeCustom + 1, Key: "error.gold", Message: "ErrNotEnoughGold", Retryable: true}},
+		{ErrInvalidCommand, &Error{Code: CodeInvalidCommand, Message: "ErrInvalidCommand"}},
+		{errors.New("1001"), &Error{Code: 1001, Message: "1001"}},
+		{errors.New("failed"), &Error{Code: CodeUnknown, Message: "ErrInternal"}},
+	}
+
+	for _, c := range cases {
+		resp := &ResponseBytes{Ver: 1, Cmd: 10001, SubCmd: 12, SeqID: 154, Err: c.err}
+		b, err := resp.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rd := &ResponseBytes{}
+		if err := rd.Unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+
+		if !rd.IsError() || !reflect.DeepEqual(rd.Err, c.expected) {
+			t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", rd.Err, c.expected)
+		}
+
+		if !errors.Is(rd.Err, c.expected) {
+			t.Fatalf("errors.Is(%v, %v) = false", rd.Err, c.expected)
+		}
+	}
+}
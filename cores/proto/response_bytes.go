@@ -5,8 +5,6 @@ package proto
 
 import (
 	"errors"
-	"fmt"
-	"strconv"
 
 	"github.com/doublemo/balala/cores/proto/pb"
 	"github.com/golang/protobuf/proto"
@@ -49,39 +47,33 @@ func (resp *ResponseBytes) Body() []byte {
 
 // Marshal 封包
 func (resp *ResponseBytes) Marshal() ([]byte, error) {
+	b := NewBytesBuffer(make([]byte, 0, bytesHeaderSize+len(resp.Content)))
+	if err := resp.MarshalTo(b); err != nil {
+		return nil, err
+	}
+
+	return b.Data(), nil
+}
+
+// MarshalTo 封包并追加到w, 配合AcquireBytesBuffer可以避免分配新的内存
+func (resp *ResponseBytes) MarshalTo(w *BytesBuffer) error {
 	if resp.IsError() {
 		if resp.SubCmd != InternalBad {
-			bad := &pb.Bad{
-				Command:    int32(resp.Cmd),
-				SubCommand: int32(resp.SubCmd),
-				Code:       0,
-				Message:    "",
-			}
-
-			if m, err := strconv.ParseInt(resp.Err.Error(), 10, 64); err == nil {
-				bad.Code = int32(m)
-			}
-
-			bad.Message = resp.Err.Error()
+			bad := FromError(resp.Err).Bad(resp.Cmd, resp.SubCmd)
 			resp.SubCmd = InternalBad
 			resp.Content, _ = proto.Marshal(bad)
 		}
 	}
 
 	if !resp.IsValid() {
-		return nil, errors.New("Unexpected data")
+		return errors.New("Unexpected data")
 	}
 
-	var b BytesBuffer
-	b.WriteInt8(resp.Ver)
-	b.WriteUint32(resp.SeqID)
-	b.WriteInt16(resp.Cmd.Int16())
-	b.WriteInt16(resp.SubCmd.Int16())
-	if err := b.WriteBytes(resp.Content...); err != nil {
-		return nil, err
-	}
-
-	return b.Data(), nil
+	w.WriteInt8(resp.Ver)
+	w.WriteUint32(resp.SeqID)
+	w.WriteInt16(resp.Cmd.Int16())
+	w.WriteInt16(resp.SubCmd.Int16())
+	return w.WriteBytes(resp.Content...)
 }
 
 // IsValid 检查数据是否合法
@@ -131,10 +123,11 @@ func (resp *ResponseBytes) Unmarshal(frame []byte) error {
 	resp.Content = rd.Bytes()
 
 	if resp.SubCmd == InternalBad {
-		resp.Err = fmt.Errorf("%d", InternalBad)
 		bad := &pb.Bad{}
-		if err := proto.Unmarshal(resp.Content, bad); err == nil {
-			resp.Err = fmt.Errorf("%d", bad.Code)
+		if err := proto.Unmarshal(resp.Content, bad); err != nil {
+			resp.Err = NewError(int32(InternalBad), "")
+		} else {
+			resp.Err = NewErrorFromBad(bad)
 		}
 	}
 
@@ -41,6 +41,22 @@ func (caches *Caches) Store(o *Options) {
 	caches.records[o.ID] = append(caches.records[o.ID], o)
 }
 
+// Replace 替换所有服务信息
+func (caches *Caches) Replace(opts ...*Options) {
+	records := make(map[int32][]*Options)
+	for _, o := range opts {
+		if o.ID < 1 {
+			continue
+		}
+
+		records[o.ID] = append(records[o.ID], o)
+	}
+
+	caches.mutex.Lock()
+	defer caches.mutex.Unlock()
+	caches.records = records
+}
+
 // StoreFromString 存储来Options对象
 func (caches *Caches) StoreFromString(s string) (*Options, error) {
 	o := Options{}
@@ -52,6 +68,16 @@ func (caches *Caches) StoreFromString(s string) (*Options, error) {
 	return &o, nil
 }
 
+// Get 指定服务的所有实例
+func (caches *Caches) Get(id int32) []*Options {
+	caches.mutex.RLock()
+	defer caches.mutex.RUnlock()
+
+	m := make([]*Options, len(caches.records[id]))
+	copy(m, caches.records[id])
+	return m
+}
+
 // RndOnce 随机一个
 func (caches *Caches) RndOnce(id int32) (*Options, bool) {
 	caches.mutex.RLock()
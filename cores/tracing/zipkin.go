@@ -0,0 +1,105 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+// Package tracing 请求追踪
+package tracing
+
+import (
+	"sync"
+	"sync/atomic"
+
+	stdopentracing "github.com/opentracing/opentracing-go"
+	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+// Zipkin 上报地址可以在运行时更换的zipkin追踪
+// 中间件持有的追踪实例不变, 更换地址时只替换上报器. 上报地址为空时不记录追踪
+type Zipkin struct {
+	tracer      *stdzipkin.Tracer
+	openTracer  stdopentracing.Tracer
+	reporter    atomic.Value
+	reporterURL string
+	mutex       sync.Mutex
+}
+
+// reporterValue atomic.Value 要求每次保存的类型相同
+type reporterValue struct {
+	zipkinreporter.Reporter
+}
+
+// swapReporter 转发到Zipkin当前的上报器
+type swapReporter struct {
+	z *Zipkin
+}
+
+func (r swapReporter) Send(span model.SpanModel) {
+	r.z.reporter.Load().(reporterValue).Send(span)
+}
+
+func (r swapReporter) Close() error {
+	return nil
+}
+
+// Tracer 返回zipkin追踪实例
+func (z *Zipkin) Tracer() *stdzipkin.Tracer {
+	return z.tracer
+}
+
+// OpenTracer 返回opentracing追踪实例
+func (z *Zipkin) OpenTracer() stdopentracing.Tracer {
+	return z.openTracer
+}
+
+// SetReporterURL 更换上报地址, 旧的上报器会被关闭
+func (z *Zipkin) SetReporterURL(url string) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	if url == z.reporterURL {
+		return
+	}
+
+	reporter := zipkinreporter.NewNoopReporter()
+	if url != "" {
+		// some http://192.168.31.20:9411/api/v2/spans
+		reporter = zipkinhttp.NewReporter(url)
+	}
+
+	old := z.reporter.Load().(reporterValue)
+	z.reporter.Store(reporterValue{reporter})
+	z.tracer.SetNoop(url == "")
+	z.reporterURL = url
+	old.Close()
+}
+
+// Close 关闭当前的上报器
+func (z *Zipkin) Close() error {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	old := z.reporter.Load().(reporterValue)
+	z.reporter.Store(reporterValue{zipkinreporter.NewNoopReporter()})
+	z.tracer.SetNoop(true)
+	z.reporterURL = ""
+	return old.Close()
+}
+
+// NewZipkin 创建追踪, url为空时不记录追踪
+func NewZipkin(serviceName, url string) (*Zipkin, error) {
+	z := &Zipkin{}
+	z.reporter.Store(reporterValue{zipkinreporter.NewNoopReporter()})
+
+	zEP, _ := stdzipkin.NewEndpoint(serviceName, "")
+	tracer, err := stdzipkin.NewTracer(swapReporter{z}, stdzipkin.WithLocalEndpoint(zEP), stdzipkin.WithNoopTracer(true))
+	if err != nil {
+		return nil, err
+	}
+
+	z.tracer = tracer
+	z.openTracer = zipkinot.Wrap(tracer)
+	z.SetReporterURL(url)
+	return z, nil
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package utils
+
+import "reflect"
+
+// DiffFields 比较两个相同类型结构体(或其指针)的字段
+// 返回值changed为所有值不同的字段名, restart为其中不在live中的字段名.
+// 一方为nil指针时视为所有字段需要重启, 返回的字段名为"*"
+func DiffFields(old, new interface{}, live ...string) (changed []string, restart []string) {
+	a, b := reflect.ValueOf(old), reflect.ValueOf(new)
+	if a.Kind() == reflect.Ptr || b.Kind() == reflect.Ptr {
+		if a.IsNil() && b.IsNil() {
+			return
+		}
+
+		if a.IsNil() || b.IsNil() {
+			return []string{"*"}, []string{"*"}
+		}
+
+		a, b = a.Elem(), b.Elem()
+	}
+
+	lives := make(map[string]bool)
+	for _, name := range live {
+		lives[name] = true
+	}
+
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			continue
+		}
+
+		name := t.Field(i).Name
+		changed = append(changed, name)
+		if !lives[name] {
+			restart = append(restart, name)
+		}
+	}
+
+	return
+}
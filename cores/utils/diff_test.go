@@ -0,0 +1,34 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	type options struct {
+		Addr     string
+		RPMLimit int
+		Tags     []string
+	}
+
+	cases := []struct {
+		old, new         *options
+		changed, restart []string
+	}{
+		{nil, nil, nil, nil},
+		{nil, &options{}, []string{"*"}, []string{"*"}},
+		{&options{Addr: ":1"}, &options{Addr: ":1"}, nil, nil},
+		{&options{RPMLimit: 1}, &options{RPMLimit: 2}, []string{"RPMLimit"}, nil},
+		{&options{Addr: ":1", Tags: []string{"a"}}, &options{Addr: ":2", Tags: []string{"b"}}, []string{"Addr", "Tags"}, []string{"Addr", "Tags"}},
+	}
+
+	for i, c := range cases {
+		changed, restart := DiffFields(c.old, c.new, "RPMLimit")
+		if !reflect.DeepEqual(changed, c.changed) || !reflect.DeepEqual(restart, c.restart) {
+			t.Fatalf("case %d: unexpected diff %v, %v", i, changed, restart)
+		}
+	}
+}
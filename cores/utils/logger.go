@@ -0,0 +1,18 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package utils
+
+import (
+	"github.com/go-kit/kit/log"
+	kitlog "github.com/go-kit/kit/log/level"
+)
+
+// LevelLogger 根据运行模式过滤日志级别
+// dev 模式输出所有日志, 其它模式只输出错误与警告
+func LevelLogger(logger log.Logger, runmode string) log.Logger {
+	if runmode == "dev" {
+		return kitlog.NewFilter(logger, kitlog.AllowAll())
+	}
+
+	return kitlog.NewFilter(logger, kitlog.AllowError(), kitlog.AllowWarn())
+}
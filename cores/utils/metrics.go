@@ -0,0 +1,39 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package utils
+
+import (
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// SummaryFrom 与prometheus.NewSummaryFrom相同, 指标已经注册时使用已注册的指标
+// 服务重启时会按相同的参数再次创建指标
+func SummaryFrom(opts stdprometheus.SummaryOpts, labelNames []string) *prometheus.Summary {
+	sv := stdprometheus.NewSummaryVec(opts, labelNames)
+	if err := stdprometheus.Register(sv); err != nil {
+		are, ok := err.(stdprometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+
+		sv = are.ExistingCollector.(*stdprometheus.SummaryVec)
+	}
+
+	return prometheus.NewSummary(sv)
+}
+
+// GaugeFrom 与prometheus.NewGaugeFrom相同, 指标已经注册时使用已注册的指标
+func GaugeFrom(opts stdprometheus.GaugeOpts, labelNames []string) *prometheus.Gauge {
+	gv := stdprometheus.NewGaugeVec(opts, labelNames)
+	if err := stdprometheus.Register(gv); err != nil {
+		are, ok := err.(stdprometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+
+		gv = are.ExistingCollector.(*stdprometheus.GaugeVec)
+	}
+
+	return prometheus.NewGauge(gv)
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package utils
+
+import (
+	"testing"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsRegisterTwice(t *testing.T) {
+	opts := stdprometheus.GaugeOpts{Namespace: "test", Subsystem: "utils", Name: "register_twice"}
+	GaugeFrom(opts, []string{"method"}).With("method", "a").Set(1)
+	GaugeFrom(opts, []string{"method"}).With("method", "a").Add(1)
+
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == "test_utils_register_twice" {
+			if v := family.GetMetric()[0].GetGauge().GetValue(); v != 2 {
+				t.Fatalf("gauge = %v, want 2", v)
+			}
+			return
+		}
+	}
+
+	t.Fatal("gauge not registered")
+}
@@ -8,6 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/doublemo/balala/cores/process"
@@ -48,18 +51,41 @@ type DNS struct {
 	// servicesCaches 集群服务信息缓存
 	servicesCaches map[int32]string
 
+	// actors 服务名称对应的协程ID, 用于重新加载配置时重启服务
+	actors map[string]int32
+
+	// readyedOnce 服务注册可能因为重新加载配置而重启
+	readyedOnce sync.Once
+
+	// reloadMutex 重新加载配置
+	reloadMutex sync.Mutex
+
+	// logLevel 日志级别过滤, 重新加载配置时切换
+	logLevel *log.SwapLogger
+
+	// rawLogger 未过滤级别的日志
+	rawLogger log.Logger
+
 	// logger
 	logger log.Logger
 }
 
+// runtimeActors 服务注册顺序就是服务的启动顺序
+// 关闭服务时会反顺关闭
+var runtimeActors = []string{"grpc", "socket", "http", "websocket", "services"}
+
 // Start 启动服务
 func (s *DNS) Start() {
 	defer func() {
 		close(s.exitChan)
 	}()
 
+	s.reloadMutex.Lock()
+
 	// 读取一个配置文件副本
+	// 保存副本后再读取配置不需要重新查询本机IP
 	opts := s.configureOptions.Read()
+	s.configureOptions.Reset(opts)
 
 	// gin web framework
 	gin.SetMode(gin.ReleaseMode)
@@ -74,22 +100,11 @@ func (s *DNS) Start() {
 	utils.Assert(s.makeEtcdv3Client())
 
 	// 开始注册服务
-	// 注意服务注册顺序就是服务的启动顺序
-	// 关闭服务时会反顺关闭
-	// internal grpc
-	s.process.Add(s.mustRuntimeActor(makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger)), true)
-
-	// socket
-	s.process.Add(makeSocketRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
-
-	// http
-	s.process.Add(makeHTTPRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
-
-	// websocket
-	s.process.Add(makeWebsocketRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), true)
+	for _, name := range runtimeActors {
+		s.actors[name] = s.mustRuntimeActor(name)
+	}
 
-	// 创建服务
-	s.process.Add(s.mustRuntimeActor(s.makeServices()), true)
+	s.reloadMutex.Unlock()
 	s.process.Run()
 }
 
@@ -105,8 +120,104 @@ func (s *DNS) Shutdown() {
 	s.process.Stop()
 }
 
-// Reload 重新加载服务
-func (s *DNS) Reload() {}
+// Reload 重新加载配置文件
+// 可以在线修改的参数立即生效, 监听参数改变时只重启对应的服务.
+// 配置文件错误时保留原来的配置
+func (s *DNS) Reload() {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	// 服务还没有启动
+	if len(s.actors) < 1 {
+		return
+	}
+
+	old := s.configureOptions.Read()
+	if err := s.configureOptions.Load(); err != nil {
+		kitlog.Error(s.logger).Log("reload", "failed", "error", err)
+		return
+	}
+
+	// 以下参数需要重启进程才能生效, 保持原来的值
+	opts := s.configureOptions.Read()
+	var ignored []string
+	if opts.ID != old.ID || opts.LocalIP != old.LocalIP || opts.Domain != old.Domain {
+		opts.ID, opts.LocalIP, opts.Domain = old.ID, old.LocalIP, old.Domain
+		ignored = append(ignored, "id/localip/domain")
+	}
+
+	if !reflect.DeepEqual(opts.ETCD, old.ETCD) {
+		opts.ETCD = old.ETCD
+		ignored = append(ignored, "etcd")
+	}
+
+	if opts.ServiceSecurityKey != old.ServiceSecurityKey {
+		opts.ServiceSecurityKey = old.ServiceSecurityKey
+		ignored = append(ignored, "servicesecuritykey")
+	}
+
+	s.configureOptions.Reset(opts)
+
+	var (
+		changed  []string
+		restarts []string
+	)
+
+	if opts.Runmode != old.Runmode {
+		s.logLevel.Swap(utils.LevelLogger(s.rawLogger, opts.Runmode))
+		changed = append(changed, "runmode")
+	}
+
+	sections := []struct {
+		name     string
+		old, new interface{}
+		live     []string
+	}{
+		{"grpc", old.GRPC, opts.GRPC, nil},
+		{"grpc", old.Tracer, opts.Tracer, nil},
+		{"socket", old.Socket, opts.Socket, []string{"ReadDeadline", "WriteDeadline", "RPMLimit"}},
+		{"http", old.HTTP, opts.HTTP, nil},
+		{"websocket", old.WebSocket, opts.WebSocket, []string{"MaxMessageSize", "ReadDeadline", "WriteDeadline", "RPMLimit"}},
+	}
+
+	restart := make(map[string]bool)
+	for _, section := range sections {
+		fields, fieldsRestart := utils.DiffFields(section.old, section.new, section.live...)
+		for _, field := range fields {
+			changed = append(changed, section.name+"."+field)
+		}
+
+		if len(fieldsRestart) > 0 {
+			restart[section.name] = true
+		}
+	}
+
+	// 监听地址与优先级需要重新注册服务
+	if opts.Priority != old.Priority {
+		s.serviceOpts.Priority = opts.Priority
+		changed = append(changed, "priority")
+		restart["services"] = true
+	}
+
+	if len(restart) > 0 {
+		restart["services"] = true
+	}
+
+	for _, name := range runtimeActors {
+		if !restart[name] {
+			continue
+		}
+
+		if err := s.restartRuntimeActor(name); err != nil {
+			kitlog.Error(s.logger).Log("reload", "restart", "actor", name, "error", err)
+			continue
+		}
+
+		restarts = append(restarts, name)
+	}
+
+	s.logger.Log("reload", "ok", "changed", strings.Join(changed, ","), "restarted", strings.Join(restarts, ","), "ignored", strings.Join(ignored, ","))
+}
 
 // ServiceName 返回唯一服务名称
 func (s *DNS) ServiceName() string {
@@ -195,7 +306,9 @@ func (s *DNS) makeServices() (*process.RuntimeActor, error) {
 	return &process.RuntimeActor{
 		Exec: func() error {
 			registrar.Register()
-			close(s.readyedChan)
+			s.readyedOnce.Do(func() {
+				close(s.readyedChan)
+			})
 			ch := make(chan struct{})
 			go s.etcdV3Client.WatchPrefix(opts.ETCD.Frefix, ch)
 			for {
@@ -229,26 +342,66 @@ func (s *DNS) makeServices() (*process.RuntimeActor, error) {
 	}, nil
 }
 
-func (s *DNS) mustRuntimeActor(actor *process.RuntimeActor, err error) *process.RuntimeActor {
+// makeRuntimeActor 根据当前配置创建服务
+func (s *DNS) makeRuntimeActor(name string) (*process.RuntimeActor, error) {
+	switch name {
+	case "grpc":
+		return makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger)
+
+	case "socket":
+		return makeSocketRuntimeActor(s.serviceOpts, s.configureOptions, s.sessionStore, s.logger), nil
+
+	case "http":
+		return makeHTTPRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.logger), nil
+
+	case "websocket":
+		return makeWebsocketRuntimeActor(s.serviceOpts, s.configureOptions, s.sessionStore, s.logger), nil
+
+	case "services":
+		return s.makeServices()
+	}
+
+	return nil, nil
+}
+
+// restartRuntimeActor 关闭服务并按当前配置重新创建
+func (s *DNS) restartRuntimeActor(name string) error {
+	id, err := s.process.Replace(s.actors[name], s.runtimeActorFunc(name))
+
+	s.actors[name] = id
+	return err
+}
+
+// runtimeActorFunc 服务的创建函数, 服务重启时按当前配置重新创建
+// 同时删除注册信息中原来的监听地址
+func (s *DNS) runtimeActorFunc(name string) func() (*process.RuntimeActor, error) {
+	return func() (*process.RuntimeActor, error) {
+		delete(s.serviceOpts.Params, name)
+		return s.makeRuntimeActor(name)
+	}
+}
+
+// mustRuntimeActor 注册服务, 创建失败时退出
+func (s *DNS) mustRuntimeActor(name string) int32 {
+	id, err := s.process.AddFunc(s.runtimeActorFunc(name))
 	if err != nil {
 		kitlog.Error(s.logger).Log("error", err)
 		panic(err)
 	}
 
-	return actor
+	return id
 }
 
 // New 创建网关服务
 func New(serviceOpts *services.Options, opts *ConfigureOptions) *DNS {
-	logger := log.NewLogfmtLogger(os.Stderr)
-	logger = log.WithPrefix(logger, "o", "dns")
-	if opts.Read().Runmode == "dev" {
-		logger = kitlog.NewFilter(logger, kitlog.AllowAll())
-	} else {
-		logger = kitlog.NewFilter(logger, kitlog.AllowError(), kitlog.AllowWarn())
-	}
+	rawLogger := log.NewLogfmtLogger(os.Stderr)
+	rawLogger = log.WithPrefix(rawLogger, "o", "dns")
+
+	var logLevel log.SwapLogger
+	logLevel.Swap(utils.LevelLogger(rawLogger, opts.Read().Runmode))
 
-	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	var logger log.Logger
+	logger = log.With(&logLevel, "ts", log.DefaultTimestampUTC)
 	logger = log.With(logger, "caller", log.DefaultCaller)
 
 	return &DNS{
@@ -257,6 +410,9 @@ func New(serviceOpts *services.Options, opts *ConfigureOptions) *DNS {
 		configureOptions: opts,
 		process:          process.NewRuntimeContainer(),
 		sessionStore:     session.NewStore(logger),
+		actors:           make(map[string]int32),
+		logLevel:         &logLevel,
+		rawLogger:        rawLogger,
 		logger:           logger,
 		serviceOpts:      serviceOpts,
 	}
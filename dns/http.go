@@ -67,6 +67,8 @@ func makeHTTPRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 			logger.Log("transport", "http", "on", "shutdown")
 			s.Shutdown(context.Background())
 		},
+
+		Restart: process.RestartOnFailure,
 	}
 }
 
@@ -18,8 +18,8 @@ import (
 	kitlog "github.com/go-kit/kit/log/level"
 )
 
-func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, logger log.Logger) *process.RuntimeActor {
-	socketOpts := opts.Socket
+func makeSocketRuntimeActor(serviceOpts *services.Options, conf *ConfigureOptions, store *session.Store, logger log.Logger) *process.RuntimeActor {
+	socketOpts := conf.Read().Socket
 	if socketOpts == nil {
 		return nil
 	}
@@ -28,12 +28,18 @@ func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store
 	var socket networks.Socket
 	{
 		socket.CallBack(func(conn net.Conn, exit chan struct{}) {
-			sess := store.NewClient(conn, "", time.Duration(socketOpts.ReadDeadline)*time.Second, time.Duration(socketOpts.WriteDeadline)*time.Second, 0)
+			// 超时与rpm限制使用最新的配置
+			o := socketOpts
+			if m := conf.Read().Socket; m != nil {
+				o = m
+			}
+
+			sess := store.NewClient(conn, "", time.Duration(o.ReadDeadline)*time.Second, time.Duration(o.WriteDeadline)*time.Second, 0)
 			defer func() {
 				store.RemoveAndExit(sess.ID())
 			}()
 
-			socketLoop(sess, exit, socketOpts.RPMLimit, logger)
+			socketLoop(sess, exit, o.RPMLimit, logger)
 		})
 	}
 
@@ -52,6 +58,8 @@ func makeSocketRuntimeActor(serviceOpts *services.Options, opts *Options, store
 			logger.Log("transport", "socket", "on", "shutdown")
 			socket.Shutdown()
 		},
+
+		Restart: process.RestartOnFailure,
 	}
 }
 
@@ -17,8 +17,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, logger log.Logger) *process.RuntimeActor {
-	websocketOpts := opts.WebSocket
+func makeWebsocketRuntimeActor(serviceOpts *services.Options, conf *ConfigureOptions, store *session.Store, logger log.Logger) *process.RuntimeActor {
+	websocketOpts := conf.Read().WebSocket
 	if websocketOpts == nil {
 		return nil
 	}
@@ -46,7 +46,13 @@ func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, sto
 			return
 		}
 
-		webscoketHandler(ctx.Writer, ctx.Request, webSocketUpgrader, store, websocketOpts, logger)
+		// 超时, 帧大小与rpm限制使用最新的配置
+		o := websocketOpts
+		if m := conf.Read().WebSocket; m != nil {
+			o = m
+		}
+
+		webscoketHandler(ctx.Writer, ctx.Request, webSocketUpgrader, store, o, logger)
 	})
 
 	// http server
@@ -77,6 +83,8 @@ func makeWebsocketRuntimeActor(serviceOpts *services.Options, opts *Options, sto
 			logger.Log("transport", "websocket", "on", "shutdown")
 			s.Shutdown(context.Background())
 		},
+
+		Restart: process.RestartOnFailure,
 	}
 }
 
@@ -203,6 +203,8 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *s
 
 			lis.Close()
 		},
+
+		Restart: process.RestartOnFailure,
 	}, nil
 }
 
@@ -3,34 +3,380 @@
 package sss
 
 import (
-	"github.com/doublemo/balala/cores/services"
-	"github.com/go-kit/kit/endpoint"
+	"sync"
+	"time"
+
+	"github.com/doublemo/balala/internal/serviceid"
+	"github.com/doublemo/balala/sss/proto/pb"
+	"github.com/doublemo/balala/sss/service"
+	"github.com/doublemo/balala/sss/session"
+	"github.com/doublemo/balala/sss/transport"
+	"github.com/go-kit/kit/log"
+	kitlog "github.com/go-kit/kit/log/level"
 	"google.golang.org/grpc"
 )
 
+const (
+	// clusterSyncInterval 定时全量同步, 修复丢失或者乱序到达的修改
+	clusterSyncInterval = 5 * time.Minute
+
+	// clusterTombstoneTTL 删除记录保留时间, 必须大于全量同步的间隔
+	clusterTombstoneTTL = 2 * clusterSyncInterval
+
+	// clusterQueueSize 等待发送给其它节点的修改数量
+	clusterQueueSize = 4096
+
+	// clusterExpireInterval 检查过期连接的间隔
+	clusterExpireInterval = time.Second
+
+	// clusterExpireGrace 不是连接所有者的节点在连接过期后等待的时间, 必须大于全量同步的间隔
+	clusterExpireGrace = clusterSyncInterval + time.Minute
+
+	// clusterReleaseGrace 网关注册信息删除超过该时间才删除网关的所有连接
+	// etcd短暂断开或者网关重新加载配置时会重新注册
+	clusterReleaseGrace = time.Minute
+)
+
 // cluster 集群处理
+// 通过etcd发现其它sss节点, 与每个节点建立同步流. 连接建立时先发送全量状态, 之后发送本地的修改,
+// 其它节点按时间戳后写优先合并, 任何一个sss节点都可以响应所有连接的状态.
+// 同时负责删除过期的连接, 以及注册信息删除超过clusterReleaseGrace的网关的所有连接
 type cluster struct {
-	endpoints     map[string]endpoint.Endpoint
-	endpointChan  chan *services.Options
-	broadcastChan chan string
+	// self 当前节点地址
+	self string
+
+	// server 本地服务, 删除过期的连接时通知订阅者
+	server *baseGRPCServer
+
+	// store 本地连接状态
+	store *session.Store
+
+	// agents 已注册的网关, 网关注册信息中的MachineID
+	agents map[string]bool
+
+	// missing 注册信息已经删除的网关与发现删除的时间
+	missing map[string]time.Time
+
+	// jwtToken 节点之间通信认证
+	jwtToken []byte
+
+	// peers 其它sss节点
+	peers map[string]*peer
+
+	// endpointChan 集群服务信息发生变化
+	endpointChan chan struct{}
+
+	// broadcastChan 需要同步到其它节点的修改
+	broadcastChan chan *pb.SessionStateServerAPI_Mutation
+
+	// resyncChan broadcastChan已满丢弃了修改, 所有节点需要重新全量同步
+	resyncChan chan struct{}
+
+	exitChan  chan struct{}
+	closeOnce sync.Once
+	logger    log.Logger
 }
 
 func (cluster *cluster) serve() {
+	ticker := time.NewTicker(time.Minute)
+	expireTicker := time.NewTicker(clusterExpireInterval)
+	defer func() {
+		ticker.Stop()
+		expireTicker.Stop()
+		for addr, p := range cluster.peers {
+			close(p.exitChan)
+			delete(cluster.peers, addr)
+		}
+	}()
+
+	cluster.reset()
 	for {
 		select {
-		case o, ok := <-cluster.endpointChan:
-			if !ok {
-				return
+		case <-cluster.endpointChan:
+			cluster.reset()
+
+		case m := <-cluster.broadcastChan:
+			for _, p := range cluster.peers {
+				p.send(m)
+			}
+
+		case <-cluster.resyncChan:
+			for _, p := range cluster.peers {
+				p.markResync()
 			}
 
-			cluster.newEndpoint(o)
+		case <-ticker.C:
+			cluster.store.Compact(cluster.store.Now() - int64(clusterTombstoneTTL))
+			cluster.reset()
+
+		case <-expireTicker.C:
+			cluster.server.expire()
 
-		case <-cluster.broadcastChan:
+		case <-cluster.exitChan:
+			return
 		}
 	}
 }
 
-func (cluster *cluster) newEndpoint(o *services.Options) error {
-	conn, err := grpc.Dial(instance, grpc.WithInsecure())
+// reset 按服务信息缓存连接新的节点, 关闭已经下线的节点
+func (cluster *cluster) reset() {
+	addrs := make(map[string]bool)
+	for _, o := range service.Caches.Get(serviceid.SessionStateID) {
+		if o.Port == "" {
+			continue
+		}
+
+		addr := o.IP + ":" + o.Port
+		if addr == cluster.self {
+			continue
+		}
+
+		addrs[addr] = true
+		if _, ok := cluster.peers[addr]; ok {
+			continue
+		}
+
+		p := newPeer(addr)
+		cluster.peers[addr] = p
+		go p.serve(cluster)
+		kitlog.Debug(cluster.logger).Log("cluster", "join", "peer", addr)
+	}
+
+	for addr, p := range cluster.peers {
+		if addrs[addr] {
+			continue
+		}
+
+		close(p.exitChan)
+		delete(cluster.peers, addr)
+		kitlog.Debug(cluster.logger).Log("cluster", "leave", "peer", addr)
+	}
+
+	// 网关的注册信息删除超过clusterReleaseGrace, 删除该网关的所有连接
+	agents := make(map[string]bool)
+	for _, o := range service.Caches.Get(serviceid.AgentID) {
+		if o.MachineID != "" {
+			agents[o.MachineID] = true
+		}
+	}
+
+	now := time.Now()
+	for id := range cluster.agents {
+		if _, ok := cluster.missing[id]; !agents[id] && !ok {
+			cluster.missing[id] = now
+		}
+	}
+
+	for id, at := range cluster.missing {
+		switch {
+		case agents[id]:
+			delete(cluster.missing, id)
+
+		case now.Sub(at) >= clusterReleaseGrace:
+			delete(cluster.missing, id)
+			go cluster.server.release(id)
+		}
+	}
+
+	cluster.agents = agents
+}
+
+// replicate 同步本地的修改到其它节点, 不会阻塞
+// 队列已满时丢弃, 所有节点重新全量同步. 全量状态不包含广播的事件, 这部分事件会丢失
+func (cluster *cluster) replicate(m *pb.SessionStateServerAPI_Mutation) {
+	if cluster == nil {
+		return
+	}
+
+	select {
+	case cluster.broadcastChan <- m:
+	default:
+		kitlog.Warn(cluster.logger).Log("cluster", "chanfull", "action", m.GetAction())
+		select {
+		case cluster.resyncChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// snapshot 全量状态, 包括删除记录
+func (cluster *cluster) snapshot(send func(*pb.SessionStateServerAPI_Mutation) error) error {
+	var err error
+	cluster.store.Range(func(client *session.Client) bool {
+		info := clientInfo(client)
+		info.Params = pbParams(client.VersionedParams())
+		info.TTL = int32(client.TTL() / time.Second)
+		err = send(&pb.SessionStateServerAPI_Mutation{
+			Action:    pb.EventSessionNew,
+			Session:   info,
+			Timestamp: client.Version(),
+			ExpireAt:  client.ExpireAt(),
+		})
+		return err == nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for id, ts := range cluster.store.Tombstones() {
+		m := &pb.SessionStateServerAPI_Mutation{
+			Action:    pb.EventSessionRemove,
+			Session:   &pb.SessionStateServerAPI_NewRequest{ClientID: id},
+			Timestamp: ts,
+		}
+
+		if err := send(m); err != nil {
+			return err
+		}
+	}
+
+	for remoteServID, expireAt := range cluster.store.Leases() {
+		m := &pb.SessionStateServerAPI_Mutation{
+			Action:   pb.MutationLease,
+			Session:  &pb.SessionStateServerAPI_NewRequest{RemoteServID: remoteServID},
+			ExpireAt: expireAt,
+		}
+
+		if err := send(m); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+func (cluster *cluster) close() {
+	cluster.closeOnce.Do(func() {
+		close(cluster.exitChan)
+	})
+}
+
+func newCluster(self string, server *baseGRPCServer, jwtToken []byte, endpointChan chan struct{}, logger log.Logger) *cluster {
+	return &cluster{
+		self:          self,
+		server:        server,
+		store:         server.sessionStore,
+		jwtToken:      jwtToken,
+		peers:         make(map[string]*peer),
+		missing:       make(map[string]time.Time),
+		endpointChan:  endpointChan,
+		broadcastChan: make(chan *pb.SessionStateServerAPI_Mutation, clusterQueueSize),
+		resyncChan:    make(chan struct{}, 1),
+		exitChan:      make(chan struct{}),
+		logger:        logger,
+	}
+}
+
+// peer 集群中其它sss节点
+type peer struct {
+	addr string
+
+	// sendChan 等待发送的修改
+	sendChan chan *pb.SessionStateServerAPI_Mutation
+
+	// resyncChan 有修改被丢弃, 需要重新全量同步
+	resyncChan chan struct{}
+
+	exitChan chan struct{}
+}
+
+func (p *peer) serve(cluster *cluster) {
+	for {
+		err := p.sync(cluster)
+		select {
+		case <-p.exitChan:
+			return
+		case <-time.After(time.Second):
+		}
+
+		if err != nil {
+			kitlog.Debug(cluster.logger).Log("cluster", "sync", "peer", p.addr, "error", err)
+		}
+	}
+}
+
+// sync 建立同步流, 发送全量状态后持续发送本地的修改
+func (p *peer) sync(cluster *cluster) error {
+	conn, err := grpc.Dial(p.addr, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	stream, cancel, err := transport.NewGRPCReplicate(conn, cluster.jwtToken)
+	if err != nil {
+		return err
+	}
+
+	defer cancel()
+
+	if err := p.resync(cluster, stream); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(clusterSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-p.sendChan:
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			if err := cluster.snapshot(stream.Send); err != nil {
+				return err
+			}
+
+		case <-p.resyncChan:
+			if err := p.resync(cluster, stream); err != nil {
+				return err
+			}
+
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+
+		case <-p.exitChan:
+			_, err := stream.CloseAndRecv()
+			return err
+		}
+	}
+}
+
+// resync 发送全量状态, 全量状态已经包含了等待发送的修改
+func (p *peer) resync(cluster *cluster, stream pb.SessionStateServer_ReplicateClient) error {
+	for n := len(p.sendChan); n > 0; n-- {
+		<-p.sendChan
+	}
+
+	return cluster.snapshot(stream.Send)
+}
+
+// send 不会阻塞, 队列已满时重新全量同步
+func (p *peer) send(m *pb.SessionStateServerAPI_Mutation) {
+	select {
+	case p.sendChan <- m:
+	default:
+		p.markResync()
+	}
+}
+
+// markResync 通知同步流重新全量同步, 不会阻塞
+func (p *peer) markResync() {
+	select {
+	case p.resyncChan <- struct{}{}:
+	default:
+	}
+}
+
+func newPeer(addr string) *peer {
+	return &peer{
+		addr:       addr,
+		sendChan:   make(chan *pb.SessionStateServerAPI_Mutation, clusterQueueSize),
+		resyncChan: make(chan struct{}, 1),
+		exitChan:   make(chan struct{}),
+	}
+}
@@ -40,6 +40,27 @@ type Set struct {
 
 	// ParamsEndpoint 修改连接参数
 	ParamsEndpoint endpoint.Endpoint
+
+	// ReplicateEndpoint 集群节点之间同步连接状态
+	ReplicateEndpoint endpoint.Endpoint
+
+	// GetEndpoint 查询连接信息
+	GetEndpoint endpoint.Endpoint
+
+	// BatchGetEndpoint 批量查询连接信息
+	BatchGetEndpoint endpoint.Endpoint
+
+	// FindByParamEndpoint 按参数查找连接
+	FindByParamEndpoint endpoint.Endpoint
+
+	// ListEndpoint 分页查询所有连接
+	ListEndpoint endpoint.Endpoint
+
+	// TouchEndpoint 心跳, 延长连接的过期时间
+	TouchEndpoint endpoint.Endpoint
+
+	// LeaseEndpoint 服务租约
+	LeaseEndpoint endpoint.Endpoint
 }
 
 // Subscribe 订阅
@@ -91,6 +112,75 @@ func (s Set) Params(ctx context.Context, in *pb.SessionStateServerAPI_NewRequest
 	return resp.(*pb.SessionStateServerAPI_Nil), nil
 }
 
+// Replicate 集群节点之间同步连接状态
+func (s Set) Replicate(ctx context.Context, stream pb.SessionStateServer_ReplicateServer) error {
+	_, err := s.ReplicateEndpoint(ctx, stream)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get 查询连接信息
+func (s Set) Get(ctx context.Context, in *pb.SessionStateServerAPI_GetRequest) (*pb.SessionStateServerAPI_GetResponse, error) {
+	resp, err := s.GetEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_GetResponse), nil
+}
+
+// BatchGet 批量查询连接信息
+func (s Set) BatchGet(ctx context.Context, in *pb.SessionStateServerAPI_BatchGetRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	resp, err := s.BatchGetEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// FindByParam 按参数查找连接
+func (s Set) FindByParam(ctx context.Context, in *pb.SessionStateServerAPI_FindByParamRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	resp, err := s.FindByParamEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// List 分页查询所有连接
+func (s Set) List(ctx context.Context, in *pb.SessionStateServerAPI_ListRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	resp, err := s.ListEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// Touch 心跳, 延长连接的过期时间
+func (s Set) Touch(ctx context.Context, in *pb.SessionStateServerAPI_TouchRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	resp, err := s.TouchEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_Nil), nil
+}
+
+// Lease 服务租约
+func (s Set) Lease(ctx context.Context, in *pb.SessionStateServerAPI_LeaseRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	resp, err := s.LeaseEndpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*pb.SessionStateServerAPI_Nil), nil
+}
+
 // MakeSubscribeEndpoint Subscribe
 func MakeSubscribeEndpoint(s service.GRPC) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -135,6 +225,66 @@ func MakeParamsEndpoint(s service.GRPC) endpoint.Endpoint {
 	}
 }
 
+// MakeReplicateEndpoint Replicate
+func MakeReplicateEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(pb.SessionStateServer_ReplicateServer)
+		err = s.Replicate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+}
+
+// MakeGetEndpoint Get
+func MakeGetEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_GetRequest)
+		return s.Get(ctx, req)
+	}
+}
+
+// MakeBatchGetEndpoint BatchGet
+func MakeBatchGetEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_BatchGetRequest)
+		return s.BatchGet(ctx, req)
+	}
+}
+
+// MakeFindByParamEndpoint FindByParam
+func MakeFindByParamEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_FindByParamRequest)
+		return s.FindByParam(ctx, req)
+	}
+}
+
+// MakeListEndpoint List
+func MakeListEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_ListRequest)
+		return s.List(ctx, req)
+	}
+}
+
+// MakeTouchEndpoint Touch
+func MakeTouchEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_TouchRequest)
+		return s.Touch(ctx, req)
+	}
+}
+
+// MakeLeaseEndpoint Lease
+func MakeLeaseEndpoint(s service.GRPC) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*pb.SessionStateServerAPI_LeaseRequest)
+		return s.Lease(ctx, req)
+	}
+}
+
 // NewSet 创建内部通信节点
 func NewSet(s service.GRPC, logger log.Logger, duration metrics.Histogram, counter metrics.Gauge, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken jwtgo.Keyfunc) Set {
 
@@ -190,7 +340,7 @@ func NewSet(s service.GRPC, logger log.Logger, duration metrics.Histogram, count
 
 	var paramsEndpoint endpoint.Endpoint
 	{
-		paramsEndpoint = MakeRemoveEndpoint(s)
+		paramsEndpoint = MakeParamsEndpoint(s)
 		paramsEndpoint = limiter(paramsEndpoint)
 		paramsEndpoint = jwtEndpoint(paramsEndpoint)
 		paramsEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(paramsEndpoint)
@@ -203,6 +353,96 @@ func NewSet(s service.GRPC, logger log.Logger, duration metrics.Histogram, count
 		paramsEndpoint = InstrumentingMiddleware(duration.With("method", "Params"))(paramsEndpoint)
 	}
 
+	var getEndpoint endpoint.Endpoint
+	{
+		getEndpoint = MakeGetEndpoint(s)
+		getEndpoint = limiter(getEndpoint)
+		getEndpoint = jwtEndpoint(getEndpoint)
+		getEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(getEndpoint)
+		getEndpoint = opentracing.TraceServer(otTracer, "Get")(getEndpoint)
+
+		if zipkinTracer != nil {
+			getEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Get")(getEndpoint)
+		}
+		getEndpoint = LoggingMiddleware(log.With(logger, "method", "Get"))(getEndpoint)
+		getEndpoint = InstrumentingMiddleware(duration.With("method", "Get"))(getEndpoint)
+	}
+
+	var batchGetEndpoint endpoint.Endpoint
+	{
+		batchGetEndpoint = MakeBatchGetEndpoint(s)
+		batchGetEndpoint = limiter(batchGetEndpoint)
+		batchGetEndpoint = jwtEndpoint(batchGetEndpoint)
+		batchGetEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(batchGetEndpoint)
+		batchGetEndpoint = opentracing.TraceServer(otTracer, "BatchGet")(batchGetEndpoint)
+
+		if zipkinTracer != nil {
+			batchGetEndpoint = zipkin.TraceEndpoint(zipkinTracer, "BatchGet")(batchGetEndpoint)
+		}
+		batchGetEndpoint = LoggingMiddleware(log.With(logger, "method", "BatchGet"))(batchGetEndpoint)
+		batchGetEndpoint = InstrumentingMiddleware(duration.With("method", "BatchGet"))(batchGetEndpoint)
+	}
+
+	var findByParamEndpoint endpoint.Endpoint
+	{
+		findByParamEndpoint = MakeFindByParamEndpoint(s)
+		findByParamEndpoint = limiter(findByParamEndpoint)
+		findByParamEndpoint = jwtEndpoint(findByParamEndpoint)
+		findByParamEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(findByParamEndpoint)
+		findByParamEndpoint = opentracing.TraceServer(otTracer, "FindByParam")(findByParamEndpoint)
+
+		if zipkinTracer != nil {
+			findByParamEndpoint = zipkin.TraceEndpoint(zipkinTracer, "FindByParam")(findByParamEndpoint)
+		}
+		findByParamEndpoint = LoggingMiddleware(log.With(logger, "method", "FindByParam"))(findByParamEndpoint)
+		findByParamEndpoint = InstrumentingMiddleware(duration.With("method", "FindByParam"))(findByParamEndpoint)
+	}
+
+	var listEndpoint endpoint.Endpoint
+	{
+		listEndpoint = MakeListEndpoint(s)
+		listEndpoint = limiter(listEndpoint)
+		listEndpoint = jwtEndpoint(listEndpoint)
+		listEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(listEndpoint)
+		listEndpoint = opentracing.TraceServer(otTracer, "List")(listEndpoint)
+
+		if zipkinTracer != nil {
+			listEndpoint = zipkin.TraceEndpoint(zipkinTracer, "List")(listEndpoint)
+		}
+		listEndpoint = LoggingMiddleware(log.With(logger, "method", "List"))(listEndpoint)
+		listEndpoint = InstrumentingMiddleware(duration.With("method", "List"))(listEndpoint)
+	}
+
+	var touchEndpoint endpoint.Endpoint
+	{
+		touchEndpoint = MakeTouchEndpoint(s)
+		touchEndpoint = limiter(touchEndpoint)
+		touchEndpoint = jwtEndpoint(touchEndpoint)
+		touchEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(touchEndpoint)
+		touchEndpoint = opentracing.TraceServer(otTracer, "Touch")(touchEndpoint)
+
+		if zipkinTracer != nil {
+			touchEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Touch")(touchEndpoint)
+		}
+		touchEndpoint = LoggingMiddleware(log.With(logger, "method", "Touch"))(touchEndpoint)
+		touchEndpoint = InstrumentingMiddleware(duration.With("method", "Touch"))(touchEndpoint)
+	}
+
+	var leaseEndpoint endpoint.Endpoint
+	{
+		leaseEndpoint = MakeLeaseEndpoint(s)
+		leaseEndpoint = limiter(leaseEndpoint)
+		leaseEndpoint = jwtEndpoint(leaseEndpoint)
+		leaseEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(leaseEndpoint)
+		leaseEndpoint = opentracing.TraceServer(otTracer, "Lease")(leaseEndpoint)
+
+		if zipkinTracer != nil {
+			leaseEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Lease")(leaseEndpoint)
+		}
+		leaseEndpoint = LoggingMiddleware(log.With(logger, "method", "Lease"))(leaseEndpoint)
+		leaseEndpoint = InstrumentingMiddleware(duration.With("method", "Lease"))(leaseEndpoint)
+	}
+
 	var subscribeEndpoint endpoint.Endpoint
 	{
 		subscribeEndpoint = MakeSubscribeEndpoint(s)
@@ -210,11 +450,25 @@ func NewSet(s service.GRPC, logger log.Logger, duration metrics.Histogram, count
 		subscribeEndpoint = jwtEndpoint(subscribeEndpoint)
 	}
 
+	var replicateEndpoint endpoint.Endpoint
+	{
+		replicateEndpoint = MakeReplicateEndpoint(s)
+		replicateEndpoint = CounterClientMiddleware(counter.With("method", "Replicate"))(replicateEndpoint)
+		replicateEndpoint = jwtEndpoint(replicateEndpoint)
+	}
+
 	return Set{
-		SubscribeEndpoint: subscribeEndpoint,
-		BroadcastEndpoint: broadcastEndpoint,
-		NewEndpoint:       newEndpoint,
-		RemoveEndpoint:    removeEndpoint,
-		ParamsEndpoint:    paramsEndpoint,
+		SubscribeEndpoint:   subscribeEndpoint,
+		BroadcastEndpoint:   broadcastEndpoint,
+		NewEndpoint:         newEndpoint,
+		RemoveEndpoint:      removeEndpoint,
+		ParamsEndpoint:      paramsEndpoint,
+		ReplicateEndpoint:   replicateEndpoint,
+		GetEndpoint:         getEndpoint,
+		BatchGetEndpoint:    batchGetEndpoint,
+		FindByParamEndpoint: findByParamEndpoint,
+		ListEndpoint:        listEndpoint,
+		TouchEndpoint:       touchEndpoint,
+		LeaseEndpoint:       leaseEndpoint,
 	}
 }
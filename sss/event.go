@@ -2,6 +2,234 @@
 
 package sss
 
-func broadcastEventToCluster() {
+import (
+	"sort"
+	"time"
 
+	"github.com/doublemo/balala/sss/proto/pb"
+	"github.com/doublemo/balala/sss/session"
+	kitlog "github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/proto"
+)
+
+// broadcastEventToCluster 广播事件同步到集群中其它节点, 由其它节点发送给它们的订阅者
+func (s *baseGRPCServer) broadcastEventToCluster(events []*pb.SessionStateServerAPI_Event) {
+	if len(events) < 1 {
+		return
+	}
+
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{Events: events})
+}
+
+// broadcast 按事件分发给订阅者, 返回每个事件放入订阅者接收缓冲的数量
+// 事件指定了ServiceID时只发送给该服务的订阅者, 投递不会阻塞.
+// 数量不代表订阅者已经收到, OverflowDropOldest的订阅者在之后的广播中可能丢弃已计数的事件
+func (s *baseGRPCServer) broadcast(events []*pb.SessionStateServerAPI_Event) []int32 {
+	delivered := make([]int32, len(events))
+	indexes := make([]int, 0, len(events))
+	for _, subscriber := range s.subscribes.GetSubscribers() {
+		actions := make([]*pb.SessionStateServerAPI_Event, 0, len(events))
+		indexes = indexes[:0]
+		for i, action := range events {
+			if !subscriber.Subscribed(action.GetAction()) {
+				continue
+			}
+
+			if action.GetServiceID() > 0 && action.GetServiceID() != subscriber.GetServiceID() {
+				continue
+			}
+
+			actions = append(actions, action)
+			indexes = append(indexes, i)
+		}
+
+		if len(actions) < 1 {
+			continue
+		}
+
+		if !subscriber.Deliver(&pb.SessionStateServerAPI_BroadcastResponse{Actions: actions}) {
+			kitlog.Warn(s.logger).Log("error", "chanfull", "subscriber", subscriber.GetID())
+			continue
+		}
+
+		for _, i := range indexes {
+			delivered[i]++
+		}
+	}
+
+	return delivered
+}
+
+// emit 发送连接状态变化事件
+func (s *baseGRPCServer) emit(action int32, clientID string, body proto.Message) {
+	frame, err := proto.Marshal(body)
+	if err != nil {
+		kitlog.Error(s.logger).Log("error", err, "action", action, "client", clientID)
+		return
+	}
+
+	s.broadcast([]*pb.SessionStateServerAPI_Event{{Action: action, ClientID: clientID, Body: frame}})
+}
+
+// applyNew 按时间戳创建连接状态, expireAt为心跳延长后的过期时间, 0时按TTL计算
+// 新建时通知订阅了EventSessionNew的服务, 合并已经存在的连接时通知参数变化
+func (s *baseGRPCServer) applyNew(in *pb.SessionStateServerAPI_NewRequest, ts, expireAt int64) {
+	client, created := s.sessionStore.ApplyNew(in.GetClientID(), ts)
+	if client == nil {
+		return
+	}
+
+	client.SetRemote(in.GetRemoteID(), in.GetRemoteServAddr(), in.GetRemoteServID())
+	if in.GetTTL() > 0 {
+		ttl := time.Duration(in.GetTTL()) * time.Second
+		client.SetTTL(ttl)
+		client.Touch(time.Now().UnixNano() + int64(ttl))
+	}
+
+	client.Touch(expireAt)
+	changed := client.ApplyParams(ts, sessionParams(in.GetParams())...)
+	if created {
+		s.emit(pb.EventSessionNew, client.ID(), clientInfo(client))
+		return
+	}
+
+	if len(changed) > 0 {
+		s.emit(pb.EventSessionParams, client.ID(), &pb.SessionStateServerAPI_EventChangeParam{Values: pbParams(changed)})
+	}
+}
+
+// applyRemove 按时间戳删除连接状态, 通知订阅了EventSessionRemove的服务
+func (s *baseGRPCServer) applyRemove(id string, ts int64) {
+	if client := s.sessionStore.ApplyRemove(id, ts); client != nil {
+		s.emit(pb.EventSessionRemove, client.ID(), clientInfo(client))
+	}
+}
+
+// applyParams 按时间戳修改参数, Value为空的参数将被删除
+// 有变化时通知订阅了EventSessionParams的服务, 连接不存在时返回false
+func (s *baseGRPCServer) applyParams(in *pb.SessionStateServerAPI_NewRequest, ts int64) bool {
+	client := s.sessionStore.Get(in.GetClientID())
+	if client == nil {
+		return false
+	}
+
+	if changed := client.ApplyParams(ts, sessionParams(in.GetParams())...); len(changed) > 0 {
+		s.emit(pb.EventSessionParams, client.ID(), &pb.SessionStateServerAPI_EventChangeParam{Values: pbParams(changed)})
+	}
+
+	return true
+}
+
+// remove 删除连接状态, 并同步到集群中其它节点
+func (s *baseGRPCServer) remove(id string, ts int64) {
+	s.applyRemove(id, ts)
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{
+		Action:    pb.EventSessionRemove,
+		Session:   &pb.SessionStateServerAPI_NewRequest{ClientID: id},
+		Timestamp: ts,
+	})
+}
+
+// expire 删除过期的连接, 包括租约已经过期的服务的所有连接
+// 不是连接所有者时等待clusterExpireGrace, 防止同步中的续约被删除覆盖
+func (s *baseGRPCServer) expire() {
+	ts := s.sessionStore.Now()
+	for _, client := range s.sessionStore.Expire(time.Now().UnixNano(), int64(clusterExpireGrace)) {
+		s.remove(client.ID(), ts)
+	}
+}
+
+// release 撤销服务租约, 删除该服务的所有连接
+// 服务正常关闭或者服务的注册信息已经删除时调用
+func (s *baseGRPCServer) release(remoteServID string) {
+	ts := s.sessionStore.Now()
+	s.sessionStore.RevokeLease(remoteServID)
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{
+		Action:    pb.MutationLease,
+		Session:   &pb.SessionStateServerAPI_NewRequest{RemoteServID: remoteServID},
+		Timestamp: ts,
+	})
+
+	clients, _ := s.sessionStore.List("", 0, func(client *session.Client) bool {
+		_, _, servID := client.Remote()
+		return servID == remoteServID
+	})
+
+	for _, client := range clients {
+		s.remove(client.ID(), ts)
+	}
+
+	if len(clients) > 0 {
+		kitlog.Info(s.logger).Log("release", remoteServID, "sessions", len(clients))
+	}
+}
+
+// apply 应用集群中其它节点的修改, 不再转发给其它节点
+func (s *baseGRPCServer) apply(m *pb.SessionStateServerAPI_Mutation) {
+	switch m.GetAction() {
+	case pb.EventSessionNew:
+		s.applyNew(m.GetSession(), m.GetTimestamp(), m.GetExpireAt())
+
+	case pb.EventSessionRemove:
+		s.applyRemove(m.GetSession().GetClientID(), m.GetTimestamp())
+
+	case pb.EventSessionParams:
+		s.applyParams(m.GetSession(), m.GetTimestamp())
+
+	case pb.MutationTouch:
+		if client := s.sessionStore.Get(m.GetSession().GetClientID()); client != nil && client.Version() <= m.GetTimestamp() {
+			client.Touch(m.GetExpireAt())
+		}
+
+	case pb.MutationLease:
+		if m.GetExpireAt() > 0 {
+			s.sessionStore.ApplyLease(m.GetSession().GetRemoteServID(), m.GetExpireAt())
+		} else {
+			s.sessionStore.RevokeLease(m.GetSession().GetRemoteServID())
+		}
+	}
+
+	if len(m.GetEvents()) > 0 {
+		s.broadcast(m.GetEvents())
+	}
+}
+
+func sessionParams(params []*pb.SessionStateServerAPI_Param) []*session.Param {
+	m := make([]*session.Param, len(params))
+	for i, v := range params {
+		m[i] = &session.Param{Key: v.GetKey(), Value: v.GetValue(), Version: v.GetVersion()}
+	}
+	return m
+}
+
+func pbParams(params []*session.Param) []*pb.SessionStateServerAPI_Param {
+	m := make([]*pb.SessionStateServerAPI_Param, len(params))
+	for i, v := range params {
+		m[i] = &pb.SessionStateServerAPI_Param{Key: v.Key, Value: v.Value, Version: v.Version}
+	}
+	return m
+}
+
+// clientInfo 连接信息, 参数按key排序
+func clientInfo(client *session.Client) *pb.SessionStateServerAPI_NewRequest {
+	remoteID, remoteServAddr, remoteServID := client.Remote()
+	info := &pb.SessionStateServerAPI_NewRequest{
+		ClientID:       client.ID(),
+		RemoteID:       remoteID,
+		RemoteServAddr: remoteServAddr,
+		RemoteServID:   remoteServID,
+		Version:        client.Version(),
+	}
+
+	params := client.Params()
+	info.Params = make([]*pb.SessionStateServerAPI_Param, 0, len(params))
+	for k, v := range params {
+		info.Params = append(info.Params, &pb.SessionStateServerAPI_Param{Key: k, Value: v})
+	}
+
+	sort.Slice(info.Params, func(i, j int) bool {
+		return info.Params[i].Key < info.Params[j].Key
+	})
+
+	return info
 }
@@ -14,6 +14,7 @@ import (
 	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/doublemo/balala/cores/process"
 	"github.com/doublemo/balala/cores/services"
+	"github.com/doublemo/balala/cores/tracing"
 	"github.com/doublemo/balala/cores/utils"
 	"github.com/doublemo/balala/sss/endpoint"
 	"github.com/doublemo/balala/sss/proto/pb"
@@ -22,16 +23,32 @@ import (
 	"github.com/go-kit/kit/log"
 	kitlog "github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics"
-	"github.com/go-kit/kit/metrics/prometheus"
 	kitgrpc "github.com/go-kit/kit/transport/grpc"
-	stdopentracing "github.com/opentracing/opentracing-go"
-	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
-	stdzipkin "github.com/openzipkin/zipkin-go"
-	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
-	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 查询分页
+const (
+	// defaultListLimit 默认每页数量
+	defaultListLimit = 100
+
+	// maxListLimit 每页最大数量, BatchGet的最大数量
+	maxListLimit = 1000
+)
+
+var (
+	// ErrInvalidClientID 连接ID为空
+	ErrInvalidClientID = errors.New("ErrInvalidClientID")
+
+	// ErrInvalidQuery 查询条件错误
+	ErrInvalidQuery = errors.New("ErrInvalidQuery")
+
+	// ErrInvalidRemoteServID 远程服务ID为空
+	ErrInvalidRemoteServID = errors.New("ErrInvalidRemoteServID")
 )
 
 // baseGRPCServer 服务于内部通信的grpc
@@ -43,38 +60,39 @@ type baseGRPCServer struct {
 	sessionStore *session.Store
 
 	// 集群中其它sss服务连接信息
+	cluster *cluster
 
 	// logger 日志
 	logger log.Logger
 }
 
 // Broadcast 广播
+// 按事件分发给订阅了该事件的订阅者, 事件指定了ServiceID时只发送给该服务的订阅者.
+// 投递不会阻塞, 接收缓冲已满时按订阅者的溢出处理方式处理, 响应返回每个事件在本节点投递成功的数量.
+// 事件同时同步到集群中其它节点
 func (s *baseGRPCServer) Broadcast(ctx context.Context, in *pb.SessionStateServerAPI_BroadcastRequest) (*pb.SessionStateServerAPI_BroadcastResponse, error) {
 	defer utils.RecoverStackPanic(s.logger, in)
-
-	return &pb.SessionStateServerAPI_BroadcastResponse{}, nil
+	delivered := s.broadcast(in.GetActions())
+	s.broadcastEventToCluster(in.GetActions())
+	return &pb.SessionStateServerAPI_BroadcastResponse{Delivered: delivered}, nil
 }
 
 // New 新状态
+// 通知订阅了EventSessionNew的服务, 并同步到集群中其它节点
 func (s *baseGRPCServer) New(ctx context.Context, in *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error) {
 	defer utils.RecoverStackPanic(s.logger, in)
 	if in.GetClientID() == "" {
 		return nil, nil
 	}
 
-	client := s.sessionStore.NewClient(in.GetClientID())
-	storeParams := make([]*session.Param, len(in.Params))
-	for i, v := range in.Params {
-		storeParams[i] = &session.Param{Key: v.GetKey(), Value: v.GetValue()}
-	}
-
-	client.SetParam(storeParams...)
-
-	// 通知集群其它服务
+	ts := s.sessionStore.Now()
+	s.applyNew(in, ts, 0)
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{Action: pb.EventSessionNew, Session: in, Timestamp: ts})
 	return &pb.SessionStateServerAPI_Nil{}, nil
 }
 
 // Remove 删除
+// 通知订阅了EventSessionRemove的服务, 并同步到集群中其它节点
 func (s *baseGRPCServer) Remove(ctx context.Context, in *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error) {
 	defer utils.RecoverStackPanic(s.logger, in)
 
@@ -82,11 +100,12 @@ func (s *baseGRPCServer) Remove(ctx context.Context, in *pb.SessionStateServerAP
 		return nil, nil
 	}
 
-	s.sessionStore.Remove(in.GetClientID())
+	s.remove(in.GetClientID(), s.sessionStore.Now())
 	return &pb.SessionStateServerAPI_Nil{}, nil
 }
 
 // Params 参数修改
+// Value为空的参数将被删除, 有变化时通知订阅了EventSessionParams的服务, 并同步到集群中其它节点
 func (s *baseGRPCServer) Params(ctx context.Context, in *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error) {
 	defer utils.RecoverStackPanic(s.logger, in)
 
@@ -94,11 +113,157 @@ func (s *baseGRPCServer) Params(ctx context.Context, in *pb.SessionStateServerAP
 		return nil, nil
 	}
 
-	client := s.sessionStore.Get(in.GetClientID())
-	if client == nil {
+	ts := s.sessionStore.Now()
+	if !s.applyParams(in, ts) {
 		return nil, nil
 	}
 
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{Action: pb.EventSessionParams, Session: in, Timestamp: ts})
+	return &pb.SessionStateServerAPI_Nil{}, nil
+}
+
+// Replicate 接收集群中其它sss节点的连接状态变化
+func (s *baseGRPCServer) Replicate(_ context.Context, stream pb.SessionStateServer_ReplicateServer) error {
+	defer utils.RecoverStackPanic(s.logger)
+	for {
+		m, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.SessionStateServerAPI_Nil{})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.apply(m)
+	}
+}
+
+// Get 查询连接信息, 连接不存在时Session为空
+func (s *baseGRPCServer) Get(ctx context.Context, in *pb.SessionStateServerAPI_GetRequest) (*pb.SessionStateServerAPI_GetResponse, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	if in.GetClientID() == "" {
+		return nil, ErrInvalidClientID
+	}
+
+	resp := &pb.SessionStateServerAPI_GetResponse{}
+	if client := s.sessionStore.Get(in.GetClientID()); client != nil {
+		resp.Session = clientInfo(client)
+	}
+
+	return resp, nil
+}
+
+// BatchGet 批量查询连接信息, 按请求的顺序返回存在的连接
+func (s *baseGRPCServer) BatchGet(ctx context.Context, in *pb.SessionStateServerAPI_BatchGetRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	if len(in.GetClientIDs()) > maxListLimit {
+		return nil, ErrInvalidQuery
+	}
+
+	resp := &pb.SessionStateServerAPI_ListResponse{Sessions: make([]*pb.SessionStateServerAPI_NewRequest, 0, len(in.GetClientIDs()))}
+	for _, id := range in.GetClientIDs() {
+		if client := s.sessionStore.Get(id); client != nil {
+			resp.Sessions = append(resp.Sessions, clientInfo(client))
+		}
+	}
+
+	return resp, nil
+}
+
+// FindByParam 按参数或者连接所在的服务查找连接, 按连接ID排序分页
+// Value为空时匹配所有设置了该参数的连接
+func (s *baseGRPCServer) FindByParam(ctx context.Context, in *pb.SessionStateServerAPI_FindByParamRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	if in.GetKey() == "" && in.GetRemoteServID() == "" {
+		return nil, ErrInvalidQuery
+	}
+
+	clients, next := s.sessionStore.List(in.GetCursor(), listLimit(in.GetLimit()), func(client *session.Client) bool {
+		if in.GetRemoteServID() != "" {
+			if _, _, servID := client.Remote(); servID != in.GetRemoteServID() {
+				return false
+			}
+		}
+
+		if in.GetKey() == "" {
+			return true
+		}
+
+		v, ok := client.Param(in.GetKey())
+		return ok && (in.GetValue() == "" || v == in.GetValue())
+	})
+
+	return listResponse(clients, next), nil
+}
+
+// List 按连接ID排序分页查询所有连接
+func (s *baseGRPCServer) List(ctx context.Context, in *pb.SessionStateServerAPI_ListRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	clients, next := s.sessionStore.List(in.GetCursor(), listLimit(in.GetLimit()), nil)
+	return listResponse(clients, next), nil
+}
+
+// Touch 心跳, 延长连接的过期时间
+// TTL为0时使用创建连接时的TTL, 没有设置过期时间的连接将被忽略
+func (s *baseGRPCServer) Touch(ctx context.Context, in *pb.SessionStateServerAPI_TouchRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	if len(in.GetClientIDs()) > maxListLimit {
+		return nil, ErrInvalidQuery
+	}
+
+	ts := s.sessionStore.Now()
+	for _, id := range in.GetClientIDs() {
+		client := s.sessionStore.Get(id)
+		if client == nil {
+			continue
+		}
+
+		ttl := time.Duration(in.GetTTL()) * time.Second
+		if ttl <= 0 {
+			ttl = client.TTL()
+		}
+
+		if ttl <= 0 {
+			continue
+		}
+
+		client.Touch(time.Now().UnixNano() + int64(ttl))
+		s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{
+			Action:    pb.MutationTouch,
+			Session:   &pb.SessionStateServerAPI_NewRequest{ClientID: id},
+			Timestamp: ts,
+			ExpireAt:  client.ExpireAt(),
+		})
+	}
+
+	return &pb.SessionStateServerAPI_Nil{}, nil
+}
+
+// Lease 服务租约, 服务定时续约, 租约过期时删除该服务的所有连接
+// 处理续约的节点立即删除该服务过期的连接, 其它节点等待clusterExpireGrace
+// TTL为0时撤销租约并立即删除该服务的所有连接, 用于服务正常关闭
+func (s *baseGRPCServer) Lease(ctx context.Context, in *pb.SessionStateServerAPI_LeaseRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	defer utils.RecoverStackPanic(s.logger, in)
+	if in.GetRemoteServID() == "" {
+		return nil, ErrInvalidRemoteServID
+	}
+
+	if in.GetTTL() <= 0 {
+		s.release(in.GetRemoteServID())
+		return &pb.SessionStateServerAPI_Nil{}, nil
+	}
+
+	ts := s.sessionStore.Now()
+	expireAt := time.Now().UnixNano() + int64(time.Duration(in.GetTTL())*time.Second)
+	s.sessionStore.Lease(in.GetRemoteServID(), expireAt)
+	s.cluster.replicate(&pb.SessionStateServerAPI_Mutation{
+		Action:    pb.MutationLease,
+		Session:   &pb.SessionStateServerAPI_NewRequest{RemoteServID: in.GetRemoteServID()},
+		Timestamp: ts,
+		ExpireAt:  expireAt,
+	})
+
 	return &pb.SessionStateServerAPI_Nil{}, nil
 }
 
@@ -109,13 +274,13 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 		return errors.New("Invalid metadata")
 	}
 
-	id, ok := metadata["id"]
-	if !ok || len(id) < 1 {
+	id := metadata.Get("id")
+	if len(id) < 1 {
 		return errors.New("Invalid id")
 	}
 
-	serviceID, ok := metadata["serviceID"]
-	if !ok || len(serviceID) < 1 {
+	serviceID := metadata.Get("serviceid")
+	if len(serviceID) < 1 {
 		return errors.New("Invalid serviceID")
 	}
 
@@ -125,7 +290,7 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 	}
 
 	events := make([]int32, 0)
-	if m, ok := metadata["events"]; ok {
+	if m := metadata.Get("events"); len(m) > 0 {
 		for _, eid := range m {
 			evid, err := strconv.Atoi(eid)
 			if err != nil {
@@ -139,7 +304,17 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 	if len(events) < 1 {
 		return errors.New("Invalid events")
 	}
-	subscriber, err := s.subscribes.NewSubscriber(id[0], int32(sid), events)
+
+	// 接收缓冲溢出时的处理方式, 见session.ParseOverflowPolicy
+	var overflow session.OverflowPolicy
+	if m := metadata.Get("overflow"); len(m) > 0 {
+		overflow, err = session.ParseOverflowPolicy(m[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	subscriber, err := s.subscribes.NewSubscriber(id[0], int32(sid), events, overflow)
 	if err != nil {
 		return err
 	}
@@ -149,9 +324,15 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 	}()
 
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// 对方关闭发送时recvChan被关闭, 订阅随之结束
+	done := make(chan struct{})
+	defer close(done)
+
 	recvChan := make(chan *pb.SessionStateServerAPI_Nil, 4096)
-	recvErr := make(chan error)
-	go s.recv(stream, recvChan, recvErr)
+	recvErr := make(chan error, 1)
+	go s.recv(stream, recvChan, recvErr, done)
 	for {
 		select {
 		case frame, ok := <-recvChan:
@@ -165,13 +346,16 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 				return nil
 			}
 
-			stream.Send(frame)
-
-		case err, ok := <-recvErr:
-			if !ok {
+			if err := stream.Send(frame); err != nil {
+				s.logger.Log("error", err)
 				return nil
 			}
 
+		case <-subscriber.Done():
+			kitlog.Warn(s.logger).Log("error", "overflow", "subscriber", subscriber.GetID())
+			return status.Error(codes.ResourceExhausted, "subscriber overflow")
+
+		case err := <-recvErr:
 			s.logger.Log("error", err)
 			return nil
 
@@ -180,16 +364,14 @@ func (s *baseGRPCServer) Subscribe(_ context.Context, stream pb.SessionStateServ
 	}
 }
 
-func (s *baseGRPCServer) recv(stream pb.SessionStateServer_SubscribeServer, recvChan chan *pb.SessionStateServerAPI_Nil, recvErr chan error) {
-	defer func() {
-		close(recvChan)
-		close(recvErr)
-	}()
-
+// recv 接收订阅方的数据, 对方关闭发送时关闭recvChan, 其它错误发送到recvErr
+// Subscribe返回后关闭done, recv随之退出
+func (s *baseGRPCServer) recv(stream pb.SessionStateServer_SubscribeServer, recvChan chan *pb.SessionStateServerAPI_Nil, recvErr chan error, done chan struct{}) {
 	for {
 		frame, err := stream.Recv()
 		if err == io.EOF {
-			continue
+			close(recvChan)
+			return
 		}
 
 		if err != nil {
@@ -197,19 +379,48 @@ func (s *baseGRPCServer) recv(stream pb.SessionStateServer_SubscribeServer, recv
 			return
 		}
 
-		recvChan <- frame
+		select {
+		case recvChan <- frame:
+		case <-done:
+			return
+		}
+	}
+}
+
+func listLimit(limit int32) int {
+	if limit < 1 {
+		return defaultListLimit
+	}
+
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+
+	return int(limit)
+}
+
+func listResponse(clients []*session.Client, next string) *pb.SessionStateServerAPI_ListResponse {
+	resp := &pb.SessionStateServerAPI_ListResponse{
+		Sessions:   make([]*pb.SessionStateServerAPI_NewRequest, len(clients)),
+		NextCursor: next,
+	}
+
+	for i, client := range clients {
+		resp.Sessions[i] = clientInfo(client)
 	}
+
+	return resp
 }
 
-func newBaseGRPCServer(logger log.Logger) *baseGRPCServer {
+func newBaseGRPCServer(store *session.Store, subscribes *session.SubscribeStore, logger log.Logger) *baseGRPCServer {
 	return &baseGRPCServer{
-		subscribes:   session.NewSubscribeStore(),
-		sessionStore: session.NewStore(),
+		subscribes:   subscribes,
+		sessionStore: store,
 		logger:       logger,
 	}
 }
 
-func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger log.Logger) (*process.RuntimeActor, error) {
+func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, store *session.Store, subscribes *session.SubscribeStore, clusterChan chan struct{}, tracer *tracing.Zipkin, logger log.Logger) (*process.RuntimeActor, error) {
 	grpcOpts := opts.GRPC
 	if grpcOpts == nil {
 		return nil, nil
@@ -223,7 +434,7 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger l
 	serviceOpts.Port = port
 	var duration metrics.Histogram
 	{
-		duration = prometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+		duration = utils.SummaryFrom(stdprometheus.SummaryOpts{
 			Namespace: opts.ID,
 			Subsystem: "sss",
 			Name:      "request_duration_seconds",
@@ -233,7 +444,7 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger l
 
 	var counter metrics.Gauge
 	{
-		counter = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		counter = utils.GaugeFrom(stdprometheus.GaugeOpts{
 			Namespace: opts.ID,
 			Subsystem: "sss",
 			Name:      "connect_to_counter",
@@ -241,29 +452,14 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger l
 		}, []string{"method"})
 	}
 
-	tracer := stdopentracing.GlobalTracer() // no-op
-
-	var reporter zipkinreporter.Reporter
-	zipkinTracer, err := stdzipkin.NewTracer(nil, stdzipkin.WithNoopTracer(true))
-	if opts.Tracer != nil && opts.Tracer.ReporterURL != "" {
-
-		// some http://192.168.31.20:9411/api/v2/spans
-		reporter = zipkinhttp.NewReporter(opts.Tracer.ReporterURL)
-		zEP, _ := stdzipkin.NewEndpoint("sss", port)
-		zipkinTracer, err = stdzipkin.NewTracer(reporter, stdzipkin.WithLocalEndpoint(zEP))
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	tracer = zipkinot.Wrap(zipkinTracer)
 	var (
-		s          = newBaseGRPCServer(logger)
-		endpoints  = endpoint.NewSet(s, logger, duration, counter, tracer, zipkinTracer, makeKeyFuncByJWT(opts.ServiceSecurityKey))
-		grpcServer = transport.NewGRPCServer(endpoints, tracer, zipkinTracer, logger)
+		s          = newBaseGRPCServer(store, subscribes, logger)
+		endpoints  = endpoint.NewSet(s, logger, duration, counter, tracer.OpenTracer(), tracer.Tracer(), makeKeyFuncByJWT(opts.ServiceSecurityKey))
+		grpcServer = transport.NewGRPCServer(endpoints, tracer.OpenTracer(), tracer.Tracer(), logger)
 	)
 
+	s.cluster = newCluster(serviceOpts.IP+":"+port, s, []byte(opts.ServiceSecurityKey), clusterChan, logger)
+
 	lis, err := net.Listen("tcp", grpcOpts.Addr)
 	if err != nil {
 		return nil, err
@@ -272,6 +468,9 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger l
 	return &process.RuntimeActor{
 		Exec: func() error {
 			logger.Log("transport", "grpc", "on", grpcOpts.Addr)
+			go s.cluster.serve()
+			defer s.cluster.close()
+
 			baseServer := grpc.NewServer(grpc.UnaryInterceptor(kitgrpc.Interceptor), grpc.MaxConcurrentStreams(65535))
 			pb.RegisterSessionStateServerServer(baseServer, grpcServer)
 			return baseServer.Serve(lis)
@@ -284,12 +483,11 @@ func makeGRPCRuntimeActor(serviceOpts *services.Options, opts *Options, logger l
 
 		Close: func() {
 			logger.Log("transport", "grpc", "on", "shutdown")
-			if reporter != nil {
-				reporter.Close()
-			}
-
+			s.cluster.close()
 			lis.Close()
 		},
+
+		Restart: process.RestartOnFailure,
 	}, nil
 }
 
@@ -76,6 +76,15 @@ type TracerOptions struct {
 	ReporterURL string `alias:"reporterurl"`
 }
 
+// GetReporterURL 返回追踪服务地址, 没有配置追踪时返回空
+func (o *TracerOptions) GetReporterURL() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.ReporterURL
+}
+
 // Clone ETCDOptions
 func (o *TracerOptions) Clone() *TracerOptions {
 	return &TracerOptions{
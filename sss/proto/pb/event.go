@@ -0,0 +1,34 @@
+package pb
+
+// 事件定义
+// 对应SessionStateServerAPI.Event.Action, 订阅者按事件订阅
+const (
+	// EventKick 踢掉用户在其它代理服务器上的连接
+	// ClientID 为新登录的连接ID, Body 为JSON {"uid": 用户ID, "before": sss分配的创建时间戳},
+	// 只踢掉创建时间戳(SessionStateServerAPI.NewRequest.Version)早于before的连接
+	EventKick int32 = 1
+
+	// EventSessionNew 新的连接
+	// Body 为SessionStateServerAPI.NewRequest, 包括连接所在的服务与参数
+	EventSessionNew int32 = 2
+
+	// EventSessionRemove 连接已删除
+	// Body 为SessionStateServerAPI.NewRequest, 包括删除前的参数
+	EventSessionRemove int32 = 3
+
+	// EventSessionParams 连接参数已修改
+	// Body 为SessionStateServerAPI.EventChangeParam, 只包括变化的参数, Value为空表示参数已删除
+	EventSessionParams int32 = 4
+)
+
+// 集群同步的修改
+// 对应SessionStateServerAPI.Mutation.Action, 只在sss节点之间同步, 不会发送给订阅者
+const (
+	// MutationTouch 延长连接的过期时间
+	// Session.ClientID 为连接ID, ExpireAt 为新的过期时间
+	MutationTouch int32 = 101
+
+	// MutationLease 服务租约
+	// Session.RemoteServID 为服务ID, ExpireAt 为租约的过期时间, 为0时撤销租约
+	MutationLease int32 = 102
+)
@@ -89,6 +89,7 @@ var xxx_messageInfo_SessionStateServerAPI_Nil proto.InternalMessageInfo
 type SessionStateServerAPI_Param struct {
 	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	Value                string   `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
+	Version              int64    `protobuf:"varint,3,opt,name=Version,proto3" json:"Version,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -133,10 +134,18 @@ func (m *SessionStateServerAPI_Param) GetValue() string {
 	return ""
 }
 
+func (m *SessionStateServerAPI_Param) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 type SessionStateServerAPI_Event struct {
 	Action               int32    `protobuf:"varint,1,opt,name=Action,proto3" json:"Action,omitempty"`
 	ClientID             string   `protobuf:"bytes,2,opt,name=ClientID,proto3" json:"ClientID,omitempty"`
 	Body                 []byte   `protobuf:"bytes,3,opt,name=Body,proto3" json:"Body,omitempty"`
+	ServiceID            int32    `protobuf:"varint,4,opt,name=ServiceID,proto3" json:"ServiceID,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -188,6 +197,13 @@ func (m *SessionStateServerAPI_Event) GetBody() []byte {
 	return nil
 }
 
+func (m *SessionStateServerAPI_Event) GetServiceID() int32 {
+	if m != nil {
+		return m.ServiceID
+	}
+	return 0
+}
+
 type SessionStateServerAPI_EventChangeParam struct {
 	Values               []*SessionStateServerAPI_Param `protobuf:"bytes,1,rep,name=Values,proto3" json:"Values,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
@@ -272,6 +288,7 @@ func (m *SessionStateServerAPI_BroadcastRequest) GetActions() []*SessionStateSer
 
 type SessionStateServerAPI_BroadcastResponse struct {
 	Actions              []*SessionStateServerAPI_Event `protobuf:"bytes,1,rep,name=Actions,proto3" json:"Actions,omitempty"`
+	Delivered            []int32                        `protobuf:"varint,2,rep,packed,name=Delivered,proto3" json:"Delivered,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
 	XXX_unrecognized     []byte                         `json:"-"`
 	XXX_sizecache        int32                          `json:"-"`
@@ -311,9 +328,21 @@ func (m *SessionStateServerAPI_BroadcastResponse) GetActions() []*SessionStateSe
 	return nil
 }
 
+func (m *SessionStateServerAPI_BroadcastResponse) GetDelivered() []int32 {
+	if m != nil {
+		return m.Delivered
+	}
+	return nil
+}
+
 type SessionStateServerAPI_NewRequest struct {
 	ClientID             string                         `protobuf:"bytes,1,opt,name=ClientID,proto3" json:"ClientID,omitempty"`
-	Params               []*SessionStateServerAPI_Param `protobuf:"bytes,2,rep,name=Params,proto3" json:"Params,omitempty"`
+	RemoteID             string                         `protobuf:"bytes,2,opt,name=RemoteID,proto3" json:"RemoteID,omitempty"`
+	RemoteServAddr       string                         `protobuf:"bytes,3,opt,name=RemoteServAddr,proto3" json:"RemoteServAddr,omitempty"`
+	RemoteServID         string                         `protobuf:"bytes,4,opt,name=RemoteServID,proto3" json:"RemoteServID,omitempty"`
+	Params               []*SessionStateServerAPI_Param `protobuf:"bytes,6,rep,name=Params,proto3" json:"Params,omitempty"`
+	TTL                  int32                          `protobuf:"varint,7,opt,name=TTL,proto3" json:"TTL,omitempty"`
+	Version              int64                          `protobuf:"varint,8,opt,name=Version,proto3" json:"Version,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
 	XXX_unrecognized     []byte                         `json:"-"`
 	XXX_sizecache        int32                          `json:"-"`
@@ -351,6 +380,27 @@ func (m *SessionStateServerAPI_NewRequest) GetClientID() string {
 	return ""
 }
 
+func (m *SessionStateServerAPI_NewRequest) GetRemoteID() string {
+	if m != nil {
+		return m.RemoteID
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_NewRequest) GetRemoteServAddr() string {
+	if m != nil {
+		return m.RemoteServAddr
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_NewRequest) GetRemoteServID() string {
+	if m != nil {
+		return m.RemoteServID
+	}
+	return ""
+}
+
 func (m *SessionStateServerAPI_NewRequest) GetParams() []*SessionStateServerAPI_Param {
 	if m != nil {
 		return m.Params
@@ -358,6 +408,469 @@ func (m *SessionStateServerAPI_NewRequest) GetParams() []*SessionStateServerAPI_
 	return nil
 }
 
+func (m *SessionStateServerAPI_NewRequest) GetTTL() int32 {
+	if m != nil {
+		return m.TTL
+	}
+	return 0
+}
+
+func (m *SessionStateServerAPI_NewRequest) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type SessionStateServerAPI_Mutation struct {
+	Action               int32                             `protobuf:"varint,1,opt,name=Action,proto3" json:"Action,omitempty"`
+	Session              *SessionStateServerAPI_NewRequest `protobuf:"bytes,2,opt,name=Session,proto3" json:"Session,omitempty"`
+	Timestamp            int64                             `protobuf:"varint,3,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	Events               []*SessionStateServerAPI_Event    `protobuf:"bytes,4,rep,name=Events,proto3" json:"Events,omitempty"`
+	ExpireAt             int64                             `protobuf:"varint,5,opt,name=ExpireAt,proto3" json:"ExpireAt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                          `json:"-"`
+	XXX_unrecognized     []byte                            `json:"-"`
+	XXX_sizecache        int32                             `json:"-"`
+}
+
+func (m *SessionStateServerAPI_Mutation) Reset()         { *m = SessionStateServerAPI_Mutation{} }
+func (m *SessionStateServerAPI_Mutation) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_Mutation) ProtoMessage()    {}
+func (*SessionStateServerAPI_Mutation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 7}
+}
+
+func (m *SessionStateServerAPI_Mutation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_Mutation.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_Mutation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_Mutation.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_Mutation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_Mutation.Merge(m, src)
+}
+func (m *SessionStateServerAPI_Mutation) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_Mutation.Size(m)
+}
+func (m *SessionStateServerAPI_Mutation) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_Mutation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_Mutation proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_Mutation) GetAction() int32 {
+	if m != nil {
+		return m.Action
+	}
+	return 0
+}
+
+func (m *SessionStateServerAPI_Mutation) GetSession() *SessionStateServerAPI_NewRequest {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *SessionStateServerAPI_Mutation) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *SessionStateServerAPI_Mutation) GetEvents() []*SessionStateServerAPI_Event {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *SessionStateServerAPI_Mutation) GetExpireAt() int64 {
+	if m != nil {
+		return m.ExpireAt
+	}
+	return 0
+}
+
+type SessionStateServerAPI_GetRequest struct {
+	ClientID             string   `protobuf:"bytes,1,opt,name=ClientID,proto3" json:"ClientID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_GetRequest) Reset()         { *m = SessionStateServerAPI_GetRequest{} }
+func (m *SessionStateServerAPI_GetRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_GetRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_GetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 8}
+}
+
+func (m *SessionStateServerAPI_GetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_GetRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_GetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_GetRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_GetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_GetRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_GetRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_GetRequest.Size(m)
+}
+func (m *SessionStateServerAPI_GetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_GetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_GetRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_GetRequest) GetClientID() string {
+	if m != nil {
+		return m.ClientID
+	}
+	return ""
+}
+
+type SessionStateServerAPI_GetResponse struct {
+	Session              *SessionStateServerAPI_NewRequest `protobuf:"bytes,1,opt,name=Session,proto3" json:"Session,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                          `json:"-"`
+	XXX_unrecognized     []byte                            `json:"-"`
+	XXX_sizecache        int32                             `json:"-"`
+}
+
+func (m *SessionStateServerAPI_GetResponse) Reset()         { *m = SessionStateServerAPI_GetResponse{} }
+func (m *SessionStateServerAPI_GetResponse) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_GetResponse) ProtoMessage()    {}
+func (*SessionStateServerAPI_GetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 9}
+}
+
+func (m *SessionStateServerAPI_GetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_GetResponse.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_GetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_GetResponse.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_GetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_GetResponse.Merge(m, src)
+}
+func (m *SessionStateServerAPI_GetResponse) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_GetResponse.Size(m)
+}
+func (m *SessionStateServerAPI_GetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_GetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_GetResponse proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_GetResponse) GetSession() *SessionStateServerAPI_NewRequest {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type SessionStateServerAPI_BatchGetRequest struct {
+	ClientIDs            []string `protobuf:"bytes,1,rep,name=ClientIDs,proto3" json:"ClientIDs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_BatchGetRequest) Reset()         { *m = SessionStateServerAPI_BatchGetRequest{} }
+func (m *SessionStateServerAPI_BatchGetRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_BatchGetRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_BatchGetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 10}
+}
+
+func (m *SessionStateServerAPI_BatchGetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_BatchGetRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_BatchGetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_BatchGetRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_BatchGetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_BatchGetRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_BatchGetRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_BatchGetRequest.Size(m)
+}
+func (m *SessionStateServerAPI_BatchGetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_BatchGetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_BatchGetRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_BatchGetRequest) GetClientIDs() []string {
+	if m != nil {
+		return m.ClientIDs
+	}
+	return nil
+}
+
+type SessionStateServerAPI_FindByParamRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
+	RemoteServID         string   `protobuf:"bytes,3,opt,name=RemoteServID,proto3" json:"RemoteServID,omitempty"`
+	Cursor               string   `protobuf:"bytes,4,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	Limit                int32    `protobuf:"varint,5,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) Reset() {
+	*m = SessionStateServerAPI_FindByParamRequest{}
+}
+func (m *SessionStateServerAPI_FindByParamRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_FindByParamRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_FindByParamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 11}
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_FindByParamRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_FindByParamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_FindByParamRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_FindByParamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_FindByParamRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_FindByParamRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_FindByParamRequest.Size(m)
+}
+func (m *SessionStateServerAPI_FindByParamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_FindByParamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_FindByParamRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_FindByParamRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) GetRemoteServID() string {
+	if m != nil {
+		return m.RemoteServID
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_FindByParamRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type SessionStateServerAPI_ListRequest struct {
+	Cursor               string   `protobuf:"bytes,1,opt,name=Cursor,proto3" json:"Cursor,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_ListRequest) Reset()         { *m = SessionStateServerAPI_ListRequest{} }
+func (m *SessionStateServerAPI_ListRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_ListRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 12}
+}
+
+func (m *SessionStateServerAPI_ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_ListRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_ListRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_ListRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_ListRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_ListRequest.Size(m)
+}
+func (m *SessionStateServerAPI_ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_ListRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_ListRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_ListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type SessionStateServerAPI_ListResponse struct {
+	Sessions             []*SessionStateServerAPI_NewRequest `protobuf:"bytes,1,rep,name=Sessions,proto3" json:"Sessions,omitempty"`
+	NextCursor           string                              `protobuf:"bytes,2,opt,name=NextCursor,proto3" json:"NextCursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                            `json:"-"`
+	XXX_unrecognized     []byte                              `json:"-"`
+	XXX_sizecache        int32                               `json:"-"`
+}
+
+func (m *SessionStateServerAPI_ListResponse) Reset()         { *m = SessionStateServerAPI_ListResponse{} }
+func (m *SessionStateServerAPI_ListResponse) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_ListResponse) ProtoMessage()    {}
+func (*SessionStateServerAPI_ListResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 13}
+}
+
+func (m *SessionStateServerAPI_ListResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_ListResponse.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_ListResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_ListResponse.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_ListResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_ListResponse.Merge(m, src)
+}
+func (m *SessionStateServerAPI_ListResponse) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_ListResponse.Size(m)
+}
+func (m *SessionStateServerAPI_ListResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_ListResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_ListResponse proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_ListResponse) GetSessions() []*SessionStateServerAPI_NewRequest {
+	if m != nil {
+		return m.Sessions
+	}
+	return nil
+}
+
+func (m *SessionStateServerAPI_ListResponse) GetNextCursor() string {
+	if m != nil {
+		return m.NextCursor
+	}
+	return ""
+}
+
+type SessionStateServerAPI_TouchRequest struct {
+	ClientIDs            []string `protobuf:"bytes,1,rep,name=ClientIDs,proto3" json:"ClientIDs,omitempty"`
+	TTL                  int32    `protobuf:"varint,2,opt,name=TTL,proto3" json:"TTL,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_TouchRequest) Reset()         { *m = SessionStateServerAPI_TouchRequest{} }
+func (m *SessionStateServerAPI_TouchRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_TouchRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_TouchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 14}
+}
+
+func (m *SessionStateServerAPI_TouchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_TouchRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_TouchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_TouchRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_TouchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_TouchRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_TouchRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_TouchRequest.Size(m)
+}
+func (m *SessionStateServerAPI_TouchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_TouchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_TouchRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_TouchRequest) GetClientIDs() []string {
+	if m != nil {
+		return m.ClientIDs
+	}
+	return nil
+}
+
+func (m *SessionStateServerAPI_TouchRequest) GetTTL() int32 {
+	if m != nil {
+		return m.TTL
+	}
+	return 0
+}
+
+type SessionStateServerAPI_LeaseRequest struct {
+	RemoteServID         string   `protobuf:"bytes,1,opt,name=RemoteServID,proto3" json:"RemoteServID,omitempty"`
+	TTL                  int32    `protobuf:"varint,2,opt,name=TTL,proto3" json:"TTL,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionStateServerAPI_LeaseRequest) Reset()         { *m = SessionStateServerAPI_LeaseRequest{} }
+func (m *SessionStateServerAPI_LeaseRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionStateServerAPI_LeaseRequest) ProtoMessage()    {}
+func (*SessionStateServerAPI_LeaseRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{0, 15}
+}
+
+func (m *SessionStateServerAPI_LeaseRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionStateServerAPI_LeaseRequest.Unmarshal(m, b)
+}
+func (m *SessionStateServerAPI_LeaseRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionStateServerAPI_LeaseRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionStateServerAPI_LeaseRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionStateServerAPI_LeaseRequest.Merge(m, src)
+}
+func (m *SessionStateServerAPI_LeaseRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionStateServerAPI_LeaseRequest.Size(m)
+}
+func (m *SessionStateServerAPI_LeaseRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionStateServerAPI_LeaseRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionStateServerAPI_LeaseRequest proto.InternalMessageInfo
+
+func (m *SessionStateServerAPI_LeaseRequest) GetRemoteServID() string {
+	if m != nil {
+		return m.RemoteServID
+	}
+	return ""
+}
+
+func (m *SessionStateServerAPI_LeaseRequest) GetTTL() int32 {
+	if m != nil {
+		return m.TTL
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*SessionStateServerAPI)(nil), "pb.SessionStateServerAPI")
 	proto.RegisterType((*SessionStateServerAPI_Nil)(nil), "pb.SessionStateServerAPI.Nil")
@@ -367,36 +880,72 @@ func init() {
 	proto.RegisterType((*SessionStateServerAPI_BroadcastRequest)(nil), "pb.SessionStateServerAPI.BroadcastRequest")
 	proto.RegisterType((*SessionStateServerAPI_BroadcastResponse)(nil), "pb.SessionStateServerAPI.BroadcastResponse")
 	proto.RegisterType((*SessionStateServerAPI_NewRequest)(nil), "pb.SessionStateServerAPI.NewRequest")
+	proto.RegisterType((*SessionStateServerAPI_Mutation)(nil), "pb.SessionStateServerAPI.Mutation")
+	proto.RegisterType((*SessionStateServerAPI_GetRequest)(nil), "pb.SessionStateServerAPI.GetRequest")
+	proto.RegisterType((*SessionStateServerAPI_GetResponse)(nil), "pb.SessionStateServerAPI.GetResponse")
+	proto.RegisterType((*SessionStateServerAPI_BatchGetRequest)(nil), "pb.SessionStateServerAPI.BatchGetRequest")
+	proto.RegisterType((*SessionStateServerAPI_FindByParamRequest)(nil), "pb.SessionStateServerAPI.FindByParamRequest")
+	proto.RegisterType((*SessionStateServerAPI_ListRequest)(nil), "pb.SessionStateServerAPI.ListRequest")
+	proto.RegisterType((*SessionStateServerAPI_ListResponse)(nil), "pb.SessionStateServerAPI.ListResponse")
+	proto.RegisterType((*SessionStateServerAPI_TouchRequest)(nil), "pb.SessionStateServerAPI.TouchRequest")
+	proto.RegisterType((*SessionStateServerAPI_LeaseRequest)(nil), "pb.SessionStateServerAPI.LeaseRequest")
 }
 
 func init() { proto.RegisterFile("service.proto", fileDescriptor_a0b84a42fa06f626) }
 
 var fileDescriptor_a0b84a42fa06f626 = []byte{
-	// 373 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x53, 0xc1, 0x6e, 0xda, 0x40,
-	0x14, 0xc4, 0xb8, 0x76, 0xcb, 0x6b, 0x2b, 0xd1, 0xa7, 0xb6, 0xb2, 0x56, 0xaa, 0x8a, 0x50, 0x0f,
-	0x56, 0x2b, 0xb9, 0x15, 0x3d, 0xa0, 0x1e, 0x81, 0xe6, 0x80, 0x92, 0x18, 0x64, 0x4b, 0xb9, 0xe5,
-	0xb0, 0x36, 0x2f, 0x89, 0x15, 0xf0, 0x3a, 0xde, 0xc5, 0x88, 0x6f, 0xcc, 0xc7, 0xe4, 0x17, 0x22,
-	0xd6, 0x80, 0x50, 0x08, 0x88, 0x28, 0xdc, 0xf6, 0x79, 0x67, 0xe6, 0xcd, 0x8c, 0x6d, 0xf8, 0x28,
-	0x29, 0x2f, 0x92, 0x98, 0xbc, 0x2c, 0x17, 0x4a, 0x60, 0x35, 0x8b, 0x9a, 0x0f, 0x26, 0x7c, 0x09,
-	0x49, 0xca, 0x44, 0xa4, 0xa1, 0xe2, 0x8a, 0x42, 0xca, 0x0b, 0xca, 0x3b, 0xc3, 0x3e, 0xb3, 0xc0,
-	0xf4, 0x93, 0x31, 0xfb, 0x0d, 0xd6, 0x90, 0xe7, 0x7c, 0x82, 0x75, 0x30, 0x6f, 0x69, 0xee, 0x18,
-	0x0d, 0xc3, 0xad, 0x05, 0x8b, 0x23, 0x7e, 0x06, 0xeb, 0x82, 0x8f, 0xa7, 0xe4, 0x54, 0xf5, 0xb3,
-	0x72, 0x60, 0x03, 0xb0, 0x4e, 0x0a, 0x4a, 0x15, 0x7e, 0x05, 0xbb, 0x13, 0xab, 0x44, 0xa4, 0x9a,
-	0x63, 0x05, 0xcb, 0x09, 0x19, 0xbc, 0xeb, 0x8d, 0x13, 0x4a, 0x55, 0xff, 0xff, 0x92, 0xb9, 0x9e,
-	0x11, 0xe1, 0x4d, 0x57, 0x8c, 0xe6, 0x8e, 0xd9, 0x30, 0xdc, 0x0f, 0x81, 0x3e, 0xb3, 0x53, 0xa8,
-	0x6b, 0xc1, 0xde, 0x0d, 0x4f, 0xaf, 0xa9, 0x34, 0xd3, 0x06, 0x5b, 0x6f, 0x93, 0x8e, 0xd1, 0x30,
-	0xdd, 0xf7, 0xad, 0xef, 0x5e, 0x16, 0x79, 0xcf, 0xe6, 0xf0, 0x34, 0x21, 0x58, 0xc2, 0xd9, 0x39,
-	0xd4, 0xbb, 0xb9, 0xe0, 0xa3, 0x98, 0x4b, 0x15, 0xd0, 0xdd, 0x94, 0xa4, 0xc2, 0x7f, 0xf0, 0xb6,
-	0xb4, 0x76, 0x80, 0x9a, 0x76, 0x12, 0xac, 0xf0, 0xcc, 0x87, 0x4f, 0x1b, 0x72, 0x32, 0x13, 0xa9,
-	0xa4, 0xd7, 0xe8, 0x71, 0x00, 0x9f, 0x66, 0x2b, 0x63, 0x9b, 0x4d, 0x19, 0x4f, 0x9a, 0x6a, 0x83,
-	0xad, 0x93, 0x49, 0xa7, 0x7a, 0x60, 0x03, 0x25, 0xbc, 0x75, 0x6f, 0x02, 0x6e, 0xe3, 0xf0, 0x12,
-	0x6a, 0xe1, 0x34, 0x92, 0x71, 0x9e, 0x44, 0x84, 0xdf, 0x76, 0x8b, 0x2d, 0xbe, 0x89, 0x5f, 0xbb,
-	0xaf, 0xb7, 0xda, 0x68, 0x56, 0x5c, 0xe3, 0x8f, 0x81, 0x57, 0x50, 0x5b, 0x5f, 0xe1, 0xcf, 0x83,
-	0xf8, 0xba, 0x83, 0x17, 0xee, 0xc2, 0x33, 0x30, 0x7d, 0x9a, 0xe1, 0x8f, 0x3d, 0x01, 0xd6, 0xfd,
-	0xb2, 0xfd, 0x31, 0x9b, 0x15, 0x1c, 0x80, 0x1d, 0xd0, 0x44, 0x14, 0x74, 0x44, 0xc1, 0xf2, 0x35,
-	0x1c, 0x49, 0x30, 0xb2, 0xf5, 0xaf, 0xfc, 0xf7, 0x31, 0x00, 0x00, 0xff, 0xff, 0x46, 0xab, 0xc8,
-	0x8e, 0xdb, 0x03, 0x00, 0x00,
+	// 805 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xdd, 0x6e, 0xda, 0x4a,
+	0x10, 0x8e, 0x31, 0x06, 0x3c, 0x70, 0xce, 0xe1, 0xac, 0xce, 0x89, 0xac, 0x55, 0xce, 0x29, 0x42,
+	0x4d, 0x44, 0x7f, 0x44, 0xab, 0xf4, 0xa2, 0xaa, 0x2a, 0x45, 0x85, 0x90, 0x46, 0x28, 0x24, 0x45,
+	0x0b, 0x4a, 0xaf, 0xaa, 0xca, 0xc0, 0xb4, 0xb1, 0x0a, 0xd8, 0xb5, 0x17, 0x92, 0xbc, 0x44, 0x1f,
+	0xa2, 0x8f, 0xd1, 0xf7, 0xe8, 0xa3, 0xf4, 0xbe, 0xda, 0xb5, 0x8d, 0x1d, 0x7e, 0x9d, 0x36, 0x77,
+	0x3b, 0xe3, 0x99, 0x6f, 0x67, 0xbe, 0x99, 0xfd, 0x64, 0xf8, 0xc3, 0x43, 0x77, 0x6a, 0xf5, 0xb1,
+	0xea, 0xb8, 0x36, 0xb7, 0x49, 0xca, 0xe9, 0x95, 0xbf, 0x16, 0xe0, 0xdf, 0x0e, 0x7a, 0x9e, 0x65,
+	0x8f, 0x3b, 0xdc, 0xe4, 0xd8, 0x41, 0x77, 0x8a, 0x6e, 0xad, 0xdd, 0xa4, 0x1a, 0xa8, 0x67, 0xd6,
+	0x90, 0x36, 0x41, 0x6b, 0x9b, 0xae, 0x39, 0x22, 0x45, 0x50, 0x3f, 0xe1, 0xb5, 0xa1, 0x94, 0x94,
+	0x8a, 0xce, 0xc4, 0x91, 0xfc, 0x03, 0xda, 0xb9, 0x39, 0x9c, 0xa0, 0x91, 0x92, 0x3e, 0xdf, 0x20,
+	0x06, 0x64, 0xcf, 0xd1, 0x15, 0x80, 0x86, 0x5a, 0x52, 0x2a, 0x2a, 0x0b, 0x4d, 0x3a, 0x02, 0xed,
+	0x68, 0x8a, 0x63, 0x4e, 0xb6, 0x21, 0x53, 0xeb, 0x73, 0x11, 0x21, 0xd0, 0x34, 0x16, 0x58, 0x84,
+	0x42, 0xee, 0x70, 0x68, 0xe1, 0x98, 0x37, 0x1b, 0x01, 0xe6, 0xcc, 0x26, 0x04, 0xd2, 0x75, 0x7b,
+	0x70, 0x2d, 0x31, 0x0b, 0x4c, 0x9e, 0xc9, 0x0e, 0xe8, 0x1d, 0xbf, 0xa3, 0x66, 0xc3, 0x48, 0x4b,
+	0xa8, 0xc8, 0x41, 0x4f, 0xa0, 0x28, 0xaf, 0x3b, 0xbc, 0x30, 0xc7, 0x1f, 0xd1, 0x6f, 0xe2, 0x39,
+	0x64, 0x64, 0x95, 0x9e, 0xa1, 0x94, 0xd4, 0x4a, 0x7e, 0xff, 0x5e, 0xd5, 0xe9, 0x55, 0x97, 0xf6,
+	0x5f, 0x95, 0x09, 0x2c, 0x08, 0xa7, 0xa7, 0x50, 0xac, 0xbb, 0xb6, 0x39, 0xe8, 0x9b, 0x1e, 0x67,
+	0xf8, 0x79, 0x82, 0x1e, 0x27, 0x2f, 0x20, 0xeb, 0x17, 0x9e, 0x00, 0x4d, 0x56, 0xc2, 0xc2, 0x78,
+	0x3a, 0x84, 0xbf, 0x63, 0x70, 0x9e, 0x63, 0x8f, 0x3d, 0xfc, 0x0d, 0x3c, 0xc1, 0x44, 0x03, 0x87,
+	0xd6, 0x14, 0x5d, 0x1c, 0x18, 0xa9, 0x92, 0x2a, 0x98, 0x98, 0x39, 0xe8, 0x0f, 0x05, 0xe0, 0x0c,
+	0x2f, 0xc3, 0xba, 0xe3, 0x34, 0x2b, 0x73, 0x34, 0x53, 0xc8, 0x31, 0x1c, 0xd9, 0x1c, 0xa3, 0x11,
+	0x84, 0x36, 0xd9, 0x83, 0x3f, 0xfd, 0xb3, 0x28, 0xa3, 0x36, 0x18, 0xb8, 0x72, 0x18, 0x3a, 0x9b,
+	0xf3, 0x92, 0x32, 0x14, 0x22, 0x4f, 0x30, 0x19, 0x9d, 0xdd, 0xf0, 0x89, 0x41, 0x48, 0x82, 0x3d,
+	0x23, 0x93, 0x70, 0x10, 0x7e, 0xb8, 0x58, 0xc3, 0x6e, 0xb7, 0x65, 0x64, 0xe5, 0xb4, 0xc5, 0x31,
+	0xbe, 0x70, 0xb9, 0x9b, 0x0b, 0xf7, 0x5d, 0x81, 0xdc, 0xe9, 0x84, 0x9b, 0x72, 0xb9, 0x56, 0x2d,
+	0xdd, 0x01, 0x64, 0x83, 0x7b, 0x65, 0xc3, 0xf9, 0xfd, 0xfb, 0xab, 0x4b, 0x89, 0x48, 0x64, 0x61,
+	0x92, 0xa0, 0xbe, 0x6b, 0x8d, 0xd0, 0xe3, 0xe6, 0xc8, 0x09, 0x36, 0x3e, 0x72, 0x88, 0x3e, 0xe5,
+	0xa8, 0x3c, 0x23, 0x9d, 0x6c, 0xa4, 0x41, 0xb8, 0x18, 0xc4, 0xd1, 0x95, 0x63, 0xb9, 0x58, 0xe3,
+	0x86, 0x26, 0x51, 0x67, 0x36, 0xad, 0x00, 0x1c, 0x23, 0x4f, 0x30, 0x4e, 0x7a, 0x0a, 0xf9, 0x63,
+	0x8c, 0x36, 0x2c, 0xd6, 0xab, 0xf2, 0x0b, 0xbd, 0xd2, 0x27, 0xf0, 0x57, 0xdd, 0xe4, 0xfd, 0x8b,
+	0xd8, 0xed, 0x3b, 0xa0, 0x87, 0xb7, 0xf9, 0x6b, 0xab, 0xb3, 0xc8, 0x41, 0xbf, 0x28, 0x40, 0x5e,
+	0x5b, 0xe3, 0x41, 0xfd, 0xda, 0x9f, 0x62, 0x90, 0x54, 0x04, 0xf5, 0x24, 0xd2, 0x92, 0x93, 0x95,
+	0x5a, 0x32, 0xbf, 0x49, 0xea, 0x92, 0x4d, 0xda, 0x86, 0xcc, 0xe1, 0xc4, 0xf5, 0x6c, 0x37, 0xd8,
+	0xb3, 0xc0, 0x12, 0x88, 0x2d, 0x6b, 0x64, 0xf9, 0xec, 0x69, 0xcc, 0x37, 0xe8, 0x4b, 0xc8, 0xb7,
+	0xac, 0xe8, 0x09, 0x47, 0xc9, 0xca, 0xf2, 0xe4, 0x54, 0x3c, 0xd9, 0x81, 0x82, 0x9f, 0x1c, 0xd0,
+	0xf9, 0x0a, 0x72, 0x01, 0x33, 0xe1, 0x8b, 0x4d, 0xc6, 0xe7, 0x2c, 0x8b, 0xfc, 0x2f, 0x1e, 0xe6,
+	0x15, 0x0f, 0x6a, 0xf0, 0x7b, 0x8f, 0x79, 0xe8, 0x01, 0x14, 0xba, 0xf6, 0xa4, 0x7f, 0x91, 0x88,
+	0xed, 0xf0, 0x6d, 0xa4, 0x66, 0x6f, 0x83, 0x36, 0xa0, 0xd0, 0x42, 0xd3, 0xc3, 0x30, 0x7f, 0x9e,
+	0x50, 0x65, 0x09, 0xa1, 0x0b, 0x28, 0xfb, 0xdf, 0x72, 0x40, 0x16, 0x9b, 0x22, 0xef, 0x40, 0xef,
+	0x4c, 0x7a, 0x5e, 0xdf, 0xb5, 0x7a, 0x48, 0xfe, 0x5b, 0xd3, 0xb9, 0x35, 0xa4, 0x8f, 0x56, 0x7f,
+	0x5e, 0x10, 0xc2, 0xf2, 0x56, 0x45, 0x79, 0xaa, 0x90, 0x0f, 0xa0, 0xcf, 0x3e, 0x91, 0x87, 0x89,
+	0xf2, 0x65, 0x93, 0xb7, 0xbc, 0x8b, 0xb4, 0x40, 0x3d, 0xc3, 0x4b, 0x92, 0x68, 0x74, 0x74, 0x7d,
+	0x9b, 0xe5, 0x2d, 0xf2, 0x06, 0x32, 0x82, 0xcd, 0x29, 0xde, 0x21, 0x60, 0x20, 0x7d, 0x77, 0x04,
+	0xc8, 0x40, 0x67, 0xe8, 0x0c, 0xad, 0xbe, 0xc9, 0x91, 0x94, 0x57, 0x47, 0x87, 0xca, 0xb9, 0x11,
+	0xb1, 0xa2, 0x90, 0x2e, 0xa8, 0xc7, 0xc8, 0xd7, 0x55, 0x18, 0x49, 0x06, 0xdd, 0xdd, 0x10, 0x35,
+	0x9b, 0xcc, 0x7b, 0xc8, 0x85, 0x72, 0x43, 0x1e, 0xac, 0x19, 0xea, 0x4d, 0x49, 0xa2, 0x7b, 0xab,
+	0x43, 0xe3, 0xcf, 0xb7, 0xbc, 0x45, 0x10, 0xf2, 0x31, 0x75, 0x22, 0x8f, 0x57, 0x27, 0x2e, 0x8a,
+	0xd8, 0x2d, 0xae, 0x79, 0x0b, 0x69, 0xe1, 0x21, 0xbb, 0x9b, 0x32, 0x6e, 0x0b, 0xdc, 0x06, 0x4d,
+	0xca, 0x03, 0x59, 0x93, 0x12, 0xd7, 0x8f, 0xcd, 0xcb, 0xd1, 0x06, 0x4d, 0x0a, 0xc6, 0x3a, 0xc4,
+	0xb8, 0xa2, 0x6c, 0x44, 0xec, 0x65, 0xe4, 0xcf, 0xe6, 0xb3, 0x9f, 0x03, 0x00, 0x06, 0x3f, 0x83,
+	0x98, 0x7d, 0x0a, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -416,6 +965,13 @@ type SessionStateServerClient interface {
 	New(ctx context.Context, in *SessionStateServerAPI_NewRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error)
 	Remove(ctx context.Context, in *SessionStateServerAPI_NewRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error)
 	Params(ctx context.Context, in *SessionStateServerAPI_NewRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error)
+	Replicate(ctx context.Context, opts ...grpc.CallOption) (SessionStateServer_ReplicateClient, error)
+	Get(ctx context.Context, in *SessionStateServerAPI_GetRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_GetResponse, error)
+	BatchGet(ctx context.Context, in *SessionStateServerAPI_BatchGetRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error)
+	FindByParam(ctx context.Context, in *SessionStateServerAPI_FindByParamRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error)
+	List(ctx context.Context, in *SessionStateServerAPI_ListRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error)
+	Touch(ctx context.Context, in *SessionStateServerAPI_TouchRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error)
+	Lease(ctx context.Context, in *SessionStateServerAPI_LeaseRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error)
 }
 
 type sessionStateServerClient struct {
@@ -493,6 +1049,94 @@ func (c *sessionStateServerClient) Params(ctx context.Context, in *SessionStateS
 	return out, nil
 }
 
+func (c *sessionStateServerClient) Replicate(ctx context.Context, opts ...grpc.CallOption) (SessionStateServer_ReplicateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SessionStateServer_serviceDesc.Streams[1], "/pb.SessionStateServer/Replicate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sessionStateServerReplicateClient{stream}
+	return x, nil
+}
+
+type SessionStateServer_ReplicateClient interface {
+	Send(*SessionStateServerAPI_Mutation) error
+	CloseAndRecv() (*SessionStateServerAPI_Nil, error)
+	grpc.ClientStream
+}
+
+type sessionStateServerReplicateClient struct {
+	grpc.ClientStream
+}
+
+func (x *sessionStateServerReplicateClient) Send(m *SessionStateServerAPI_Mutation) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *sessionStateServerReplicateClient) CloseAndRecv() (*SessionStateServerAPI_Nil, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(SessionStateServerAPI_Nil)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sessionStateServerClient) Get(ctx context.Context, in *SessionStateServerAPI_GetRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_GetResponse, error) {
+	out := new(SessionStateServerAPI_GetResponse)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionStateServerClient) BatchGet(ctx context.Context, in *SessionStateServerAPI_BatchGetRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error) {
+	out := new(SessionStateServerAPI_ListResponse)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/BatchGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionStateServerClient) FindByParam(ctx context.Context, in *SessionStateServerAPI_FindByParamRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error) {
+	out := new(SessionStateServerAPI_ListResponse)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/FindByParam", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionStateServerClient) List(ctx context.Context, in *SessionStateServerAPI_ListRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_ListResponse, error) {
+	out := new(SessionStateServerAPI_ListResponse)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionStateServerClient) Touch(ctx context.Context, in *SessionStateServerAPI_TouchRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error) {
+	out := new(SessionStateServerAPI_Nil)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/Touch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionStateServerClient) Lease(ctx context.Context, in *SessionStateServerAPI_LeaseRequest, opts ...grpc.CallOption) (*SessionStateServerAPI_Nil, error) {
+	out := new(SessionStateServerAPI_Nil)
+	err := c.cc.Invoke(ctx, "/pb.SessionStateServer/Lease", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SessionStateServerServer is the server API for SessionStateServer service.
 type SessionStateServerServer interface {
 	Subscribe(SessionStateServer_SubscribeServer) error
@@ -500,6 +1144,13 @@ type SessionStateServerServer interface {
 	New(context.Context, *SessionStateServerAPI_NewRequest) (*SessionStateServerAPI_Nil, error)
 	Remove(context.Context, *SessionStateServerAPI_NewRequest) (*SessionStateServerAPI_Nil, error)
 	Params(context.Context, *SessionStateServerAPI_NewRequest) (*SessionStateServerAPI_Nil, error)
+	Replicate(SessionStateServer_ReplicateServer) error
+	Get(context.Context, *SessionStateServerAPI_GetRequest) (*SessionStateServerAPI_GetResponse, error)
+	BatchGet(context.Context, *SessionStateServerAPI_BatchGetRequest) (*SessionStateServerAPI_ListResponse, error)
+	FindByParam(context.Context, *SessionStateServerAPI_FindByParamRequest) (*SessionStateServerAPI_ListResponse, error)
+	List(context.Context, *SessionStateServerAPI_ListRequest) (*SessionStateServerAPI_ListResponse, error)
+	Touch(context.Context, *SessionStateServerAPI_TouchRequest) (*SessionStateServerAPI_Nil, error)
+	Lease(context.Context, *SessionStateServerAPI_LeaseRequest) (*SessionStateServerAPI_Nil, error)
 }
 
 // UnimplementedSessionStateServerServer can be embedded to have forward compatible implementations.
@@ -521,6 +1172,27 @@ func (*UnimplementedSessionStateServerServer) Remove(ctx context.Context, req *S
 func (*UnimplementedSessionStateServerServer) Params(ctx context.Context, req *SessionStateServerAPI_NewRequest) (*SessionStateServerAPI_Nil, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Params not implemented")
 }
+func (*UnimplementedSessionStateServerServer) Replicate(srv SessionStateServer_ReplicateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+func (*UnimplementedSessionStateServerServer) Get(ctx context.Context, req *SessionStateServerAPI_GetRequest) (*SessionStateServerAPI_GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedSessionStateServerServer) BatchGet(ctx context.Context, req *SessionStateServerAPI_BatchGetRequest) (*SessionStateServerAPI_ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (*UnimplementedSessionStateServerServer) FindByParam(ctx context.Context, req *SessionStateServerAPI_FindByParamRequest) (*SessionStateServerAPI_ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindByParam not implemented")
+}
+func (*UnimplementedSessionStateServerServer) List(ctx context.Context, req *SessionStateServerAPI_ListRequest) (*SessionStateServerAPI_ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (*UnimplementedSessionStateServerServer) Touch(ctx context.Context, req *SessionStateServerAPI_TouchRequest) (*SessionStateServerAPI_Nil, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Touch not implemented")
+}
+func (*UnimplementedSessionStateServerServer) Lease(ctx context.Context, req *SessionStateServerAPI_LeaseRequest) (*SessionStateServerAPI_Nil, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lease not implemented")
+}
 
 func RegisterSessionStateServerServer(s *grpc.Server, srv SessionStateServerServer) {
 	s.RegisterService(&_SessionStateServer_serviceDesc, srv)
@@ -624,6 +1296,140 @@ func _SessionStateServer_Params_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SessionStateServer_Replicate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SessionStateServerServer).Replicate(&sessionStateServerReplicateServer{stream})
+}
+
+type SessionStateServer_ReplicateServer interface {
+	SendAndClose(*SessionStateServerAPI_Nil) error
+	Recv() (*SessionStateServerAPI_Mutation, error)
+	grpc.ServerStream
+}
+
+type sessionStateServerReplicateServer struct {
+	grpc.ServerStream
+}
+
+func (x *sessionStateServerReplicateServer) SendAndClose(m *SessionStateServerAPI_Nil) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *sessionStateServerReplicateServer) Recv() (*SessionStateServerAPI_Mutation, error) {
+	m := new(SessionStateServerAPI_Mutation)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _SessionStateServer_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).Get(ctx, req.(*SessionStateServerAPI_GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SessionStateServer_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/BatchGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).BatchGet(ctx, req.(*SessionStateServerAPI_BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SessionStateServer_FindByParam_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_FindByParamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).FindByParam(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/FindByParam",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).FindByParam(ctx, req.(*SessionStateServerAPI_FindByParamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SessionStateServer_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).List(ctx, req.(*SessionStateServerAPI_ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SessionStateServer_Touch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_TouchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).Touch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/Touch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).Touch(ctx, req.(*SessionStateServerAPI_TouchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SessionStateServer_Lease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionStateServerAPI_LeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SessionStateServerServer).Lease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionStateServer/Lease",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SessionStateServerServer).Lease(ctx, req.(*SessionStateServerAPI_LeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SessionStateServer_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.SessionStateServer",
 	HandlerType: (*SessionStateServerServer)(nil),
@@ -644,6 +1450,30 @@ var _SessionStateServer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Params",
 			Handler:    _SessionStateServer_Params_Handler,
 		},
+		{
+			MethodName: "Get",
+			Handler:    _SessionStateServer_Get_Handler,
+		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _SessionStateServer_BatchGet_Handler,
+		},
+		{
+			MethodName: "FindByParam",
+			Handler:    _SessionStateServer_FindByParam_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _SessionStateServer_List_Handler,
+		},
+		{
+			MethodName: "Touch",
+			Handler:    _SessionStateServer_Touch_Handler,
+		},
+		{
+			MethodName: "Lease",
+			Handler:    _SessionStateServer_Lease_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -652,6 +1482,11 @@ var _SessionStateServer_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Replicate",
+			Handler:       _SessionStateServer_Replicate_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "service.proto",
 }
@@ -24,4 +24,25 @@ type GRPC interface {
 
 	// Params 参数修改
 	Params(context.Context, *pb.SessionStateServerAPI_NewRequest) (*pb.SessionStateServerAPI_Nil, error)
+
+	// Replicate 集群节点之间同步连接状态
+	Replicate(context.Context, pb.SessionStateServer_ReplicateServer) error
+
+	// Get 查询连接信息
+	Get(context.Context, *pb.SessionStateServerAPI_GetRequest) (*pb.SessionStateServerAPI_GetResponse, error)
+
+	// BatchGet 批量查询连接信息
+	BatchGet(context.Context, *pb.SessionStateServerAPI_BatchGetRequest) (*pb.SessionStateServerAPI_ListResponse, error)
+
+	// FindByParam 按参数查找连接
+	FindByParam(context.Context, *pb.SessionStateServerAPI_FindByParamRequest) (*pb.SessionStateServerAPI_ListResponse, error)
+
+	// List 分页查询所有连接
+	List(context.Context, *pb.SessionStateServerAPI_ListRequest) (*pb.SessionStateServerAPI_ListResponse, error)
+
+	// Touch 心跳, 延长连接的过期时间
+	Touch(context.Context, *pb.SessionStateServerAPI_TouchRequest) (*pb.SessionStateServerAPI_Nil, error)
+
+	// Lease 服务租约
+	Lease(context.Context, *pb.SessionStateServerAPI_LeaseRequest) (*pb.SessionStateServerAPI_Nil, error)
 }
@@ -1,13 +1,18 @@
 package session
 
 import (
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Param struct {
 	Key   string
 	Value string
+
+	// Version 修改时间戳, 集群同步时使用
+	Version int64
 }
 
 // Client 连接信息
@@ -16,20 +21,113 @@ type Client struct {
 	// id 连接唯一id
 	id string
 
+	// remoteID 远程服务识别ID
+	remoteID string
+
+	// remoteServAddr 远程服务地址
+	remoteServAddr string
+
+	// remoteServID 远程服务ID
+	remoteServID string
+
+	// version 创建时间戳, 集群同步时按时间戳后写优先
+	version int64
+
+	// ttl 过期时间, 0为不过期
+	ttl time.Duration
+
+	// expireAt 过期时间(本地时间UnixNano), 0为不过期
+	// 与version不同, 不受集群同步时间戳的影响
+	expireAt int64
+
 	// params 参数
 	params atomic.Value
 
+	// paramVersions 参数的修改时间戳, 包含已经删除的参数
+	paramVersions map[string]int64
+
+	// clock 产生本地修改的时间戳
+	clock *Clock
+
+	// expiry 存储的过期索引, 第一次设置过期时间时加入
+	expiry *expiryQueue
+
 	// lock
 	mutex sync.Mutex
 }
 
 // ID ...
-func (s Client) ID() string {
+func (s *Client) ID() string {
 	return s.id
 }
 
+// Version 创建时间戳
+func (s *Client) Version() int64 {
+	return s.version
+}
+
+// SetTTL 设置过期时间, 0为不过期
+func (s *Client) SetTTL(ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ttl = ttl
+}
+
+// TTL 过期时间
+func (s *Client) TTL() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ttl
+}
+
+// Touch 延长过期时间, 只会延长不会缩短
+func (s *Client) Touch(expireAt int64) {
+	for {
+		old := atomic.LoadInt64(&s.expireAt)
+		if expireAt <= old {
+			return
+		}
+
+		if atomic.CompareAndSwapInt64(&s.expireAt, old, expireAt) {
+			if old == 0 && s.expiry != nil {
+				s.expiry.push(s, expireAt)
+			}
+			return
+		}
+	}
+}
+
+// ExpireAt 过期时间戳, 0为不过期
+func (s *Client) ExpireAt() int64 {
+	return atomic.LoadInt64(&s.expireAt)
+}
+
+// Expired 是否已经过期
+func (s *Client) Expired(now int64) bool {
+	expireAt := s.ExpireAt()
+	return expireAt > 0 && expireAt < now
+}
+
+// SetRemote 设置连接所在的服务
+func (s *Client) SetRemote(remoteID, remoteServAddr, remoteServID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.remoteID = remoteID
+	s.remoteServAddr = remoteServAddr
+	s.remoteServID = remoteServID
+}
+
+// Remote 连接所在的服务
+func (s *Client) Remote() (remoteID, remoteServAddr, remoteServID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.remoteID, s.remoteServAddr, s.remoteServID
+}
+
 // SetParam 设置session数据
-func (s *Client) SetParam(params ...*Param) {
+// 返回值发生了变化的参数
+func (s *Client) SetParam(params ...*Param) []*Param {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -39,14 +137,24 @@ func (s *Client) SetParam(params ...*Param) {
 		m2[k] = v
 	}
 
+	ts := s.now()
+	changed := make([]*Param, 0, len(params))
 	for _, v := range params {
+		s.paramVersions[v.Key] = ts
+		if old, ok := m2[v.Key]; ok && old == v.Value {
+			continue
+		}
+
 		m2[v.Key] = v.Value
+		changed = append(changed, v)
 	}
 	s.params.Store(m2)
+	return changed
 }
 
 // RemoveParam 设置session数据
-func (s *Client) RemoveParam(keys ...string) {
+// 返回实际删除的key
+func (s *Client) RemoveParam(keys ...string) []string {
 	mkeys := make(map[string]bool)
 	for _, k := range keys {
 		mkeys[k] = true
@@ -55,15 +163,23 @@ func (s *Client) RemoveParam(keys ...string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	ts := s.now()
+	for k := range mkeys {
+		s.paramVersions[k] = ts
+	}
+
 	m1 := s.params.Load().(map[string]string)
 	m2 := make(map[string]string)
+	removed := make([]string, 0, len(keys))
 	for k, v := range m1 {
 		if mkeys[k] {
+			removed = append(removed, k)
 			continue
 		}
 		m2[k] = v
 	}
 	s.params.Store(m2)
+	return removed
 }
 
 // Param 获取session数据
@@ -72,3 +188,102 @@ func (s *Client) Param(key string) (string, bool) {
 	v, ok := m[key]
 	return v, ok
 }
+
+// Params 获取所有session数据, 返回的map不能修改
+func (s *Client) Params() map[string]string {
+	return s.params.Load().(map[string]string)
+}
+
+// ApplyParams 按修改时间戳合并参数, 后写优先, Value为空的参数将被删除
+// 参数的Version为0时使用ts, 早于连接创建时间的修改将被忽略.
+// 返回值发生了变化的参数, 被删除的参数Value为空
+func (s *Client) ApplyParams(ts int64, params ...*Param) []*Param {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.clock != nil {
+		s.clock.Observe(ts)
+	}
+
+	m1 := s.params.Load().(map[string]string)
+	m2 := make(map[string]string)
+	for k, v := range m1 {
+		m2[k] = v
+	}
+
+	changed := make([]*Param, 0, len(params))
+	for _, v := range params {
+		version := v.Version
+		if version == 0 {
+			version = ts
+		}
+
+		if version < s.version {
+			continue
+		}
+
+		// 时间戳相同时按值的大小决定, 保证各个节点的结果一致
+		old, ok := m2[v.Key]
+		if last := s.paramVersions[v.Key]; last > version || (last == version && v.Value <= old) {
+			continue
+		}
+
+		s.paramVersions[v.Key] = version
+		if v.Value == "" {
+			if ok {
+				delete(m2, v.Key)
+				changed = append(changed, &Param{Key: v.Key})
+			}
+			continue
+		}
+
+		if ok && old == v.Value {
+			continue
+		}
+
+		m2[v.Key] = v.Value
+		changed = append(changed, &Param{Key: v.Key, Value: v.Value})
+	}
+
+	s.params.Store(m2)
+	return changed
+}
+
+// VersionedParams 带修改时间戳的所有参数, 已删除的参数Value为空, 按key排序
+// 用于集群中节点之间的全量同步
+func (s *Client) VersionedParams() []*Param {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m := s.params.Load().(map[string]string)
+	params := make([]*Param, 0, len(s.paramVersions))
+	for k, version := range s.paramVersions {
+		params = append(params, &Param{Key: k, Value: m[k], Version: version})
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Key < params[j].Key
+	})
+	return params
+}
+
+func (s *Client) now() int64 {
+	if s.clock == nil {
+		return time.Now().UnixNano()
+	}
+
+	return s.clock.Now()
+}
+
+func newClient(id string, version int64, clock *Clock, expiry *expiryQueue) *Client {
+	s := &Client{
+		id:            id,
+		version:       version,
+		paramVersions: make(map[string]int64),
+		clock:         clock,
+		expiry:        expiry,
+	}
+
+	s.params.Store(make(map[string]string))
+	return s
+}
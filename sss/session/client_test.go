@@ -0,0 +1,213 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientParams(t *testing.T) {
+	store := NewStore()
+	client := store.NewClient("c1")
+
+	changed := client.SetParam(&Param{Key: "UserID", Value: "10001"}, &Param{Key: "Room", Value: "1"})
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 changed params, got %d", len(changed))
+	}
+
+	// 值没有变化的参数不计入
+	changed = client.SetParam(&Param{Key: "UserID", Value: "10001"}, &Param{Key: "Room", Value: "2"})
+	if len(changed) != 1 || changed[0].Key != "Room" {
+		t.Fatalf("expected Room changed, got %v", changed)
+	}
+
+	if removed := client.RemoveParam("Room", "None"); !reflect.DeepEqual(removed, []string{"Room"}) {
+		t.Fatalf("expected Room removed, got %v", removed)
+	}
+
+	if !reflect.DeepEqual(client.Params(), map[string]string{"UserID": "10001"}) {
+		t.Fatalf("unexpected params %v", client.Params())
+	}
+
+	if m := store.Remove("c1"); m != client || store.Get("c1") != nil || store.Remove("c1") != nil {
+		t.Fatal("unexpected remove result")
+	}
+}
+
+func TestStoreApply(t *testing.T) {
+	store := NewStore()
+	client, ok := store.ApplyNew("c1", 100)
+	if !ok || client.Version() != 100 {
+		t.Fatal("expected c1 created")
+	}
+
+	// 同一个连接再次同步时合并参数
+	if m, ok := store.ApplyNew("c1", 100); ok || m != client {
+		t.Fatal("expected c1 merged")
+	}
+
+	changed := client.ApplyParams(110, &Param{Key: "Room", Value: "1"}, &Param{Key: "UserID", Value: "10001", Version: 105})
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 changed params, got %v", changed)
+	}
+
+	// 旧的修改不能覆盖新的修改, 早于连接创建的修改被忽略
+	if changed := client.ApplyParams(108, &Param{Key: "Room", Value: "2"}, &Param{Key: "Token", Value: "x", Version: 90}); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+
+	if changed := client.ApplyParams(120, &Param{Key: "Room"}); len(changed) != 1 || changed[0].Value != "" {
+		t.Fatalf("expected Room removed, got %v", changed)
+	}
+
+	if !reflect.DeepEqual(client.Params(), map[string]string{"UserID": "10001"}) {
+		t.Fatalf("unexpected params %v", client.Params())
+	}
+
+	expected := []*Param{{Key: "Room", Version: 120}, {Key: "UserID", Value: "10001", Version: 105}}
+	if params := client.VersionedParams(); !reflect.DeepEqual(params, expected) {
+		t.Fatalf("unexpected versioned params %v", params)
+	}
+
+	// 旧的删除不能删除新的连接
+	if store.ApplyRemove("c1", 99) != nil || store.Get("c1") == nil {
+		t.Fatal("expected stale remove ignored")
+	}
+
+	if store.ApplyRemove("c1", 130) != client {
+		t.Fatal("expected c1 removed")
+	}
+
+	// 删除之前的创建不能恢复连接
+	if m, _ := store.ApplyNew("c1", 125); m != nil || store.Get("c1") != nil {
+		t.Fatal("expected stale new ignored")
+	}
+
+	if n := store.Compact(131); n != 1 || len(store.Tombstones()) != 0 {
+		t.Fatalf("expected tombstone compacted, got %d", n)
+	}
+
+	// 本地时钟不能落后于其它节点的时间戳
+	future := time.Now().Add(time.Hour).UnixNano()
+	store.ApplyRemove("c2", future)
+	if store.Now() <= future {
+		t.Fatal("expected clock after observed timestamp")
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	store := NewStore()
+	for _, id := range []string{"c3", "c1", "c4", "c2"} {
+		store.NewClient(id).SetParam(&Param{Key: "Room", Value: id[1:]})
+	}
+
+	var ids []string
+	cursor := ""
+	for {
+		clients, next := store.List(cursor, 3, nil)
+		for _, c := range clients {
+			ids = append(ids, c.ID())
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if !reflect.DeepEqual(ids, []string{"c1", "c2", "c3", "c4"}) {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	clients, next := store.List("", 0, func(c *Client) bool {
+		v, _ := c.Param("Room")
+		return v > "2"
+	})
+
+	if len(clients) != 2 || clients[0].ID() != "c3" || next != "" {
+		t.Fatalf("unexpected clients %v, %s", clients, next)
+	}
+}
+
+func TestStoreExpire(t *testing.T) {
+	store := NewStore()
+	c1 := store.NewClient("c1")
+	c1.Touch(100)
+	c1.Touch(90)
+	if c1.ExpireAt() != 100 {
+		t.Fatalf("expected expire at 100, got %d", c1.ExpireAt())
+	}
+
+	store.NewClient("c2").SetRemote("", "", "agent1")
+	store.NewClient("c3").SetRemote("", "", "agent2")
+	store.Lease("agent1", 150)
+	store.Lease("agent2", 300)
+
+	if clients := store.Expire(99, 0); len(clients) != 0 {
+		t.Fatalf("expected no expired clients, got %d", len(clients))
+	}
+
+	if clients := store.Expire(101, 0); len(clients) != 1 || clients[0] != c1 {
+		t.Fatalf("expected c1 expired, got %v", clients)
+	}
+
+	// 租约过期时该服务的连接同时过期
+	store.Remove("c1")
+	clients := store.Expire(200, 0)
+	if len(clients) != 1 || clients[0].ID() != "c2" {
+		t.Fatalf("expected c2 expired, got %v", clients)
+	}
+
+	if !reflect.DeepEqual(store.Leases(), map[string]int64{"agent2": 300}) {
+		t.Fatalf("unexpected leases %v", store.Leases())
+	}
+}
+
+func TestStoreExpireGrace(t *testing.T) {
+	store := NewStore()
+	c1 := store.NewClient("c1")
+	c1.SetRemote("", "", "agent1")
+	c1.Touch(100)
+
+	c2 := store.NewClient("c2")
+	c2.SetRemote("", "", "agent2")
+	c2.Touch(100)
+
+	// agent1的续约由本节点处理, agent2的续约来自其它节点
+	store.Lease("agent1", 1000)
+	store.ApplyLease("agent1", 1000)
+	store.ApplyLease("agent2", 1000)
+
+	if clients := store.Expire(101, 100); len(clients) != 1 || clients[0] != c1 {
+		t.Fatalf("expected c1 expired, got %v", clients)
+	}
+
+	store.Remove("c1")
+
+	// 宽限期内的续约仍然有效
+	c2.Touch(250)
+	if clients := store.Expire(300, 100); len(clients) != 0 {
+		t.Fatalf("expected no expired clients, got %v", clients)
+	}
+
+	if clients := store.Expire(351, 100); len(clients) != 1 || clients[0] != c2 {
+		t.Fatalf("expected c2 expired, got %v", clients)
+	}
+
+	store.Remove("c2")
+
+	// 其它节点更新了续约, 本节点不再是所有者
+	c3 := store.NewClient("c3")
+	c3.SetRemote("", "", "agent1")
+	c3.Touch(500)
+	store.ApplyLease("agent1", 3000)
+	if clients := store.Expire(501, 100); len(clients) != 0 {
+		t.Fatalf("expected no expired clients, got %v", clients)
+	}
+
+	if clients := store.Expire(601, 100); len(clients) != 1 || clients[0] != c3 {
+		t.Fatalf("expected c3 expired, got %v", clients)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock 混合逻辑时钟
+// 产生的时间戳单调递增, 并且大于已经见过的其它节点的时间戳,
+// 节点之间的时钟误差不会导致本地新的修改被其它节点旧的修改覆盖.
+// 只用于后写优先的版本, 过期时间使用本地时间
+type Clock struct {
+	last int64
+}
+
+// Now 返回新的时间戳(纳秒)
+func (c *Clock) Now() int64 {
+	for {
+		last := atomic.LoadInt64(&c.last)
+		now := time.Now().UnixNano()
+		if now <= last {
+			now = last + 1
+		}
+
+		if atomic.CompareAndSwapInt64(&c.last, last, now) {
+			return now
+		}
+	}
+}
+
+// Observe 记录其它节点的时间戳
+func (c *Clock) Observe(ts int64) {
+	for {
+		last := atomic.LoadInt64(&c.last)
+		if ts <= last || atomic.CompareAndSwapInt64(&c.last, last, ts) {
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// expiryItem 在at之后需要检查的session
+type expiryItem struct {
+	at     int64
+	client *Client
+}
+
+// expiryHeap 按检查时间排序的最小堆
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].at < h[j].at }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiryItem)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = expiryItem{}
+	*h = old[:len(old)-1]
+	return item
+}
+
+// expiryQueue 过期索引, 检查过期时只处理已经到期的session
+// 延长过期时间不更新索引, 到期检查时按session当前的过期时间重新加入
+type expiryQueue struct {
+	items expiryHeap
+	mutex sync.Mutex
+}
+
+// push 在at之后检查client
+func (q *expiryQueue) push(client *Client, at int64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	heap.Push(&q.items, expiryItem{at: at, client: client})
+}
+
+// due 取出检查时间早于now的session
+func (q *expiryQueue) due(now int64) []*Client {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	clients := make([]*Client, 0)
+	for len(q.items) > 0 && q.items[0].at < now {
+		clients = append(clients, heap.Pop(&q.items).(expiryItem).client)
+	}
+
+	return clients
+}
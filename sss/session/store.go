@@ -3,20 +3,71 @@
 package session
 
 import (
+	"sort"
 	"sync"
 )
 
 // Store session 存储
 type Store struct {
 	store sync.Map
+
+	// tombstones 已删除session的删除时间戳, 防止集群同步时旧的创建覆盖删除
+	tombstones map[string]int64
+
+	// leases 服务租约, 租约过期时该服务的session同时过期
+	leases map[string]lease
+
+	// expiry 按过期时间排序的session
+	expiry expiryQueue
+
+	// clock 本地修改的时间戳
+	clock Clock
+
+	// mutex 创建与删除
+	mutex sync.Mutex
+}
+
+// lease 服务租约
+type lease struct {
+	// expireAt 过期时间戳
+	expireAt int64
+
+	// local 最后一次续约由本节点处理, 本节点是该服务的session的所有者
+	local bool
 }
 
 // NewClient 创建一个新的session
 func (ss *Store) NewClient(id string) *Client {
-	var s Client
-	s.id = id
-	ss.store.Store(s.id, &s)
-	return &s
+	s, _ := ss.ApplyNew(id, ss.clock.Now())
+	return s
+}
+
+// ApplyNew 按时间戳创建session, 后写优先
+// 已存在创建时间相同的session时返回该session和false, 调用者合并参数;
+// 创建时间更晚的session或者更晚的删除已经存在时返回nil
+func (ss *Store) ApplyNew(id string, ts int64) (*Client, bool) {
+	ss.clock.Observe(ts)
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.tombstones[id] >= ts {
+		return nil, false
+	}
+
+	if old := ss.Get(id); old != nil {
+		if old.Version() > ts {
+			return nil, false
+		}
+
+		if old.Version() == ts {
+			return old, false
+		}
+	}
+
+	delete(ss.tombstones, id)
+	s := newClient(id, ts, &ss.clock, &ss.expiry)
+	ss.store.Store(id, s)
+	return s, true
 }
 
 // Get 获取session
@@ -29,9 +80,32 @@ func (ss *Store) Get(id string) *Client {
 	return s.(*Client)
 }
 
-// Remove 删除session
-func (ss *Store) Remove(id string) {
-	ss.store.Delete(id)
+// Remove 删除session, 返回被删除的session, 不存在时返回nil
+func (ss *Store) Remove(id string) *Client {
+	return ss.ApplyRemove(id, ss.clock.Now())
+}
+
+// ApplyRemove 按时间戳删除session, 后写优先
+// 返回被删除的session, 不存在或者session的创建时间更晚时返回nil
+func (ss *Store) ApplyRemove(id string, ts int64) *Client {
+	ss.clock.Observe(ts)
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	s := ss.Get(id)
+	if s != nil && s.Version() > ts {
+		return nil
+	}
+
+	if ss.tombstones[id] < ts {
+		ss.tombstones[id] = ts
+	}
+
+	if s != nil {
+		ss.store.Delete(id)
+	}
+
+	return s
 }
 
 // Store 保存session
@@ -39,7 +113,180 @@ func (ss *Store) Store(s *Client) {
 	ss.store.Store(s.id, s)
 }
 
+// Range 遍历所有session, fn返回false时停止
+func (ss *Store) Range(fn func(*Client) bool) {
+	ss.store.Range(func(_, v interface{}) bool {
+		return fn(v.(*Client))
+	})
+}
+
+// List 按id排序分页查询, 返回id大于cursor并且match返回true的session
+// 还有更多数据时返回下一页的cursor, 否则为空. match为nil时返回所有session
+func (ss *Store) List(cursor string, limit int, match func(*Client) bool) ([]*Client, string) {
+	clients := make([]*Client, 0)
+	ss.store.Range(func(k, v interface{}) bool {
+		s := v.(*Client)
+		if k.(string) > cursor && (match == nil || match(s)) {
+			clients = append(clients, s)
+		}
+		return true
+	})
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].id < clients[j].id
+	})
+
+	if limit < 1 || len(clients) <= limit {
+		return clients, ""
+	}
+
+	clients = clients[:limit]
+	return clients, clients[limit-1].id
+}
+
+// Tombstones 已删除session的删除时间戳
+func (ss *Store) Tombstones() map[string]int64 {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	m := make(map[string]int64, len(ss.tombstones))
+	for k, v := range ss.tombstones {
+		m[k] = v
+	}
+	return m
+}
+
+// Compact 清理早于before的删除记录, 返回清理的数量
+func (ss *Store) Compact(before int64) int {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	n := 0
+	for k, v := range ss.tombstones {
+		if v < before {
+			delete(ss.tombstones, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Lease 本节点处理的服务续约, 只会延长不会缩短
+// 本节点成为该服务的session的所有者
+func (ss *Store) Lease(remoteServID string, expireAt int64) {
+	ss.lease(remoteServID, expireAt, true)
+}
+
+// ApplyLease 集群中其它节点处理的服务续约, 只会延长不会缩短
+// 续约更新时本节点不再是该服务的session的所有者
+func (ss *Store) ApplyLease(remoteServID string, expireAt int64) {
+	ss.lease(remoteServID, expireAt, false)
+}
+
+func (ss *Store) lease(remoteServID string, expireAt int64, local bool) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	old := ss.leases[remoteServID]
+	switch {
+	case old.expireAt < expireAt:
+		ss.leases[remoteServID] = lease{expireAt: expireAt, local: local}
+
+	case old.expireAt == expireAt && local:
+		ss.leases[remoteServID] = lease{expireAt: expireAt, local: true}
+	}
+}
+
+// RevokeLease 撤销服务租约
+func (ss *Store) RevokeLease(remoteServID string) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	delete(ss.leases, remoteServID)
+}
+
+// Leases 服务租约的过期时间戳
+func (ss *Store) Leases() map[string]int64 {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	m := make(map[string]int64, len(ss.leases))
+	for k, v := range ss.leases {
+		m[k] = v.expireAt
+	}
+	return m
+}
+
+// Expire 返回已经过期的session, 包括租约已经过期的服务的所有session
+// 过期时间使用本地时间, 不使用集群同步的时间戳, 其它节点的时钟偏差不会让session提前过期.
+// 本节点持有服务租约的session到期立即过期, 其它session在过期grace之后才过期,
+// 等待所有者的续约通过集群同步到达. 过期的租约会被删除, session由调用者删除
+func (ss *Store) Expire(now, grace int64) []*Client {
+	expired := make(map[string]bool)
+	ss.mutex.Lock()
+	for k, v := range ss.leases {
+		deadline := v.expireAt
+		if !v.local {
+			deadline += grace
+		}
+
+		if deadline < now {
+			expired[k] = true
+			delete(ss.leases, k)
+		}
+	}
+	ss.mutex.Unlock()
+
+	clients := make([]*Client, 0)
+	due := make(map[*Client]bool)
+	for _, s := range ss.expiry.due(now) {
+		// 已经删除或者重新创建
+		if ss.Get(s.id) != s {
+			continue
+		}
+
+		deadline := s.ExpireAt()
+		if !ss.owns(s) {
+			deadline += grace
+		}
+
+		if deadline >= now {
+			ss.expiry.push(s, deadline)
+			continue
+		}
+
+		due[s] = true
+		clients = append(clients, s)
+	}
+
+	if len(expired) > 0 {
+		ss.Range(func(s *Client) bool {
+			if _, _, remoteServID := s.Remote(); expired[remoteServID] && !due[s] {
+				clients = append(clients, s)
+			}
+			return true
+		})
+	}
+
+	return clients
+}
+
+// owns 本节点是否持有session所在服务的租约
+func (ss *Store) owns(s *Client) bool {
+	_, _, remoteServID := s.Remote()
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	return ss.leases[remoteServID].local
+}
+
+// Now 返回本地修改的时间戳
+func (ss *Store) Now() int64 {
+	return ss.clock.Now()
+}
+
 // NewStore 创建session存储器
 func NewStore() *Store {
-	return &Store{}
+	return &Store{
+		tombstones: make(map[string]int64),
+		leases:     make(map[string]lease),
+	}
 }
@@ -15,14 +15,60 @@ var (
 
 	// ErrNotFound 存储信息不存在
 	ErrNotFound = errors.New("ErrNotFound")
+
+	// ErrInvalidOverflowPolicy 不支持的溢出处理方式
+	ErrInvalidOverflowPolicy = errors.New("ErrInvalidOverflowPolicy")
 )
 
+// OverflowPolicy 订阅者接收缓冲已满时的处理方式
+type OverflowPolicy int32
+
+// 溢出处理方式定义
+const (
+	// OverflowDropNewest 丢弃新的事件, 默认
+	OverflowDropNewest OverflowPolicy = iota
+
+	// OverflowDropOldest 丢弃缓冲中最早的事件, 保留新的事件
+	// 被丢弃的事件在投递时已经计入广播的投递数量
+	OverflowDropOldest
+
+	// OverflowDisconnect 断开订阅, 由订阅者重新订阅
+	OverflowDisconnect
+)
+
+var overflowPolicyNames = map[string]OverflowPolicy{
+	"drop-newest": OverflowDropNewest,
+	"drop-oldest": OverflowDropOldest,
+	"disconnect":  OverflowDisconnect,
+}
+
+// ParseOverflowPolicy 解析溢出处理方式, 空字符串为OverflowDropNewest
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	if s == "" {
+		return OverflowDropNewest, nil
+	}
+
+	policy, ok := overflowPolicyNames[s]
+	if !ok {
+		return OverflowDropNewest, ErrInvalidOverflowPolicy
+	}
+
+	return policy, nil
+}
+
 // Subscriber 订阅人
 type Subscriber struct {
 	id        string
 	serviceID int32
 	recvchan  chan *pb.SessionStateServerAPI_BroadcastResponse
 	events    map[int32]bool
+
+	// overflow 接收缓冲已满时的处理方式
+	overflow OverflowPolicy
+
+	// die 因溢出断开订阅的信号
+	die     chan struct{}
+	dieOnce sync.Once
 }
 
 func (subscriber *Subscriber) GetID() string {
@@ -33,17 +79,73 @@ func (subscriber *Subscriber) GetServiceID() int32 {
 	return subscriber.serviceID
 }
 
+// Subscribed 是否订阅了事件
+func (subscriber *Subscriber) Subscribed(event int32) bool {
+	return subscriber.events[event]
+}
+
 func (subscriber *Subscriber) GetRecv() chan *pb.SessionStateServerAPI_BroadcastResponse {
 	return subscriber.recvchan
 }
 
+// Deliver 投递事件, 不会阻塞
+// 接收缓冲已满时按溢出处理方式处理, 返回frame是否放入了接收缓冲.
+// OverflowDropOldest丢弃的是之前已经返回true的frame
+func (subscriber *Subscriber) Deliver(frame *pb.SessionStateServerAPI_BroadcastResponse) bool {
+	select {
+	case <-subscriber.die:
+		return false
+	default:
+	}
+
+	select {
+	case subscriber.recvchan <- frame:
+		return true
+	default:
+	}
+
+	switch subscriber.overflow {
+	case OverflowDropOldest:
+		select {
+		case <-subscriber.recvchan:
+		default:
+		}
+
+		// 同时广播时腾出的位置可能被占用, 这时放弃新的事件
+		select {
+		case subscriber.recvchan <- frame:
+			return true
+		default:
+		}
+
+	case OverflowDisconnect:
+		subscriber.Close()
+	}
+
+	return false
+}
+
+// Close 断开订阅, 可以重复调用
+func (subscriber *Subscriber) Close() {
+	subscriber.dieOnce.Do(func() {
+		close(subscriber.die)
+	})
+}
+
+// Done 订阅被断开的信号
+func (subscriber *Subscriber) Done() <-chan struct{} {
+	return subscriber.die
+}
+
 // NewSubscriber 创建订阅者
-func NewSubscriber(id string, serviceID int32, events ...int32) *Subscriber {
+func NewSubscriber(id string, serviceID int32, overflow OverflowPolicy, events ...int32) *Subscriber {
 	subscriber := &Subscriber{
 		id:        id,
 		serviceID: serviceID,
 		recvchan:  make(chan *pb.SessionStateServerAPI_BroadcastResponse, 128),
 		events:    make(map[int32]bool),
+		overflow:  overflow,
+		die:       make(chan struct{}),
 	}
 
 	for _, event := range events {
@@ -61,7 +163,7 @@ type SubscribeStore struct {
 }
 
 // NewSubscriber 创建新订阅信息
-func (subscribeStore *SubscribeStore) NewSubscriber(id string, serviceID int32, events []int32) (*Subscriber, error) {
+func (subscribeStore *SubscribeStore) NewSubscriber(id string, serviceID int32, events []int32, overflow OverflowPolicy) (*Subscriber, error) {
 	subscribeStore.mutex.Lock()
 	defer subscribeStore.mutex.Unlock()
 
@@ -70,11 +172,11 @@ func (subscribeStore *SubscribeStore) NewSubscriber(id string, serviceID int32,
 	}
 
 	subscribeStore.storesMap[id] = true
-	if _, ok := subscribeStore.stores[serviceID]; ok {
+	if _, ok := subscribeStore.stores[serviceID]; !ok {
 		subscribeStore.stores[serviceID] = make([]*Subscriber, 0)
 	}
 
-	subscriber := NewSubscriber(id, serviceID, events...)
+	subscriber := NewSubscriber(id, serviceID, overflow, events...)
 	subscribeStore.stores[serviceID] = append(subscribeStore.stores[serviceID], subscriber)
 	return subscriber, nil
 }
@@ -93,7 +195,7 @@ func (subscribeStore *SubscribeStore) Store(subscriber *Subscriber) error {
 	}
 
 	subscribeStore.storesMap[subscriber.GetID()] = true
-	if _, ok := subscribeStore.stores[subscriber.GetServiceID()]; ok {
+	if _, ok := subscribeStore.stores[subscriber.GetServiceID()]; !ok {
 		subscribeStore.stores[subscriber.GetServiceID()] = make([]*Subscriber, 0)
 	}
 
@@ -0,0 +1,69 @@
+// Copyright (c) 2019 The balala Authors <https://github.com/doublemo/balala>
+
+package session
+
+import (
+	"testing"
+
+	"github.com/doublemo/balala/sss/proto/pb"
+)
+
+func TestSubscriberDeliver(t *testing.T) {
+	frame := func(i int) *pb.SessionStateServerAPI_BroadcastResponse {
+		return &pb.SessionStateServerAPI_BroadcastResponse{Actions: []*pb.SessionStateServerAPI_Event{{Action: int32(i)}}}
+	}
+
+	cases := []struct {
+		policy    string
+		delivered bool
+		first     int32
+		closed    bool
+	}{
+		{"", false, 0, false},
+		{"drop-newest", false, 0, false},
+		{"drop-oldest", true, 1, false},
+		{"disconnect", false, 0, true},
+	}
+
+	for _, c := range cases {
+		policy, err := ParseOverflowPolicy(c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subscriber := NewSubscriber("s1", 1, policy, pb.EventKick)
+		for i := 0; i < cap(subscriber.GetRecv()); i++ {
+			if !subscriber.Deliver(frame(i)) {
+				t.Fatalf("%s: frame %d not delivered", c.policy, i)
+			}
+		}
+
+		if ok := subscriber.Deliver(frame(cap(subscriber.GetRecv()))); ok != c.delivered {
+			t.Fatalf("%s: expected delivered %v, got %v", c.policy, c.delivered, ok)
+		}
+
+		if m := <-subscriber.GetRecv(); m.Actions[0].Action != c.first {
+			t.Fatalf("%s: expected first action %d, got %d", c.policy, c.first, m.Actions[0].Action)
+		}
+
+		select {
+		case <-subscriber.Done():
+			if !c.closed {
+				t.Fatalf("%s: unexpected disconnect", c.policy)
+			}
+
+			if subscriber.Deliver(frame(0)) {
+				t.Fatalf("%s: delivered after disconnect", c.policy)
+			}
+
+		default:
+			if c.closed {
+				t.Fatalf("%s: expected disconnect", c.policy)
+			}
+		}
+	}
+
+	if _, err := ParseOverflowPolicy("block"); err != ErrInvalidOverflowPolicy {
+		t.Fatalf("expected ErrInvalidOverflowPolicy, got %v", err)
+	}
+}
@@ -7,13 +7,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/doublemo/balala/cores/process"
 	"github.com/doublemo/balala/cores/services"
+	"github.com/doublemo/balala/cores/tracing"
 	"github.com/doublemo/balala/cores/utils"
 	"github.com/doublemo/balala/internal/serviceid"
 	"github.com/doublemo/balala/sss/service"
+	"github.com/doublemo/balala/sss/session"
 	"github.com/go-kit/kit/log"
 	kitlog "github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/sd/etcdv3"
@@ -39,34 +44,72 @@ type SSS struct {
 	// ServiceOpts 系统服务参数
 	serviceOpts *services.Options
 
+	// actors 服务名称对应的协程ID, 用于重新加载配置时重启服务
+	actors map[string]int32
+
+	// readyedOnce 服务注册可能因为重新加载配置而重启
+	readyedOnce sync.Once
+
+	// reloadMutex 重新加载配置
+	reloadMutex sync.Mutex
+
+	// logLevel 日志级别过滤, 重新加载配置时切换
+	logLevel *log.SwapLogger
+
+	// rawLogger 未过滤级别的日志
+	rawLogger log.Logger
+
 	// servicesCaches 集群服务信息缓存
 	servicesCaches map[int32]string
 
+	// clusterChan 集群服务信息发生变化时通知集群同步
+	clusterChan chan struct{}
+
+	// sessionStore 客户端信息存储, grpc服务重启时保持不变
+	sessionStore *session.Store
+
+	// subscribes 订阅信息, grpc服务重启时保持不变
+	subscribes *session.SubscribeStore
+
+	// tracer 请求追踪, grpc服务重启时保持不变
+	tracer *tracing.Zipkin
+
+	// registerChan 通知服务注册以当前参数覆盖注册信息
+	registerChan chan struct{}
+
 	// logger
 	logger log.Logger
 }
 
+// runtimeActors 服务注册顺序就是服务的启动顺序
+// 关闭服务时会反顺关闭
+var runtimeActors = []string{"grpc", "services"}
+
 // Start 启动服务
 func (s *SSS) Start() {
 	defer func() {
 		close(s.exitChan)
 	}()
 
-	// 读取一个配置文件副本
-	//opts := s.configureOptions.Read()
+	s.reloadMutex.Lock()
+
+	// 保存配置副本, 之后读取配置不需要重新查询本机IP
+	s.configureOptions.Reset(s.configureOptions.Read())
 
 	// init etcd
 	utils.Assert(s.makeEtcdv3Client())
 
+	// init tracer
+	utils.Assert(s.makeTracer())
+
 	// 开始注册服务
-	// 注意服务注册顺序就是服务的启动顺序
-	// 关闭服务时会反顺关闭
-	// internal grpc
-	s.process.Add(s.mustRuntimeActor(makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.logger)), true)
+	for _, name := range runtimeActors {
+		s.actors[name] = s.mustRuntimeActor(name)
+	}
 
-	// 创建服务
-	s.process.Add(s.mustRuntimeActor(s.makeServices()), true)
+	s.reloadMutex.Unlock()
 	s.process.Run()
+	s.tracer.Close()
 }
 
 // Readyed 返回服务准备就绪信号
@@ -81,8 +124,100 @@ func (s *SSS) Shutdown() {
 	s.process.Stop()
 }
 
-// Reload 重新加载服务
-func (s *SSS) Reload() {}
+// Reload 重新加载配置文件
+// 日志级别与追踪立即生效, grpc参数改变时只重启grpc服务.
+// 配置文件错误时保留原来的配置
+func (s *SSS) Reload() {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	// 服务还没有启动
+	if len(s.actors) < 1 {
+		return
+	}
+
+	old := s.configureOptions.Read()
+	if err := s.configureOptions.Load(); err != nil {
+		kitlog.Error(s.logger).Log("reload", "failed", "error", err)
+		return
+	}
+
+	// 以下参数需要重启进程才能生效, 保持原来的值
+	opts := s.configureOptions.Read()
+	var ignored []string
+	if opts.ID != old.ID || opts.LocalIP != old.LocalIP || opts.Domain != old.Domain {
+		opts.ID, opts.LocalIP, opts.Domain = old.ID, old.LocalIP, old.Domain
+		ignored = append(ignored, "id/localip/domain")
+	}
+
+	if !reflect.DeepEqual(opts.ETCD, old.ETCD) {
+		opts.ETCD = old.ETCD
+		ignored = append(ignored, "etcd")
+	}
+
+	if opts.ServiceSecurityKey != old.ServiceSecurityKey {
+		opts.ServiceSecurityKey = old.ServiceSecurityKey
+		ignored = append(ignored, "servicesecuritykey")
+	}
+
+	s.configureOptions.Reset(opts)
+
+	var (
+		changed  []string
+		restarts []string
+	)
+
+	if opts.Runmode != old.Runmode {
+		s.logLevel.Swap(utils.LevelLogger(s.rawLogger, opts.Runmode))
+		changed = append(changed, "runmode")
+	}
+
+	if !reflect.DeepEqual(opts.Tracer, old.Tracer) {
+		s.tracer.SetReporterURL(opts.Tracer.GetReporterURL())
+		changed = append(changed, "tracer")
+	}
+
+	restart := make(map[string]bool)
+	fields, _ := utils.DiffFields(old.GRPC, opts.GRPC)
+	for _, field := range fields {
+		changed = append(changed, "grpc."+field)
+	}
+
+	if len(fields) > 0 {
+		restart["grpc"] = true
+		restart["services"] = true
+	}
+
+	// 优先级只需要更新注册信息
+	var register bool
+	if opts.Priority != old.Priority {
+		s.serviceOpts.Priority = opts.Priority
+		changed = append(changed, "priority")
+		register = true
+	}
+
+	for _, name := range runtimeActors {
+		if !restart[name] {
+			continue
+		}
+
+		if err := s.restartRuntimeActor(name); err != nil {
+			kitlog.Error(s.logger).Log("reload", "restart", "actor", name, "error", err)
+			continue
+		}
+
+		restarts = append(restarts, name)
+	}
+
+	if register && !restart["services"] {
+		select {
+		case s.registerChan <- struct{}{}:
+		default:
+		}
+	}
+
+	s.logger.Log("reload", "ok", "changed", strings.Join(changed, ","), "restarted", strings.Join(restarts, ","), "ignored", strings.Join(ignored, ","))
+}
 
 // ServiceName 返回唯一服务名称
 func (s *SSS) ServiceName() string {
@@ -157,21 +292,36 @@ func (s *SSS) makeEtcdv3Client() error {
 	return nil
 }
 
+func (s *SSS) makeTracer() error {
+	opts := s.configureOptions.Read()
+	tracer, err := tracing.NewZipkin("sss", opts.Tracer.GetReporterURL())
+	if err != nil {
+		return err
+	}
+
+	s.tracer = tracer
+	return nil
+}
+
 func (s *SSS) makeServices() (*process.RuntimeActor, error) {
 	opts := s.configureOptions.Read()
 	if opts.ETCD == nil {
 		return nil, errors.New("ETCD options is nil")
 	}
 
-	registrar := etcdv3.NewRegistrar(s.etcdV3Client, etcdv3.Service{
+	regService := etcdv3.Service{
 		Key:   services.RegKey(opts.ETCD.Frefix, s.serviceOpts),
 		Value: services.RegValue(s.serviceOpts),
-	}, s.logger)
+	}
+
+	registrar := etcdv3.NewRegistrar(s.etcdV3Client, regService, s.logger)
 	serviceChan := make(chan struct{})
 	return &process.RuntimeActor{
 		Exec: func() error {
 			registrar.Register()
-			close(s.readyedChan)
+			s.readyedOnce.Do(func() {
+				close(s.readyedChan)
+			})
 			ch := make(chan struct{})
 			go s.etcdV3Client.WatchPrefix(opts.ETCD.Frefix, ch)
 			for {
@@ -182,11 +332,26 @@ func (s *SSS) makeServices() (*process.RuntimeActor, error) {
 						continue
 					}
 
-					service.Caches.Reset()
-					for _, s := range instances {
-						service.Caches.StoreFromString(s)
+					// 一次替换, 集群同步时不会看到没有服务的中间状态
+					values := make([]*services.Options, 0, len(instances))
+					for _, v := range instances {
+						if o, err := services.RegValueFromString(v); err == nil {
+							values = append(values, o)
+						}
 					}
 
+					service.Caches.Replace(values...)
+
+					select {
+					case s.clusterChan <- struct{}{}:
+					default:
+					}
+
+				case <-s.registerChan:
+					// 相同的key以新的值重新注册, 关闭时原来的registrar仍然可以注销
+					regService.Value = services.RegValue(s.serviceOpts)
+					etcdv3.NewRegistrar(s.etcdV3Client, regService, s.logger).Register()
+
 				case <-s.exitChan:
 					return nil
 
@@ -205,33 +370,71 @@ func (s *SSS) makeServices() (*process.RuntimeActor, error) {
 	}, nil
 }
 
-func (s *SSS) mustRuntimeActor(actor *process.RuntimeActor, err error) *process.RuntimeActor {
+// makeRuntimeActor 根据当前配置创建服务
+func (s *SSS) makeRuntimeActor(name string) (*process.RuntimeActor, error) {
+	switch name {
+	case "grpc":
+		return makeGRPCRuntimeActor(s.serviceOpts, s.configureOptions.Read(), s.sessionStore, s.subscribes, s.clusterChan, s.tracer, s.logger)
+
+	case "services":
+		return s.makeServices()
+	}
+
+	return nil, nil
+}
+
+// restartRuntimeActor 关闭服务并按当前配置重新创建
+func (s *SSS) restartRuntimeActor(name string) error {
+	id, err := s.process.Replace(s.actors[name], s.runtimeActorFunc(name))
+
+	s.actors[name] = id
+	return err
+}
+
+// runtimeActorFunc 服务的创建函数, 服务重启时按当前配置重新创建
+// 同时删除注册信息中原来的监听地址
+func (s *SSS) runtimeActorFunc(name string) func() (*process.RuntimeActor, error) {
+	return func() (*process.RuntimeActor, error) {
+		delete(s.serviceOpts.Params, name)
+		return s.makeRuntimeActor(name)
+	}
+}
+
+// mustRuntimeActor 注册服务, 创建失败时退出
+func (s *SSS) mustRuntimeActor(name string) int32 {
+	id, err := s.process.AddFunc(s.runtimeActorFunc(name))
 	if err != nil {
 		kitlog.Error(s.logger).Log("error", err)
 		panic(err)
 	}
 
-	return actor
+	return id
 }
 
 // New 创建网关服务
 func New(serviceOpts *services.Options, opts *ConfigureOptions) *SSS {
-	logger := log.NewLogfmtLogger(os.Stderr)
-	logger = log.WithPrefix(logger, "o", "Sesson-state server")
-	if opts.Read().Runmode == "dev" {
-		logger = kitlog.NewFilter(logger, kitlog.AllowAll())
-	} else {
-		logger = kitlog.NewFilter(logger, kitlog.AllowError(), kitlog.AllowWarn())
-	}
+	rawLogger := log.NewLogfmtLogger(os.Stderr)
+	rawLogger = log.WithPrefix(rawLogger, "o", "Sesson-state server")
+
+	var logLevel log.SwapLogger
+	logLevel.Swap(utils.LevelLogger(rawLogger, opts.Read().Runmode))
 
-	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	var logger log.Logger
+	logger = log.With(&logLevel, "ts", log.DefaultTimestampUTC)
 	logger = log.With(logger, "caller", log.DefaultCaller)
 
 	return &SSS{
 		exitChan:         make(chan struct{}),
 		readyedChan:      make(chan struct{}),
+		clusterChan:      make(chan struct{}, 1),
+		registerChan:     make(chan struct{}, 1),
+		sessionStore:     session.NewStore(),
+		subscribes:       session.NewSubscribeStore(),
 		configureOptions: opts,
 		process:          process.NewRuntimeContainer(),
+		actors:           make(map[string]int32),
+		logLevel:         &logLevel,
+		rawLogger:        rawLogger,
 		logger:           logger,
 		serviceOpts:      serviceOpts,
 	}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 	"time"
 
 	jwtgo "github.com/dgrijalva/jwt-go"
@@ -34,17 +35,24 @@ import (
 
 // GRPCServer 内部响应GRPC服务
 type GRPCServer struct {
-	subscribe grpctransport.Handler
-	broadcast grpctransport.Handler
-	new       grpctransport.Handler
-	remove    grpctransport.Handler
-	params    grpctransport.Handler
+	subscribe   grpctransport.Handler
+	broadcast   grpctransport.Handler
+	new         grpctransport.Handler
+	remove      grpctransport.Handler
+	params      grpctransport.Handler
+	replicate   grpctransport.Handler
+	get         grpctransport.Handler
+	batchGet    grpctransport.Handler
+	findByParam grpctransport.Handler
+	list        grpctransport.Handler
+	touch       grpctransport.Handler
+	lease       grpctransport.Handler
 }
 
 // Subscribe 订阅
 func (s *GRPCServer) Subscribe(stream pb.SessionStateServer_SubscribeServer) error {
-	s.subscribe.ServeGRPC(context.Background(), stream)
-	return nil
+	_, _, err := s.subscribe.ServeGRPC(stream.Context(), stream)
+	return err
 }
 
 // Broadcast 广播
@@ -83,6 +91,66 @@ func (s *GRPCServer) Params(ctx context.Context, in *pb.SessionStateServerAPI_Ne
 	return rep.(*pb.SessionStateServerAPI_Nil), nil
 }
 
+// Replicate 集群节点之间同步连接状态
+func (s *GRPCServer) Replicate(stream pb.SessionStateServer_ReplicateServer) error {
+	_, _, err := s.replicate.ServeGRPC(stream.Context(), stream)
+	return err
+}
+
+// Get 查询连接信息
+func (s *GRPCServer) Get(ctx context.Context, in *pb.SessionStateServerAPI_GetRequest) (*pb.SessionStateServerAPI_GetResponse, error) {
+	_, rep, err := s.get.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_GetResponse), nil
+}
+
+// BatchGet 批量查询连接信息
+func (s *GRPCServer) BatchGet(ctx context.Context, in *pb.SessionStateServerAPI_BatchGetRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	_, rep, err := s.batchGet.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// FindByParam 按参数查找连接
+func (s *GRPCServer) FindByParam(ctx context.Context, in *pb.SessionStateServerAPI_FindByParamRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	_, rep, err := s.findByParam.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// List 分页查询所有连接
+func (s *GRPCServer) List(ctx context.Context, in *pb.SessionStateServerAPI_ListRequest) (*pb.SessionStateServerAPI_ListResponse, error) {
+	_, rep, err := s.list.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_ListResponse), nil
+}
+
+// Touch 心跳, 延长连接的过期时间
+func (s *GRPCServer) Touch(ctx context.Context, in *pb.SessionStateServerAPI_TouchRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	_, rep, err := s.touch.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_Nil), nil
+}
+
+// Lease 服务租约
+func (s *GRPCServer) Lease(ctx context.Context, in *pb.SessionStateServerAPI_LeaseRequest) (*pb.SessionStateServerAPI_Nil, error) {
+	_, rep, err := s.lease.ServeGRPC(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SessionStateServerAPI_Nil), nil
+}
+
 // NewGRPCServer 创建内部服务grpc server
 func NewGRPCServer(endpoints sssendpoint.Set, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.SessionStateServerServer {
 	options := []grpctransport.ServerOption{grpctransport.ServerErrorLogger(logger)}
@@ -135,6 +203,55 @@ func NewGRPCServer(endpoints sssendpoint.Set, otTracer stdopentracing.Tracer, zi
 			encodeGRPCResponse,
 			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "Params", logger)))...,
 		),
+
+		replicate: grpctransport.NewServer(
+			endpoints.ReplicateEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "Replicate", logger)))...,
+		),
+
+		get: grpctransport.NewServer(
+			endpoints.GetEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "Get", logger)))...,
+		),
+
+		batchGet: grpctransport.NewServer(
+			endpoints.BatchGetEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "BatchGet", logger)))...,
+		),
+
+		findByParam: grpctransport.NewServer(
+			endpoints.FindByParamEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "FindByParam", logger)))...,
+		),
+
+		list: grpctransport.NewServer(
+			endpoints.ListEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "List", logger)))...,
+		),
+
+		touch: grpctransport.NewServer(
+			endpoints.TouchEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "Touch", logger)))...,
+		),
+
+		lease: grpctransport.NewServer(
+			endpoints.LeaseEndpoint,
+			decodeGRPCRequest,
+			encodeGRPCResponse,
+			append(options, grpctransport.ServerBefore(jwt.GRPCToContext(), opentracing.GRPCToContext(otTracer, "Lease", logger)))...,
+		),
 	}
 }
 
@@ -170,7 +287,7 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 
 	var newEndpoint endpoint.Endpoint
 	{
-		broadcastEndpoint = grpctransport.NewClient(conn,
+		newEndpoint = grpctransport.NewClient(conn,
 			"pb.SessionStateServer",
 			"New",
 			encodeGRPRequest,
@@ -228,11 +345,137 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 		}))(paramsEndpoint)
 	}
 
+	var getEndpoint endpoint.Endpoint
+	{
+		getEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"Get",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_GetResponse{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		getEndpoint = jwtEndpoint(getEndpoint)
+		getEndpoint = opentracing.TraceClient(otTracer, "Get")(getEndpoint)
+		getEndpoint = limiter(getEndpoint)
+		getEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Get",
+			Timeout: 30 * time.Second,
+		}))(getEndpoint)
+	}
+
+	var batchGetEndpoint endpoint.Endpoint
+	{
+		batchGetEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"BatchGet",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_ListResponse{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		batchGetEndpoint = jwtEndpoint(batchGetEndpoint)
+		batchGetEndpoint = opentracing.TraceClient(otTracer, "BatchGet")(batchGetEndpoint)
+		batchGetEndpoint = limiter(batchGetEndpoint)
+		batchGetEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "BatchGet",
+			Timeout: 30 * time.Second,
+		}))(batchGetEndpoint)
+	}
+
+	var findByParamEndpoint endpoint.Endpoint
+	{
+		findByParamEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"FindByParam",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_ListResponse{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		findByParamEndpoint = jwtEndpoint(findByParamEndpoint)
+		findByParamEndpoint = opentracing.TraceClient(otTracer, "FindByParam")(findByParamEndpoint)
+		findByParamEndpoint = limiter(findByParamEndpoint)
+		findByParamEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "FindByParam",
+			Timeout: 30 * time.Second,
+		}))(findByParamEndpoint)
+	}
+
+	var listEndpoint endpoint.Endpoint
+	{
+		listEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"List",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_ListResponse{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		listEndpoint = jwtEndpoint(listEndpoint)
+		listEndpoint = opentracing.TraceClient(otTracer, "List")(listEndpoint)
+		listEndpoint = limiter(listEndpoint)
+		listEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "List",
+			Timeout: 30 * time.Second,
+		}))(listEndpoint)
+	}
+
+	var touchEndpoint endpoint.Endpoint
+	{
+		touchEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"Touch",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_Nil{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		touchEndpoint = jwtEndpoint(touchEndpoint)
+		touchEndpoint = opentracing.TraceClient(otTracer, "Touch")(touchEndpoint)
+		touchEndpoint = limiter(touchEndpoint)
+		touchEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Touch",
+			Timeout: 30 * time.Second,
+		}))(touchEndpoint)
+	}
+
+	var leaseEndpoint endpoint.Endpoint
+	{
+		leaseEndpoint = grpctransport.NewClient(conn,
+			"pb.SessionStateServer",
+			"Lease",
+			encodeGRPRequest,
+			decodeGRPCResponse,
+			pb.SessionStateServerAPI_Nil{},
+			append(options, grpctransport.ClientBefore(jwt.ContextToGRPC()))...,
+		).Endpoint()
+
+		leaseEndpoint = jwtEndpoint(leaseEndpoint)
+		leaseEndpoint = opentracing.TraceClient(otTracer, "Lease")(leaseEndpoint)
+		leaseEndpoint = limiter(leaseEndpoint)
+		leaseEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "Lease",
+			Timeout: 30 * time.Second,
+		}))(leaseEndpoint)
+	}
+
 	return sssendpoint.Set{
-		BroadcastEndpoint: broadcastEndpoint,
-		NewEndpoint:       newEndpoint,
-		RemoveEndpoint:    removeEndpoint,
-		ParamsEndpoint:    paramsEndpoint,
+		BroadcastEndpoint:   broadcastEndpoint,
+		NewEndpoint:         newEndpoint,
+		RemoveEndpoint:      removeEndpoint,
+		ParamsEndpoint:      paramsEndpoint,
+		GetEndpoint:         getEndpoint,
+		BatchGetEndpoint:    batchGetEndpoint,
+		FindByParamEndpoint: findByParamEndpoint,
+		ListEndpoint:        listEndpoint,
+		TouchEndpoint:       touchEndpoint,
+		LeaseEndpoint:       leaseEndpoint,
 	}
 }
 
@@ -298,6 +541,55 @@ func makeSubscribeEndpoint(conn *grpc.ClientConn) endpoint.Endpoint {
 	}
 }
 
+// NewGRPCSubscribe 在指定连接上创建订阅流
+// param 中的events可以为多个事件, 以逗号分隔
+func NewGRPCSubscribe(conn *grpc.ClientConn, jwtToken []byte, param map[string]string) (GRPCStream, error) {
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{}).SignedString(jwtToken)
+	if err != nil {
+		return nil, err
+	}
+
+	md := metadata.New(nil)
+	for k, v := range param {
+		if k == "events" {
+			md.Append(k, strings.Split(v, ",")...)
+			continue
+		}
+
+		md.Set(k, v)
+	}
+
+	md.Set("authorization", "Bearer "+token)
+	cli := pb.NewSessionStateServerClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cli.Subscribe(metadata.NewOutgoingContext(ctx, md))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &DefaultGRPCStream{stream: stream, cancel: cancel}, nil
+}
+
+// NewGRPCReplicate 在指定连接上创建集群同步流
+func NewGRPCReplicate(conn *grpc.ClientConn, jwtToken []byte) (pb.SessionStateServer_ReplicateClient, context.CancelFunc, error) {
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.StandardClaims{}).SignedString(jwtToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	md := metadata.Pairs("authorization", "Bearer "+token)
+	cli := pb.NewSessionStateServerClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cli.Replicate(metadata.NewOutgoingContext(ctx, md))
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	return stream, cancel, nil
+}
+
 // MakeFactoryBroadcast Broadcast
 func MakeFactoryBroadcast(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
 	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
@@ -374,6 +666,120 @@ func MakeFactoryParams(logger log.Logger, otTracer stdopentracing.Tracer, zipkin
 	}
 }
 
+// MakeFactoryGet Get
+func MakeFactoryGet(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeGetEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
+// MakeFactoryBatchGet BatchGet
+func MakeFactoryBatchGet(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeBatchGetEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
+// MakeFactoryFindByParam FindByParam
+func MakeFactoryFindByParam(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeFindByParamEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
+// MakeFactoryList List
+func MakeFactoryList(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeListEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
+// MakeFactoryTouch Touch
+func MakeFactoryTouch(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeTouchEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
+// MakeFactoryLease Lease
+func MakeFactoryLease(logger log.Logger, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, jwtToken []byte) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		value, err := services.RegValueFromString(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conn, err := grpc.Dial(value.IP+":"+value.Port, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s := NewGRPCClient(conn, otTracer, zipkinTracer, jwtToken, logger)
+		doEndpoint := sssendpoint.MakeLeaseEndpoint(s)
+		return doEndpoint, conn, nil
+	}
+}
+
 func encodeGRPRequest(_ context.Context, request interface{}) (interface{}, error) {
 	return request, nil
 }